@@ -0,0 +1,81 @@
+/*
+Copyright IBM Corp 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+
+package main
+
+import (
+	"testing"
+
+	"github.com/vsagineedu/learn-chaincode/supplychain"
+	"github.com/vsagineedu/learn-chaincode/supplychain/shimstub/shimtest"
+)
+
+//==============================================================================================================================
+//	TestBluechainArgs - Runs the calls of a bluechain client in order against one ledger. Each step either fails with the
+//						error code given or succeeds with a result containing want. A corrupt step stores a record
+//						instead of calling the chaincode.
+//==============================================================================================================================
+func TestBluechainArgs(t *testing.T) {
+
+	l, err := shimtest.NewLedger("bluechain", new(SimpleChaincode), "admin")
+	if err != nil { t.Fatalf("init: %s", err) }
+
+	err = l.Register(
+		supplychain.Participant{ParticipantID: "supplier1", Organization: "org1", Roles: []string{supplychain.ROLE_SUPPLIER, supplychain.ROLE_OWNER}},
+		supplychain.Participant{ParticipantID: "operator1", Organization: "org1", Roles: []string{supplychain.ROLE_OPERATOR}},
+		supplychain.Participant{ParticipantID: "operator2", Organization: "org2", Roles: []string{supplychain.ROLE_OPERATOR}},
+	)
+	if err != nil { t.Fatal(err) }
+
+	create := []string{"A1", "supplier1", "operator1", "-0.1276", "51.5072", "Sheet", "steel", "12.5", "kg", ""}
+	badLongitude := []string{"A2", "supplier1", "operator1", "west", "51.5072", "", "steel", "1", "kg", ""}
+
+	steps := []struct {
+		kind     string
+		user     string
+		function string
+		args     []string
+		code     string
+		want     string
+	}{
+		{"invoke",  "supplier1", "create_supplyItem", create,                                         "",                  ""},
+		{"query",   "supplier1", "get_supplyItem",    []string{"A1"},                                 "",                  `"ownerID":"supplier1"`},
+		{"query",   "supplier1", "get_supplyItem",    []string{"A1"},                                 "",                  `"operatorID":"operator1"`},
+		{"invoke",  "supplier1", "create_supplyItem", create,                                         "ALREADY_EXISTS",    ""},
+		{"invoke",  "supplier1", "create_supplyItem", create[:9],                                     "INVALID_ARGUMENT",  ""},
+		{"invoke",  "supplier1", "create_supplyItem", badLongitude,                                   "INVALID_ARGUMENT",  ""},
+		{"invoke",  "operator1", "update_supplyItem", []string{"A1", "operator2"},                    "PERMISSION_DENIED", ""},
+		{"invoke",  "supplier1", "update_supplyItem", []string{"A1", "operator2"},                    "",                  ""},
+		{"query",   "supplier1", "get_supplyItem",    []string{"A1"},                                 "",                  `"operatorID":"operator1"`},
+		{"invoke",  "operator2", "accept_transfer",   []string{"A1", supplychain.TRANSFER_OPERATION}, "",                  ""},
+		{"query",   "supplier1", "get_supplyItem",    []string{"A1"},                                 "",                  `"operatorID":"operator2"`},
+		{"invoke",  "operator2", "update_supplyItem", []string{"A1", `{"description":"Cut sheet"}`},  "",                  ""},
+		{"query",   "supplier1", "get_supplyItem",    []string{"A1"},                                 "",                  `"description":"Cut sheet"`},
+		{"invoke",  "supplier1", "update_supplyItem", []string{"A1"},                                 "INVALID_ARGUMENT",  ""},
+		{"invoke",  "supplier1", "update_supplyItem", []string{"B1", "operator2"},                    "NOT_FOUND",         ""},
+		{"query",   "supplier1", "get_supplyItems",   []string{},                                     "",                  `"supplyItemID":"A1"`},
+		{"query",   "supplier1", "get_supplyItems",   []string{"0"},                                  "INVALID_ARGUMENT",  ""},
+		{"corrupt", "",          "",                  []string{"C1", "not a supplyItem"},             "",                  ""},
+		{"query",   "supplier1", "get_supplyItem",    []string{"C1"},                                 "CORRUPT_RECORD",    ""},
+		{"query",   "supplier1", "get_supplyItems",   []string{},                                     "CORRUPT_RECORD",    ""},
+	}
+
+	for i, step := range steps {
+		bytes, err := l.Step(step.kind, step.user, step.function, step.args...)
+		if err := shimtest.Check(bytes, err, step.code, step.want); err != nil { t.Errorf("step %d %s %v: %s", i, step.function, step.args, err) }
+	}
+}
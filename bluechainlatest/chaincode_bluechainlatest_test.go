@@ -0,0 +1,80 @@
+/*
+Copyright IBM Corp 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+
+package main
+
+import (
+	"testing"
+
+	"github.com/vsagineedu/learn-chaincode/supplychain"
+	"github.com/vsagineedu/learn-chaincode/supplychain/shimstub/shimtest"
+)
+
+//==============================================================================================================================
+//	TestChaincode - Runs the calls of a bluechainlatest client in order against one ledger. Each step either fails with the
+//					error code given or succeeds with a result containing want. A corrupt step stores a record instead
+//					of calling the chaincode.
+//==============================================================================================================================
+func TestChaincode(t *testing.T) {
+
+	l, err := shimtest.NewLedger("bluechainlatest", new(SimpleChaincode), "admin")
+	if err != nil { t.Fatalf("init: %s", err) }
+
+	err = l.Register(
+		supplychain.Participant{ParticipantID: "supplier1", Organization: "org1", Roles: []string{supplychain.ROLE_SUPPLIER, supplychain.ROLE_OWNER}},
+		supplychain.Participant{ParticipantID: "operator1", Organization: "org1", Roles: []string{supplychain.ROLE_OPERATOR}},
+	)
+	if err != nil { t.Fatal(err) }
+
+	item := `{"supplyItemID":"A1","supplierID":"supplier1","operatorID":"operator1","ownerID":"supplier1","longitude":-0.1276,"latitude":51.5072,"materialType":"steel","materialQuantity":12.5,"unitOfMeasure":"kg"}`
+	positional := []string{"A2", "supplier1", "", "supplier1", "-0.1276", "51.5072", "Sheet", "steel", "3", "kg", ""}	// bluechainlatest order, with ownerID after operatorID
+
+	steps := []struct {
+		kind     string
+		user     string
+		function string
+		args     []string
+		code     string
+		want     string
+	}{
+		{"invoke",  "supplier1", "create_supplyItem",      []string{item},                                "",                  ""},
+		{"query",   "supplier1", "get_supplyItem_history", []string{"A1"},                                "",                  `"txID":"create_supplyItem"`},
+		{"query",   "supplier1", "get_supplyItem_history", []string{"A1"},                                "",                  `"timestamp":"1970-01-01T00:00:00Z"`},
+		{"invoke",  "supplier1", "create_supplyItem",      positional,                                    "",                  ""},
+		{"query",   "supplier1", "get_supplyItem",         []string{"A2"},                                "",                  `"ownerID":"supplier1"`},
+		{"query",   "supplier1", "get_supplyItem",         []string{"A2"},                                "",                  `"materialQuantity":3`},
+		{"invoke",  "supplier1", "create_supplyItem",      positional[:10],                               "INVALID_ARGUMENT",  ""},
+		{"invoke",  "supplier1", "create_supplyItem",      []string{item},                                "ALREADY_EXISTS",    ""},
+		{"query",   "",          "get_supplyItem",         []string{"A1"},                                "PERMISSION_DENIED", ""},
+		{"invoke",  "operator1", "update_supplyItem",      []string{"A1", `{"description":"Cut sheet"}`}, "",                  ""},
+		{"query",   "supplier1", "get_supplyItem",         []string{"A1"},                                "",                  `"description":"Cut sheet"`},
+		{"invoke",  "operator1", "update_supplyItem",      []string{"A1", `{"ownerID":"operator1"}`},     "INVALID_ARGUMENT",  ""},
+		{"invoke",  "supplier1", "update_supplyItem",      []string{"A1", "operator1"},                   "INVALID_ARGUMENT",  ""},
+		{"invoke",  "supplier1", "update_supplyItem",      []string{"A1"},                                "INVALID_ARGUMENT",  ""},
+		{"invoke",  "supplier1", "update_supplyItem",      []string{"B1", `{"description":"Cut sheet"}`}, "NOT_FOUND",         ""},
+		{"query",   "supplier1", "get_supplyItems",        []string{},                                    "",                  `"supplyItemID":"A2"`},
+		{"query",   "supplier1", "get_supplyItems",        []string{"0"},                                 "INVALID_ARGUMENT",  ""},
+		{"corrupt", "",          "",                       []string{"C1", "not a supplyItem"},            "",                  ""},
+		{"query",   "supplier1", "get_supplyItem",         []string{"C1"},                                "CORRUPT_RECORD",    ""},
+		{"query",   "supplier1", "get_supplyItems",        []string{},                                    "CORRUPT_RECORD",    ""},
+	}
+
+	for i, step := range steps {
+		bytes, err := l.Step(step.kind, step.user, step.function, step.args...)
+		if err := shimtest.Check(bytes, err, step.code, step.want); err != nil { t.Errorf("step %d %s %v: %s", i, step.function, step.args, err) }
+	}
+}
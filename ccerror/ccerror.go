@@ -0,0 +1,182 @@
+/*
+Copyright IBM Corp 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+
+//==============================================================================================================================
+//	Package ccerror is the error returned by every chaincode in this repository. Each error carries a stable Code that
+//	clients can branch on, the function that failed, a message for people and, for invalid input, one entry per bad
+//	field. Error() renders it as JSON, so the error text returned through the REST API can be decoded with Parse.
+//==============================================================================================================================
+package ccerror
+
+import (
+	"encoding/json"
+)
+
+type Code string
+
+const (
+	NOT_FOUND         Code = "NOT_FOUND"			// The SupplyItem, participant or other record does not exist
+	ALREADY_EXISTS    Code = "ALREADY_EXISTS"		// A record with that ID already exists
+	PERMISSION_DENIED Code = "PERMISSION_DENIED"	// The caller may not call the function or act on the record
+	INVALID_ARGUMENT  Code = "INVALID_ARGUMENT"		// The arguments are missing, malformed or out of range
+	CORRUPT_RECORD    Code = "CORRUPT_RECORD"		// A record on the ledger cannot be decoded
+	CONFLICT          Code = "CONFLICT"				// The request conflicts with the current state of the record
+	INTERNAL          Code = "INTERNAL"				// The ledger could not be read or written
+)
+
+//==============================================================================================================================
+//	FieldError - A single problem with a single input field.
+//==============================================================================================================================
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+//==============================================================================================================================
+//	Error - The structured chaincode error.
+//==============================================================================================================================
+type Error struct {
+	Code     Code         `json:"code"`
+	Function string       `json:"function"`
+	Message  string       `json:"message"`
+	Fields   []FieldError `json:"fields,omitempty"`
+}
+
+//==============================================================================================================================
+//	 New - Returns an Error with the given code and message. The function is filled in by Wrap when the error reaches the
+//		   router, so code below the router does not need to know which function it was called from.
+//==============================================================================================================================
+func New(code Code, message string) *Error {
+	return &Error{Code: code, Message: message}
+}
+
+//==============================================================================================================================
+//	 Invalid - Returns an INVALID_ARGUMENT Error for function to which field errors can be added.
+//==============================================================================================================================
+func Invalid(function string) *Error {
+	return &Error{Code: INVALID_ARGUMENT, Function: function, Message: "Invalid arguments"}
+}
+
+func (e *Error) Error() string {
+	bytes, err := json.Marshal(e)
+	if err != nil {
+		return string(e.Code) + ": " + e.Function + ": " + e.Message
+	}
+	return string(bytes)
+}
+
+func (e *Error) Add(field string, message string) {
+	e.Fields = append(e.Fields, FieldError{Field: field, Message: message})
+}
+
+func (e *Error) Has(field string) bool {
+	for _, f := range e.Fields {
+		if f.Field == field {
+			return true
+		}
+	}
+	return false
+}
+
+//==============================================================================================================================
+//	 Result - Returns the Error if any field errors were recorded, otherwise nil.
+//==============================================================================================================================
+func (e *Error) Result() error {
+	if len(e.Fields) == 0 {
+		return nil
+	}
+	return e
+}
+
+//==============================================================================================================================
+//	 Merge - Appends the field errors from err for any field that does not already have an error recorded, so a value
+//			 that failed to parse is not reported a second time by validation. An err that is not an Error is returned
+//			 as it is.
+//==============================================================================================================================
+func (e *Error) Merge(err error) error {
+
+	other, ok := err.(*Error)
+	if !ok {
+		if err != nil {
+			return err
+		}
+		return e.Result()
+	}
+
+	for _, f := range other.Fields {
+		if !e.Has(f.Field) {
+			e.Fields = append(e.Fields, f)
+		}
+	}
+
+	return e.Result()
+}
+
+//==============================================================================================================================
+//	 Wrap - Returns err as an Error of function. An Error without a function gets this one; any other error becomes an
+//			INTERNAL Error with its text as the message.
+//==============================================================================================================================
+func Wrap(function string, err error) error {
+
+	if err == nil {
+		return nil
+	}
+
+	e, ok := err.(*Error)
+	if !ok {
+		return &Error{Code: INTERNAL, Function: function, Message: err.Error()}
+	}
+
+	if e.Function == "" {
+		wrapped := *e
+		wrapped.Function = function
+		return &wrapped
+	}
+
+	return e
+}
+
+//==============================================================================================================================
+//	 CodeOf - Returns the code of err, or INTERNAL if it is not an Error.
+//==============================================================================================================================
+func CodeOf(err error) Code {
+	if e, ok := err.(*Error); ok {
+		return e.Code
+	}
+	return INTERNAL
+}
+
+//==============================================================================================================================
+//	 MessageOf - Returns the message of err without the JSON around it, for use inside another message.
+//==============================================================================================================================
+func MessageOf(err error) string {
+	if e, ok := err.(*Error); ok {
+		return e.Message
+	}
+	return err.Error()
+}
+
+//==============================================================================================================================
+//	 Parse - Decodes the error text returned by a chaincode back into an Error.
+//==============================================================================================================================
+func Parse(text string) (*Error, bool) {
+	var e Error
+	if err := json.Unmarshal([]byte(text), &e); err != nil || e.Code == "" {
+		return nil, false
+	}
+	return &e, true
+}
@@ -0,0 +1,76 @@
+/*
+Copyright IBM Corp 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+
+package ccerror
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestWrap(t *testing.T) {
+
+	tests := []struct {
+		name     string
+		wrapAs   string
+		err      error
+		code     Code
+		function string
+		message  string
+	}{
+		{"plain error",        "get_supplyItems", errors.New("Unable to query the ledger"), INTERNAL,         "get_supplyItems", "Unable to query the ledger"},
+		{"function filled in", "get_participant", New(NOT_FOUND, "No participant p"),       NOT_FOUND,        "get_participant", "No participant p"},
+		{"function kept",      "get_participant", Invalid("parse"),                         INVALID_ARGUMENT, "parse",           "Invalid arguments"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := Wrap(tc.wrapAs, tc.err)
+
+			e, ok := Parse(err.Error())
+			if !ok { t.Fatalf("cannot parse %s", err) }
+			if e.Code != tc.code || e.Function != tc.function || e.Message != tc.message { t.Fatalf("got %+v", e) }
+			if CodeOf(err) != tc.code { t.Fatalf("CodeOf is %s", CodeOf(err)) }
+		})
+	}
+
+	if Wrap("f", nil) != nil { t.Fatal("Wrap(nil) is not nil") }
+}
+
+func TestFields(t *testing.T) {
+
+	verr := Invalid("create_supplyItem")
+	if verr.Result() != nil { t.Fatal("an Error without fields is returned") }
+
+	verr.Add("latitude", "Must be between -90 and 90")
+
+	other := Invalid("create_supplyItem")
+	other.Add("latitude", "Required")
+	other.Add("ownerID", "Required")
+
+	err := verr.Merge(other)
+
+	e, ok := Parse(err.Error())
+	if !ok { t.Fatalf("cannot parse %s", err) }
+	if len(e.Fields) != 2 || e.Fields[0].Message != "Must be between -90 and 90" || e.Fields[1].Field != "ownerID" { t.Fatalf("merged %+v", e.Fields) }
+
+	plain := errors.New("Unable to query the ledger")
+	if verr.Merge(plain) != plain { t.Fatal("Merge does not pass other errors through") }
+
+	if MessageOf(New(CONFLICT, "Participant p is suspended")) != "Participant p is suspended" { t.Fatal("MessageOf returned the JSON") }
+	if _, ok := Parse("Permission Denied. get_supplyItem"); ok { t.Fatal("parsed a plain message") }
+}
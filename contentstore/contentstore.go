@@ -0,0 +1,84 @@
+/*
+Copyright IBM Corp 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+//==============================================================================================================================
+//	Package contentstore keeps the content of SupplyItem photos and attachments off the ledger. A client stores the
+//	content with Put and passes the returned Ref to the chaincode as the attachment; anyone holding the content can
+//	check it against the digest on the ledger with Verify or the chaincode's verify_attachment query.
+//==============================================================================================================================
+package contentstore
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+)
+
+//==============================================================================================================================
+//	Ref - Where content is stored and what it is. Serialises to the attachment JSON object the chaincode accepts.
+//==============================================================================================================================
+type Ref struct {
+	URI       string `json:"uri"`
+	SHA256    string `json:"sha256"`
+	MediaType string `json:"mediaType"`
+	Size      int64  `json:"size"`
+}
+
+//==============================================================================================================================
+//	Store - A backend that content can be written to and read back from by URI.
+//==============================================================================================================================
+type Store interface {
+	Put(r io.Reader, mediaType string) (Ref, error)
+	Open(uri string) (io.ReadCloser, error)
+}
+
+var ErrNotFound = errors.New("contentstore: no content at that URI")
+
+//==============================================================================================================================
+//	 Digest - Returns the lower case hex SHA-256 digest and the length of everything read from r.
+//==============================================================================================================================
+func Digest(r io.Reader) (string, int64, error) {
+	h := sha256.New()
+	n, err := io.Copy(h, r)
+	if err != nil {
+		return "", n, err
+	}
+	return hex.EncodeToString(h.Sum(nil)), n, nil
+}
+
+//==============================================================================================================================
+//	 Verify - Reports whether the content read from r has the digest and size recorded in ref.
+//==============================================================================================================================
+func Verify(r io.Reader, ref Ref) (bool, error) {
+	digest, n, err := Digest(r)
+	if err != nil {
+		return false, err
+	}
+	return digest == ref.SHA256 && n == ref.Size, nil
+}
+
+//==============================================================================================================================
+//	 VerifyURI - Reads the content at ref.URI from the store and verifies it against ref.
+//==============================================================================================================================
+func VerifyURI(s Store, ref Ref) (bool, error) {
+	rc, err := s.Open(ref.URI)
+	if err != nil {
+		return false, err
+	}
+	defer rc.Close()
+	return Verify(rc, ref)
+}
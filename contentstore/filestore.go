@@ -0,0 +1,130 @@
+/*
+Copyright IBM Corp 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package contentstore
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+//==============================================================================================================================
+//	FileStore - The reference Store, keeping content in a local directory. Content is addressed by its digest: it is
+//				written to Root/<first two hex digits>/<digest>, so storing the same content twice keeps one copy.
+//				URIs are file:// URIs of those paths.
+//==============================================================================================================================
+type FileStore struct {
+	Root string
+}
+
+//==============================================================================================================================
+//	 NewFileStore - Returns a FileStore rooted at dir, creating the directory if needed.
+//==============================================================================================================================
+func NewFileStore(dir string) (*FileStore, error) {
+	root, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(root, 0755); err != nil {
+		return nil, err
+	}
+	root, err = filepath.EvalSymlinks(root)
+	if err != nil {
+		return nil, err
+	}
+	return &FileStore{Root: root}, nil
+}
+
+func (s *FileStore) path(digest string) string {
+	return filepath.Join(s.Root, digest[:2], digest)
+}
+
+//==============================================================================================================================
+//	 Put - Copies r into the store. The content is written to a temporary file while its digest is computed and then
+//		   moved into place, so a reader never sees part of it.
+//==============================================================================================================================
+func (s *FileStore) Put(r io.Reader, mediaType string) (Ref, error) {
+
+	tmp, err := ioutil.TempFile(s.Root, ".put-")
+	if err != nil {
+		return Ref{}, err
+	}
+	defer os.Remove(tmp.Name())
+
+	h := sha256.New()
+	n, err := io.Copy(io.MultiWriter(tmp, h), r)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return Ref{}, err
+	}
+
+	digest := hex.EncodeToString(h.Sum(nil))
+	path := s.path(digest)
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return Ref{}, err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return Ref{}, err
+	}
+
+	u := url.URL{Scheme: "file", Path: filepath.ToSlash(path)}
+	return Ref{URI: u.String(), SHA256: digest, MediaType: mediaType, Size: n}, nil
+}
+
+//==============================================================================================================================
+//	 Open - Returns the content at a file:// URI returned by Put. URIs outside the store's root are refused, including
+//			those that only reach outside it through a symbolic link.
+//==============================================================================================================================
+func (s *FileStore) Open(uri string) (io.ReadCloser, error) {
+
+	u, err := url.Parse(uri)
+	if err != nil || u.Scheme != "file" {
+		return nil, errors.New("contentstore: not a file URI: " + uri)
+	}
+
+	root, err := filepath.EvalSymlinks(s.Root)
+	if err != nil {
+		return nil, err
+	}
+
+	path := filepath.Clean(filepath.FromSlash(u.Path))
+	if !strings.HasPrefix(path, s.Root+string(filepath.Separator)) && !strings.HasPrefix(path, root+string(filepath.Separator)) {
+		return nil, errors.New("contentstore: URI is outside the store: " + uri)
+	}
+
+	path, err = filepath.EvalSymlinks(path)
+	if os.IsNotExist(err) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(path, root+string(filepath.Separator)) {
+		return nil, errors.New("contentstore: URI is outside the store: " + uri)
+	}
+
+	return os.Open(path)
+}
@@ -0,0 +1,153 @@
+/*
+Copyright IBM Corp 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+
+package contentstore
+
+import (
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func new_test_store(t *testing.T) (*FileStore, string) {
+	dir, err := ioutil.TempDir("", "contentstore")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := NewFileStore(filepath.Join(dir, "store"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s, dir
+}
+
+func file_uri(path string) string {
+	u := url.URL{Scheme: "file", Path: filepath.ToSlash(path)}
+	return u.String()
+}
+
+func TestPutOpen(t *testing.T) {
+
+	s, dir := new_test_store(t)
+	defer os.RemoveAll(dir)
+
+	ref, err := s.Put(strings.NewReader("hello"), "text/plain")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ref.SHA256 != "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824" || ref.Size != 5 || ref.MediaType != "text/plain" {
+		t.Errorf("ref %+v", ref)
+	}
+
+	rc, err := s.Open(ref.URI)
+	if err != nil {
+		t.Fatal(err)
+	}
+	content, err := ioutil.ReadAll(rc)
+	rc.Close()
+	if err != nil || string(content) != "hello" {
+		t.Errorf("read back %q, %v", content, err)
+	}
+
+	again, err := s.Put(strings.NewReader("hello"), "text/plain")
+	if err != nil || again.URI != ref.URI {
+		t.Errorf("storing the same content again gave %+v, %v", again, err)
+	}
+
+	if ok, err := VerifyURI(s, ref); !ok || err != nil {
+		t.Errorf("VerifyURI = %v, %v", ok, err)
+	}
+}
+
+func TestVerifyMismatch(t *testing.T) {
+
+	s, dir := new_test_store(t)
+	defer os.RemoveAll(dir)
+
+	ref, err := s.Put(strings.NewReader("hello"), "text/plain")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		content string
+		ref     Ref
+	}{
+		{"different content", "hellO", ref},
+		{"different digest",  "hello", Ref{URI: ref.URI, SHA256: strings.Repeat("0", 64), Size: ref.Size}},
+		{"different size",    "hello", Ref{URI: ref.URI, SHA256: ref.SHA256, Size: 4}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if ok, err := Verify(strings.NewReader(tc.content), tc.ref); ok || err != nil {
+				t.Errorf("Verify = %v, %v", ok, err)
+			}
+		})
+	}
+
+	tampered := ref
+	tampered.SHA256 = strings.Repeat("0", 64)
+	if ok, err := VerifyURI(s, tampered); ok || err != nil {
+		t.Errorf("VerifyURI of a tampered ref = %v, %v", ok, err)
+	}
+}
+
+func TestOpenOutsideStore(t *testing.T) {
+
+	s, dir := new_test_store(t)
+	defer os.RemoveAll(dir)
+
+	secret := filepath.Join(dir, "secret")
+	if err := ioutil.WriteFile(secret, []byte("secret"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(secret, filepath.Join(s.Root, "link")); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		uri  string
+	}{
+		{"absolute path",  file_uri(secret)},
+		{"dot dot",        "file://" + filepath.ToSlash(s.Root) + "/../secret"},
+		{"symbolic link",  file_uri(filepath.Join(s.Root, "link"))},
+		{"not a file uri", "https://example.com/" + strings.Repeat("0", 64)},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			rc, err := s.Open(tc.uri)
+			if err == nil {
+				rc.Close()
+				t.Fatalf("opened %s", tc.uri)
+			}
+			if err == ErrNotFound {
+				t.Fatalf("reported %s as missing instead of refusing it", tc.uri)
+			}
+		})
+	}
+
+	if _, err := s.Open(file_uri(filepath.Join(s.Root, "00", strings.Repeat("0", 64)))); err != ErrNotFound {
+		t.Errorf("missing content gave %v", err)
+	}
+}
@@ -21,6 +21,7 @@ import (
 	"fmt"
 
 	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/vsagineedu/learn-chaincode/ccerror"
 )
 
 // SimpleChaincode example simple Chaincode implementation
@@ -35,12 +36,14 @@ func main() {
 }
 
 // Init resets all the things
-func (t *SimpleChaincode) Init(stub shim.ChaincodeStubInterface, function string, args []string) ([]byte, error) {
+func (t *SimpleChaincode) Init(stub shim.ChaincodeStubInterface, function string, args []string) (result []byte, err error) {
+	defer func() { err = ccerror.Wrap("init", err) }()
+
 	if len(args) != 1 {
-		return nil, errors.New("Incorrect number of arguments. Expecting 1")
+		return nil, ccerror.New(ccerror.INVALID_ARGUMENT, "Incorrect number of arguments. Expecting 1")
 	}
 
-	err := stub.PutState("hello_world", []byte(args[0]))
+	err = stub.PutState("hello_world", []byte(args[0]))
 	if err != nil {
 		return nil, err
 	}
@@ -48,8 +51,10 @@ func (t *SimpleChaincode) Init(stub shim.ChaincodeStubInterface, function string
 	return nil, nil
 }
 
-// Invoke isur entry point to invoke a chaincode function
-func (t *SimpleChaincode) Invoke(stub shim.ChaincodeStubInterface, function string, args []string) ([]byte, error) {
+// Invoke isur entry point to invoke a chaincode function. Any error is returned as a ccerror.Error of the function.
+func (t *SimpleChaincode) Invoke(stub shim.ChaincodeStubInterface, function string, args []string) (result []byte, err error) {
+	defer func() { err = ccerror.Wrap(function, err) }()
+
 	fmt.Println("invoke is running " + function)
 
 	// Handle different functions
@@ -60,11 +65,13 @@ func (t *SimpleChaincode) Invoke(stub shim.ChaincodeStubInterface, function stri
 	}
 	fmt.Println("invoke did not find func: " + function)
 
-	return nil, errors.New("Received unknown function invocation: " + function)
+	return nil, ccerror.New(ccerror.INVALID_ARGUMENT, "Received unknown function invocation: " + function)
 }
 
-// Query is our entry point for queries
-func (t *SimpleChaincode) Query(stub shim.ChaincodeStubInterface, function string, args []string) ([]byte, error) {
+// Query is our entry point for queries. Any error is returned as a ccerror.Error of the function.
+func (t *SimpleChaincode) Query(stub shim.ChaincodeStubInterface, function string, args []string) (result []byte, err error) {
+	defer func() { err = ccerror.Wrap(function, err) }()
+
 	fmt.Println("query is running " + function)
 
 	// Handle different functions
@@ -73,7 +80,7 @@ func (t *SimpleChaincode) Query(stub shim.ChaincodeStubInterface, function strin
 	}
 	fmt.Println("query did not find func: " + function)
 
-	return nil, errors.New("Received unknown function query: " + function)
+	return nil, ccerror.New(ccerror.INVALID_ARGUMENT, "Received unknown function query: " + function)
 }
 
 // write - invoke function to write key/value pair
@@ -83,7 +90,7 @@ func (t *SimpleChaincode) write(stub shim.ChaincodeStubInterface, args []string)
 	fmt.Println("running write()")
 
 	if len(args) != 2 {
-		return nil, errors.New("Incorrect number of arguments. Expecting 2. name of the key and value to set")
+		return nil, ccerror.New(ccerror.INVALID_ARGUMENT, "Incorrect number of arguments. Expecting 2. name of the key and value to set")
 	}
 
 	key = args[0] //rename for funsies
@@ -97,18 +104,17 @@ func (t *SimpleChaincode) write(stub shim.ChaincodeStubInterface, args []string)
 
 // read - query function to read key/value pair
 func (t *SimpleChaincode) read(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
-	var key, jsonResp string
+	var key string
 	var err error
 
 	if len(args) != 1 {
-		return nil, errors.New("Incorrect number of arguments. Expecting name of the key to query")
+		return nil, ccerror.New(ccerror.INVALID_ARGUMENT, "Incorrect number of arguments. Expecting name of the key to query")
 	}
 
 	key = args[0]
 	valAsbytes, err := stub.GetState(key)
 	if err != nil {
-		jsonResp = "{\"Error\":\"Failed to get state for " + key + "\"}"
-		return nil, errors.New(jsonResp)
+		return nil, errors.New("Failed to get state for " + key)
 	}
 
 	return valAsbytes, nil
@@ -17,10 +17,10 @@ limitations under the License.
 package main
 
 import (
-	"errors"
 	"fmt"
 
 	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/vsagineedu/learn-chaincode/ccerror"
 )
 
 // SimpleChaincode example simple Chaincode implementation
@@ -38,16 +38,20 @@ func main() {
 }
 
 // Init resets all the things
-func (t *SimpleChaincode) Init(stub shim.ChaincodeStubInterface, function string, args []string) ([]byte, error) {
+func (t *SimpleChaincode) Init(stub shim.ChaincodeStubInterface, function string, args []string) (result []byte, err error) {
+	defer func() { err = ccerror.Wrap("init", err) }()
+
 	if len(args) != 1 {
-		return nil, errors.New("Incorrect number of arguments. Expecting 1")
+		return nil, ccerror.New(ccerror.INVALID_ARGUMENT, "Incorrect number of arguments. Expecting 1")
 	}
 
 	return nil, nil
 }
 
-// Invoke is our entry point to invoke a chaincode function
-func (t *SimpleChaincode) Invoke(stub shim.ChaincodeStubInterface, function string, args []string) ([]byte, error) {
+// Invoke is our entry point to invoke a chaincode function. Any error is returned as a ccerror.Error of the function.
+func (t *SimpleChaincode) Invoke(stub shim.ChaincodeStubInterface, function string, args []string) (result []byte, err error) {
+	defer func() { err = ccerror.Wrap(function, err) }()
+
 	fmt.Println("invoke is running " + function)
 
 	// Handle different functions
@@ -56,11 +60,13 @@ func (t *SimpleChaincode) Invoke(stub shim.ChaincodeStubInterface, function stri
 	}
 	fmt.Println("invoke did not find func: " + function)					//error
 
-	return nil, errors.New("Received unknown function invocation: " + function)
+	return nil, ccerror.New(ccerror.INVALID_ARGUMENT, "Received unknown function invocation: " + function)
 }
 
-// Query is our entry point for queries
-func (t *SimpleChaincode) Query(stub shim.ChaincodeStubInterface, function string, args []string) ([]byte, error) {
+// Query is our entry point for queries. Any error is returned as a ccerror.Error of the function.
+func (t *SimpleChaincode) Query(stub shim.ChaincodeStubInterface, function string, args []string) (result []byte, err error) {
+	defer func() { err = ccerror.Wrap(function, err) }()
+
 	fmt.Println("query is running " + function)
 
 	// Handle different functions
@@ -70,5 +76,5 @@ func (t *SimpleChaincode) Query(stub shim.ChaincodeStubInterface, function strin
 	}
 	fmt.Println("query did not find func: " + function)						//error
 
-	return nil, errors.New("Received unknown function query: " + function)
+	return nil, ccerror.New(ccerror.INVALID_ARGUMENT, "Received unknown function query: " + function)
 }
@@ -0,0 +1,418 @@
+/*
+Copyright IBM Corp 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package supplychain
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/vsagineedu/learn-chaincode/ccerror"
+)
+
+//==============================================================================================================================
+//	Running totals - The aggregation queries read totals kept up to date by save_changes instead of scanning SupplyItems.
+//
+//	Holdings	   - A HoldingTotal under (HOLDING_KEY_TYPE, ownerID, materialType, unitOfMeasure, status, cell) sums
+//					 the SupplyItems in that group, where cell is the HOLDING_GEOHASH_PRECISION character geohash of
+//					 their position. What each SupplyItem last added is kept under (HOLDING_ITEM_KEY_TYPE, id) so it
+//					 can be taken off again when the SupplyItem changes, which also makes reindexing safe to repeat.
+//	Supply volumes - A VolumeTotal under (VOLUME_KEY_TYPE, day, supplierID, unitOfMeasure) sums the SupplyItems the
+//					 supplier created on that UTC day. Day comes first so a time window is one range query.
+//					 (VOLUME_ITEM_KEY_TYPE, id) marks a SupplyItem already counted.
+//==============================================================================================================================
+const HOLDING_KEY_TYPE = "holding"
+const HOLDING_ITEM_KEY_TYPE = "holdingItem"
+const VOLUME_KEY_TYPE = "dailyVolume"
+const VOLUME_ITEM_KEY_TYPE = "dailyVolumeItem"
+
+const HOLDING_GEOHASH_PRECISION = 4				// About 39km x 20km at the equator
+const VOLUME_DAY_FORMAT = "2006-01-02"
+
+type HoldingTotal struct {
+	MaterialQty   Quantity `json:"materialQuantity"`
+	NormalizedQty Quantity `json:"normalizedQuantity"`
+	Count         int      `json:"count"`
+}
+
+//==============================================================================================================================
+//	HoldingItem - The holding a SupplyItem was last added to and the amounts it added.
+//==============================================================================================================================
+type HoldingItem struct {
+	Key           string   `json:"key"`
+	MaterialQty   Quantity `json:"materialQuantity"`
+	NormalizedQty Quantity `json:"normalizedQuantity"`
+}
+
+type VolumeTotal struct {
+	MaterialQty   Quantity      `json:"materialQuantity"`
+	NormalizedQty Quantity      `json:"normalizedQuantity"`
+	BaseUnit      UnitOfMeasure `json:"baseUnit"`
+	Count         int           `json:"count"`
+}
+
+//==============================================================================================================================
+//	HoldingFilter - The argument of get_holdings. Every non-empty field must match. Geohash is a prefix of at most
+//					HOLDING_GEOHASH_PRECISION characters selecting the area the SupplyItems are in. SupplyItems in
+//					terminal_statuses are left out unless IncludeTerminal is set or Status names one.
+//	Holding		  - One row of the response of get_holdings.
+//==============================================================================================================================
+type HoldingFilter struct {
+	OwnerID         string           `json:"ownerID"`
+	MaterialType    string           `json:"materialType"`
+	Status          SupplyItemStatus `json:"status"`
+	Geohash         string           `json:"geohash"`
+	IncludeTerminal bool             `json:"includeTerminal"`
+}
+
+type Holding struct {
+	OwnerID       string        `json:"ownerID"`
+	MaterialType  string        `json:"materialType"`
+	UnitOfMeasure UnitOfMeasure `json:"unitOfMeasure"`
+	MaterialQty   Quantity      `json:"materialQuantity"`
+	NormalizedQty Quantity      `json:"normalizedQuantity"`
+	BaseUnit      UnitOfMeasure `json:"baseUnit"`
+	Count         int           `json:"count"`
+}
+
+//==============================================================================================================================
+//	SupplierVolume - One row of the response of get_supplier_volume: what a supplier created in one unit.
+//==============================================================================================================================
+type SupplierVolume struct {
+	SupplierID    string        `json:"supplierID"`
+	UnitOfMeasure UnitOfMeasure `json:"unitOfMeasure"`
+	MaterialQty   Quantity      `json:"materialQuantity"`
+	NormalizedQty Quantity      `json:"normalizedQuantity"`
+	BaseUnit      UnitOfMeasure `json:"baseUnit"`
+	Count         int           `json:"count"`
+}
+
+//==============================================================================================================================
+//	 get_record / put_record - Read and write a JSON record, reporting whether get_record found one. put_record deletes
+//							   the key when remove is set.
+//==============================================================================================================================
+func get_record(stub Stub, key string, record interface{}) (bool, error) {
+
+	bytes, err := stub.GetState(key)
+	if err != nil { return false, errors.New("Unable to query the ledger") }
+	if bytes == nil { return false, nil }
+
+	err = json.Unmarshal(bytes, record)
+	if err != nil { return false, ccerror.New(ccerror.CORRUPT_RECORD, "Corrupt record " + string(bytes)) }
+
+	return true, nil
+}
+
+func put_record(stub Stub, key string, record interface{}, remove bool) error {
+
+	if remove { return stub.DelState(key) }
+
+	bytes, err := json.Marshal(record)
+	if err != nil { return errors.New("Error converting record") }
+
+	return stub.PutState(key, bytes)
+}
+
+//==============================================================================================================================
+//	 add_holding - Adds the amounts to the HoldingTotal under key. A total whose count drops to zero is removed.
+//==============================================================================================================================
+func add_holding(stub Stub, key string, qty Quantity, normalized Quantity, count int) error {
+
+	var total HoldingTotal
+	if _, err := get_record(stub, key, &total); err != nil { return err }
+
+	total.MaterialQty += qty
+	total.NormalizedQty += normalized
+	total.Count += count
+
+	return put_record(stub, key, total, total.Count <= 0)
+}
+
+//==============================================================================================================================
+//	 update_holdings - Moves the amounts a SupplyItem adds to the holding totals from the group it was last counted in
+//					   to the group it is in now. SupplyItems holding nothing are not counted.
+//==============================================================================================================================
+func update_holdings(stub Stub, sItem SupplyItem) error {
+
+	itemKey, err := create_composite_key(HOLDING_ITEM_KEY_TYPE, []string{sItem.SupplyItemID})
+	if err != nil { return err }
+
+	var last HoldingItem
+	counted, err := get_record(stub, itemKey, &last)
+	if err != nil { return err }
+
+	current := HoldingItem{MaterialQty: sItem.MaterialQty, NormalizedQty: sItem.NormalizedQty}
+	if sItem.MaterialQty != 0 {
+		current.Key, err = create_composite_key(HOLDING_KEY_TYPE, []string{sItem.OwnerID, sItem.MaterialType, string(sItem.UnitOfMeasure), string(current_status(sItem)), encode_geohash(sItem.Latitude, sItem.Longitude, HOLDING_GEOHASH_PRECISION)})
+		if err != nil { return err }
+	}
+
+	if counted && last == current { return nil }
+
+	if counted {
+		err = add_holding(stub, last.Key, -last.MaterialQty, -last.NormalizedQty, -1)
+		if err != nil { return errors.New("Unable to update holding totals for " + sItem.SupplyItemID) }
+	}
+
+	if current.Key != "" {
+		err = add_holding(stub, current.Key, current.MaterialQty, current.NormalizedQty, 1)
+		if err != nil { return errors.New("Unable to update holding totals for " + sItem.SupplyItemID) }
+	}
+
+	err = put_record(stub, itemKey, current, current.Key == "")
+	if err != nil { return errors.New("Unable to update holding totals for " + sItem.SupplyItemID) }
+
+	return nil
+}
+
+//==============================================================================================================================
+//	 add_supply_volume - Adds a SupplyItem created by a supplier to the supplier's volume for the day it was created,
+//						 unless it has already been counted.
+//==============================================================================================================================
+func (t *Chaincode) add_supply_volume(stub Stub, sItem SupplyItem, created time.Time) error {
+
+	itemKey, err := create_composite_key(VOLUME_ITEM_KEY_TYPE, []string{sItem.SupplyItemID})
+	if err != nil { return err }
+
+	marker, err := stub.GetState(itemKey)
+	if err != nil { return errors.New("Unable to query the ledger") }
+	if marker != nil { return nil }
+
+	err = t.normalize_supplyItem(stub, &sItem)
+	if err != nil { return err }
+
+	key, err := create_composite_key(VOLUME_KEY_TYPE, []string{created.UTC().Format(VOLUME_DAY_FORMAT), sItem.SupplierID, string(sItem.UnitOfMeasure)})
+	if err != nil { return err }
+
+	var total VolumeTotal
+	if _, err := get_record(stub, key, &total); err != nil { return err }
+
+	total.MaterialQty += sItem.MaterialQty
+	total.NormalizedQty += sItem.NormalizedQty
+	total.BaseUnit = sItem.BaseUnit
+	total.Count++
+
+	err = put_record(stub, key, total, false)
+	if err != nil { return errors.New("Unable to update supply volume for " + sItem.SupplierID) }
+
+	err = stub.PutState(itemKey, index_value)
+	if err != nil { return errors.New("Unable to update supply volume for " + sItem.SupplierID) }
+
+	return nil
+}
+
+//==============================================================================================================================
+//	 backfill_supply_volume - Counts a SupplyItem created before supply volumes were kept, using the time, quantity and
+//							  unit recorded by its create_supplyItem HistoryEntry. SupplyItems without one are skipped.
+//==============================================================================================================================
+func (t *Chaincode) backfill_supply_volume(stub Stub, sItem SupplyItem) error {
+
+	key, err := history_key(sItem.SupplyItemID, 1)
+	if err != nil { return err }
+
+	var entry HistoryEntry
+	found, err := get_record(stub, key, &entry)
+	if err != nil { return err }
+	if !found || entry.Action != "create_supplyItem" { return nil }
+
+	created, err := time.Parse(time.RFC3339Nano, entry.Timestamp)
+	if err != nil { return ccerror.New(ccerror.CORRUPT_RECORD, "Corrupt history record for " + sItem.SupplyItemID) }
+
+	for _, c := range entry.Changes {
+		var err error
+		if c.Field == "materialQuantity" { err = json.Unmarshal(c.After, &sItem.MaterialQty) }
+		if c.Field == "unitOfMeasure" { err = json.Unmarshal(c.After, &sItem.UnitOfMeasure) }
+		if err != nil { return ccerror.New(ccerror.CORRUPT_RECORD, "Corrupt history record for " + sItem.SupplyItemID) }
+	}
+
+	return t.add_supply_volume(stub, sItem, created)
+}
+
+//=================================================================================================================================
+//	 get_holdings - Returns the total quantity held per owner, material type and unit, summed over the statuses and
+//					areas selected by the filter. Recalled and Destroyed SupplyItems are left out by default. Callers
+//					without a ReadAll role only see their own holdings.
+//=================================================================================================================================
+func (t *Chaincode) get_holdings(stub Stub, caller string, args []string) ([]byte, error) {
+
+	//Args
+	//		0
+	//	filter JSON object of {ownerID, materialType, status, geohash, includeTerminal} (optional)
+
+	if len(args) > 1 { return nil, ccerror.New(ccerror.INVALID_ARGUMENT, "GET_HOLDINGS: Incorrect number of arguments. Expecting an optional filter JSON object") }
+
+	var filter HoldingFilter
+	if len(args) == 1 && args[0] != "" {
+		err := json.Unmarshal([]byte(args[0]), &filter)
+		if err != nil { return nil, ccerror.New(ccerror.INVALID_ARGUMENT, "GET_HOLDINGS: Invalid filter object: " + err.Error()) }
+	}
+
+	verr := new_validation_error("get_holdings")
+	validate_id(verr, "ownerID", filter.OwnerID, false)
+	if filter.Status != "" && !is_supplyItem_status(filter.Status) { verr.Add("status", "Unknown status "+string(filter.Status)) }
+	if len(filter.Geohash) > HOLDING_GEOHASH_PRECISION { verr.Add("geohash", "Must be at most "+fmt.Sprint(HOLDING_GEOHASH_PRECISION)+" characters") }
+	for _, c := range filter.Geohash {
+		if !strings.ContainsRune(GEOHASH_ALPHABET, c) { verr.Add("geohash", "Not a geohash"); break }
+	}
+	if err := verr.Result(); err != nil { return nil, err }
+
+	if filter.OwnerID != caller && !t.reads_all(stub, caller) {
+		if filter.OwnerID != "" { return nil, permission_denied("get_holdings") }
+		filter.OwnerID = caller
+	}
+
+	attributes := []string{}
+	if filter.OwnerID != "" {
+		attributes = []string{filter.OwnerID}
+		if filter.MaterialType != "" {
+			attributes = append(attributes, filter.MaterialType)
+		}
+	}
+
+	units, err := t.retrieve_units(stub)
+	if err != nil { return nil, err }
+
+	iter, err := range_query_composite_key(stub, HOLDING_KEY_TYPE, attributes)
+	if err != nil { return nil, errors.New("Unable to query the ledger") }
+	defer iter.Close()
+
+	holdings := []Holding{}
+
+	for iter.HasNext() {
+		key, bytes, err := iter.Next()
+		if err != nil { return nil, errors.New("Unable to query the ledger") }
+
+		_, parts, err := split_composite_key(key)
+		if err != nil || len(parts) != 5 { return nil, ccerror.New(ccerror.CORRUPT_RECORD, "Corrupt holding total " + key) }
+
+		owner, materialType, unit, status, cell := parts[0], parts[1], UnitOfMeasure(parts[2]), SupplyItemStatus(parts[3]), parts[4]
+
+		if filter.MaterialType != "" && materialType != filter.MaterialType { continue }
+		if filter.Status != "" && status != filter.Status { continue }
+		if filter.Status == "" && !filter.IncludeTerminal && contains_status(terminal_statuses, status) { continue }
+		if !strings.HasPrefix(cell, filter.Geohash) { continue }
+
+		var total HoldingTotal
+		err = json.Unmarshal(bytes, &total)
+		if err != nil { return nil, ccerror.New(ccerror.CORRUPT_RECORD, "Corrupt holding total " + string(bytes)) }
+
+		n := len(holdings)
+		if n == 0 || holdings[n-1].OwnerID != owner || holdings[n-1].MaterialType != materialType || holdings[n-1].UnitOfMeasure != unit {
+			h := Holding{OwnerID: owner, MaterialType: materialType, UnitOfMeasure: unit}
+			if i := find_unit(units, string(unit)); i >= 0 {
+				h.BaseUnit = base_units[units[i].Dimension]
+			}
+			holdings = append(holdings, h)
+			n++
+		}
+
+		holdings[n-1].MaterialQty += total.MaterialQty
+		holdings[n-1].NormalizedQty += total.NormalizedQty
+		holdings[n-1].Count += total.Count
+	}
+
+	return json.Marshal(holdings)
+}
+
+type by_supplier_unit []SupplierVolume
+
+func (v by_supplier_unit) Len() int      { return len(v) }
+func (v by_supplier_unit) Swap(i, j int) { v[i], v[j] = v[j], v[i] }
+func (v by_supplier_unit) Less(i, j int) bool {
+	if v[i].SupplierID != v[j].SupplierID { return v[i].SupplierID < v[j].SupplierID }
+	return v[i].UnitOfMeasure < v[j].UnitOfMeasure
+}
+
+//=================================================================================================================================
+//	 get_supplier_volume - Returns the quantity each supplier created per unit from one UTC day to another, both included.
+//						   Totals are kept per day, so the bounds are days given as YYYY-MM-DD rather than times.
+//						   Callers without a ReadAll role only see their own volume.
+//=================================================================================================================================
+func (t *Chaincode) get_supplier_volume(stub Stub, caller string, args []string) ([]byte, error) {
+
+	//Args
+	//		0		1			2
+	//	from	to		supplierID (optional)
+
+	if len(args) < 2 || len(args) > 3 { return nil, ccerror.New(ccerror.INVALID_ARGUMENT, "GET_SUPPLIER_VOLUME: Incorrect number of arguments. Expecting from, to and an optional supplierID") }
+
+	supplierID := ""
+	if len(args) == 3 {
+		supplierID = args[2]
+	}
+
+	verr := new_validation_error("get_supplier_volume")
+	from, err := time.Parse(VOLUME_DAY_FORMAT, args[0])
+	if err != nil { verr.Add("from", "Must be a date as YYYY-MM-DD") }
+	to, err := time.Parse(VOLUME_DAY_FORMAT, args[1])
+	if err != nil { verr.Add("to", "Must be a date as YYYY-MM-DD") }
+	if !verr.Has("from") && !verr.Has("to") && to.Before(from) { verr.Add("to", "Must not be before from") }
+	validate_id(verr, "supplierID", supplierID, false)
+	if err := verr.Result(); err != nil { return nil, err }
+
+	if supplierID != caller && !t.reads_all(stub, caller) {
+		if supplierID != "" { return nil, permission_denied("get_supplier_volume") }
+		supplierID = caller
+	}
+
+	startKey, err := create_composite_key(VOLUME_KEY_TYPE, []string{args[0]})
+	if err != nil { return nil, err }
+	endKey, err := create_composite_key(VOLUME_KEY_TYPE, []string{args[1]})
+	if err != nil { return nil, err }
+
+	iter, err := stub.RangeQueryState(startKey, endKey+MAX_UNICODE_RUNE)
+	if err != nil { return nil, errors.New("Unable to query the ledger") }
+	defer iter.Close()
+
+	totals := map[string]*SupplierVolume{}
+	volumes := []SupplierVolume{}
+
+	for iter.HasNext() {
+		key, bytes, err := iter.Next()
+		if err != nil { return nil, errors.New("Unable to query the ledger") }
+
+		_, parts, err := split_composite_key(key)
+		if err != nil || len(parts) != 3 { return nil, ccerror.New(ccerror.CORRUPT_RECORD, "Corrupt supply volume " + key) }
+
+		supplier, unit := parts[1], UnitOfMeasure(parts[2])
+		if supplierID != "" && supplier != supplierID { continue }
+
+		var total VolumeTotal
+		err = json.Unmarshal(bytes, &total)
+		if err != nil { return nil, ccerror.New(ccerror.CORRUPT_RECORD, "Corrupt supply volume " + string(bytes)) }
+
+		v, ok := totals[supplier+COMPOSITE_KEY_SEPARATOR+string(unit)]
+		if !ok {
+			v = &SupplierVolume{SupplierID: supplier, UnitOfMeasure: unit, BaseUnit: total.BaseUnit}
+			totals[supplier+COMPOSITE_KEY_SEPARATOR+string(unit)] = v
+		}
+
+		v.MaterialQty += total.MaterialQty
+		v.NormalizedQty += total.NormalizedQty
+		v.Count += total.Count
+	}
+
+	for _, v := range totals {
+		volumes = append(volumes, *v)
+	}
+	sort.Sort(by_supplier_unit(volumes))
+
+	return json.Marshal(volumes)
+}
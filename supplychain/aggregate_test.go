@@ -0,0 +1,114 @@
+/*
+Copyright IBM Corp 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+
+package supplychain
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestGetHoldings(t *testing.T) {
+
+	tests := []struct {
+		name   string
+		caller string
+		filter string
+		count  int
+		qty    string
+		err    string
+	}{
+		{"terminal statuses left out", TEST_AUDITOR, ``,                                 2, "14.5", ""},
+		{"including terminal",         TEST_AUDITOR, `{"includeTerminal":true}`,         3, "15.5", ""},
+		{"recalled only",              TEST_AUDITOR, `{"status":"Recalled"}`,            1, "1",    ""},
+		{"own holdings",               TEST_OWNER,   `{"ownerID":"` + TEST_OWNER + `"}`, 2, "14.5", ""},
+		{"another owner's holdings",   "owner2",     `{"ownerID":"` + TEST_OWNER + `"}`, 0, "",     `"code":"PERMISSION_DENIED"`},
+		{"unknown status",             TEST_AUDITOR, `{"status":"Lost"}`,                0, "",     `"field":"status"`},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			l := new_ledger(t)
+			l.register("owner2", ROLE_OWNER)
+			l.create(a_supplyItem("A1"))
+			l.create(a_supplyItem("A2").quantity(2))
+			l.create(a_supplyItem("A3").quantity(1))
+			if _, err := l.invoke(TEST_OWNER, "update_status", "A3", string(STATUS_RECALLED)); err != nil { t.Fatal(err) }
+
+			bytes, err := l.query(tc.caller, "get_holdings", tc.filter)
+			check_error(t, err, tc.err)
+			if tc.err != "" { return }
+
+			var holdings []Holding
+			if err := json.Unmarshal(bytes, &holdings); err != nil { t.Fatal(err) }
+			if len(holdings) != 1 || holdings[0].Count != tc.count || holdings[0].MaterialQty.String() != tc.qty {
+				t.Errorf("holdings %s", bytes)
+			}
+		})
+	}
+}
+
+func TestGetSupplierVolume(t *testing.T) {
+
+	l := new_ledger(t)
+	l.register("supplier2", ROLE_SUPPLIER)
+
+	day := 24 * time.Hour
+	l.create(a_supplyItem("D0").quantity(1))
+	l.now = l.now.Add(day)
+	l.create(a_supplyItem("D1").quantity(2))
+	if _, err := l.invoke("supplier2", "create_supplyItem", a_supplyItem("E1").with("supplierID", "supplier2").quantity(4).json()); err != nil { t.Fatal(err) }
+	l.now = l.now.Add(day)
+	l.create(a_supplyItem("D2").quantity(8))
+
+	tests := []struct {
+		name   string
+		caller string
+		args   []string
+		want   map[string]string
+		err    string
+	}{
+		{"one day",                TEST_AUDITOR,  []string{"1970-01-02", "1970-01-02"},                map[string]string{TEST_SUPPLIER: "2", "supplier2": "4"}, ""},
+		{"two days, one supplier", TEST_AUDITOR,  []string{"1970-01-01", "1970-01-02", TEST_SUPPLIER}, map[string]string{TEST_SUPPLIER: "3"},                   ""},
+		{"own volume",             TEST_SUPPLIER, []string{"1970-01-01", "1970-01-03"},                map[string]string{TEST_SUPPLIER: "11"},                  ""},
+		{"before any supply",      TEST_AUDITOR,  []string{"1969-12-01", "1969-12-31"},                map[string]string{},                                     ""},
+		{"to before from",         TEST_AUDITOR,  []string{"1970-01-02", "1970-01-01"},                nil,                                                     `"field":"to"`},
+		{"time of day",            TEST_AUDITOR,  []string{"1970-01-01", "1970-01-02T12:00:00Z"},      nil,                                                     `"field":"to"`},
+		{"another supplier",       TEST_SUPPLIER, []string{"1970-01-01", "1970-01-03", "supplier2"},   nil,                                                     `"code":"PERMISSION_DENIED"`},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			bytes, err := l.query(tc.caller, "get_supplier_volume", tc.args...)
+			check_error(t, err, tc.err)
+			if tc.err != "" { return }
+
+			var volumes []SupplierVolume
+			if err := json.Unmarshal(bytes, &volumes); err != nil { t.Fatal(err) }
+
+			got := map[string]string{}
+			for _, v := range volumes {
+				got[v.SupplierID] = v.MaterialQty.String()
+			}
+			if len(got) != len(tc.want) { t.Fatalf("volumes %s", bytes) }
+			for supplier, qty := range tc.want {
+				if got[supplier] != qty { t.Errorf("volumes %s", bytes) }
+			}
+		})
+	}
+}
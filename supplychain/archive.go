@@ -0,0 +1,355 @@
+/*
+Copyright IBM Corp 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+
+package supplychain
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/vsagineedu/learn-chaincode/ccerror"
+)
+
+//==============================================================================================================================
+//	Archiving - An archived SupplyItem keeps its state but is left out of listings unless they are asked to include
+//				archived SupplyItems, and no function may change it until it is restored. ArchivedAt records the
+//				transaction time it was archived at in ARCHIVE_TIME_FORMAT, which sorts in time order, and an empty
+//				record is kept under (ARCHIVE_KEY_TYPE, archivedAt, supplyItemID) so purge_archived finds the oldest first.
+//				Once the retention period of the RetentionConfig has passed, purge_archived deletes its state but keeps
+//				its history. SupplyItems that other records refer to are never purged, so walks over them never find
+//				a missing SupplyItem.
+//==============================================================================================================================
+const ARCHIVE_KEY_TYPE = "archived"
+const ARCHIVE_TIME_FORMAT = "2006-01-02T15:04:05.000000000Z"
+
+const RETENTION_CONFIG = "retention"
+const DEFAULT_ARCHIVE_RETENTION_DAYS = 90
+const DEFAULT_PURGE_BATCH = 100
+
+//==============================================================================================================================
+//	RetentionConfig - How many days archived SupplyItems are kept before purge_archived may delete them, changed by
+//					  admins with update_retention_config.
+//==============================================================================================================================
+type RetentionConfig struct {
+	ArchiveRetentionDays int `json:"archiveRetentionDays"`
+}
+
+//==============================================================================================================================
+//	PurgeResult - The response of purge_archived. Retained lists the SupplyItems due to be purged that are kept because
+//				  other records refer to them. More is set if further SupplyItems are due to be purged.
+//==============================================================================================================================
+type PurgeResult struct {
+	Purged   []string `json:"purged"`
+	Retained []string `json:"retained"`
+	More     bool     `json:"more"`
+}
+
+func is_archived(sItem SupplyItem) bool {
+	return sItem.ArchivedAt != ""
+}
+
+func archive_key(sItem SupplyItem) (string, error) {
+	return create_composite_key(ARCHIVE_KEY_TYPE, []string{sItem.ArchivedAt, sItem.SupplyItemID})
+}
+
+//==============================================================================================================================
+//	 is_referenced - Reports whether another record refers to a SupplyItem: the lots it was split or merged from or
+//					 into, the assemblies and components it is linked to, or a recall listing it in recalled.
+//==============================================================================================================================
+func is_referenced(sItem SupplyItem, recalled map[string]bool) bool {
+	return len(sItem.ParentIDs) > 0 || len(sItem.ChildIDs) > 0 || len(sItem.Components) > 0 || len(sItem.UsedInIDs) > 0 || recalled[sItem.SupplyItemID]
+}
+
+//==============================================================================================================================
+//	 update_archive_index - Moves the archive index entry of a SupplyItem that was archived or restored. A nil before
+//							adds the entry of an archived after.
+//==============================================================================================================================
+func update_archive_index(stub Stub, before *SupplyItem, after SupplyItem) error {
+
+	if before != nil && before.ArchivedAt == after.ArchivedAt { return nil }
+
+	if before != nil && is_archived(*before) {
+		key, err := archive_key(*before)
+		if err != nil { return err }
+
+		err = stub.DelState(key)
+		if err != nil { return errors.New("Unable to remove archive index entry for " + after.SupplyItemID) }
+	}
+
+	if is_archived(after) {
+		key, err := archive_key(after)
+		if err != nil { return err }
+
+		err = stub.PutState(key, index_value)
+		if err != nil { return errors.New("Unable to store archive index entry for " + after.SupplyItemID) }
+	}
+
+	return nil
+}
+
+//=================================================================================================================================
+//	 archive_supplyItem - Archives a SupplyItem. Only the owner may archive it, and not while a transfer is pending.
+//=================================================================================================================================
+func (t *Chaincode) archive_supplyItem(stub Stub, caller string, args []string) ([]byte, error) {
+
+	//Args
+	//		0
+	//	supplyItemID
+
+	sItem, err := t.retrieve_SupplyItem(stub, args[0])
+	if err != nil { return nil, err }
+
+	if sItem.OwnerID != caller { return nil, permission_denied("archive_supplyItem") }
+
+	if is_archived(sItem) { return nil, ccerror.New(ccerror.CONFLICT, "SupplyItem " + sItem.SupplyItemID + " is already archived") }
+
+	iter, err := range_query_composite_key(stub, TRANSFER_KEY_TYPE, []string{sItem.SupplyItemID})
+	if err != nil { return nil, errors.New("Unable to query pending transfers") }
+	pending := iter.HasNext()
+	iter.Close()
+
+	if pending { return nil, ccerror.New(ccerror.CONFLICT, "SupplyItem " + sItem.SupplyItemID + " has a pending transfer") }
+
+	txTime, err := get_tx_time(stub)
+	if err != nil { return nil, err }
+
+	sItem.ArchivedAt = txTime.Format(ARCHIVE_TIME_FORMAT)
+
+	_, err = t.save_changes(stub, sItem, caller, "archive_supplyItem")
+	if err != nil { fmt.Printf("ARCHIVE_SUPPLYITEM: Error saving changes: %s", err); return nil, err }
+
+	return nil, nil
+}
+
+//=================================================================================================================================
+//	 restore_supplyItem - Returns an archived SupplyItem to the listings. Only the owner may restore it.
+//=================================================================================================================================
+func (t *Chaincode) restore_supplyItem(stub Stub, caller string, args []string) ([]byte, error) {
+
+	//Args
+	//		0
+	//	supplyItemID
+
+	sItem, err := t.retrieve_SupplyItem(stub, args[0])
+	if err != nil { return nil, err }
+
+	if sItem.OwnerID != caller { return nil, permission_denied("restore_supplyItem") }
+
+	if !is_archived(sItem) { return nil, ccerror.New(ccerror.CONFLICT, "SupplyItem " + sItem.SupplyItemID + " is not archived") }
+
+	sItem.ArchivedAt = ""
+
+	_, err = t.save_changes(stub, sItem, caller, "restore_supplyItem")
+	if err != nil { fmt.Printf("RESTORE_SUPPLYITEM: Error saving changes: %s", err); return nil, err }
+
+	return nil, nil
+}
+
+//=================================================================================================================================
+//	 purge_archived - Deletes the SupplyItems archived longer ago than the retention period, measured from the time of
+//					  this transaction, oldest first. At most batchSize are deleted per call; More in the result says
+//					  whether to call again. Referenced SupplyItems are listed as retained and left archived.
+//=================================================================================================================================
+func (t *Chaincode) purge_archived(stub Stub, caller string, args []string) ([]byte, error) {
+
+	//Args
+	//		0
+	//	batchSize (optional)
+
+	batchSize := DEFAULT_PURGE_BATCH
+	if len(args) > 0 && args[0] != "" {
+		size, err := strconv.Atoi(args[0])
+		if err != nil || size <= 0 { return nil, ccerror.New(ccerror.INVALID_ARGUMENT, "PURGE_ARCHIVED: Batch size must be a positive integer") }
+		batchSize = size
+	}
+
+	config, err := t.retrieve_retention_config(stub)
+	if err != nil { return nil, err }
+
+	txTime, err := get_tx_time(stub)
+	if err != nil { return nil, err }
+
+	cutoff := txTime.AddDate(0, 0, -config.ArchiveRetentionDays).Format(ARCHIVE_TIME_FORMAT)
+
+	recalled, err := recalled_ids(stub)
+	if err != nil { return nil, err }
+
+	result := PurgeResult{Purged: []string{}, Retained: []string{}}
+
+	var due []SupplyItem
+
+	iter, err := range_query_composite_key(stub, ARCHIVE_KEY_TYPE, []string{})
+	if err != nil { return nil, errors.New("Unable to query archived supplyItems") }
+
+	for iter.HasNext() {
+		key, _, err := iter.Next()
+		if err != nil { iter.Close(); return nil, errors.New("Unable to query archived supplyItems") }
+
+		_, parts, err := split_composite_key(key)
+		if err != nil || len(parts) != 2 { iter.Close(); return nil, ccerror.New(ccerror.CORRUPT_RECORD, "Corrupt archive index entry " + key) }
+
+		if parts[0] > cutoff { break }						// The rest were archived more recently
+
+		sItem, err := t.retrieve_SupplyItem(stub, parts[1])
+		if err != nil { iter.Close(); return nil, err }
+
+		if is_referenced(sItem, recalled) { result.Retained = append(result.Retained, sItem.SupplyItemID); continue }
+
+		if len(due) == batchSize { result.More = true; break }
+
+		due = append(due, sItem)
+	}
+	iter.Close()
+
+	for _, sItem := range due {
+		err = t.purge_supplyItem(stub, sItem, caller)
+		if err != nil { fmt.Printf("PURGE_ARCHIVED: Error purging %s: %s", sItem.SupplyItemID, err); return nil, err }
+
+		result.Purged = append(result.Purged, sItem.SupplyItemID)
+	}
+
+	return json.Marshal(result)
+}
+
+//==============================================================================================================================
+//	 purge_supplyItem - Deletes a SupplyItem with its index entries, location trail and pending transfers, and takes it
+//						off the running totals of current holdings. Its history and the supply volumes already counted
+//						are kept, and a last HistoryEntry records the purge.
+//==============================================================================================================================
+func (t *Chaincode) purge_supplyItem(stub Stub, sItem SupplyItem, actor string) error {
+
+	key, err := supplyItem_key(sItem.SupplyItemID)
+	if err != nil { return err }
+
+	err = stub.DelState(key)
+	if err != nil { return errors.New("Unable to delete supplyItem " + sItem.SupplyItemID) }
+
+	err = add_supplyItem_count(stub, sItem.SupplyItemID, -1)
+	if err != nil { return err }
+
+	var keys []string
+
+	for _, field := range indexed_fields {
+		if value := indexed_field_value(sItem, field); value != "" {
+			key, err := create_composite_key(INDEX_KEY_TYPE, []string{field, value, sItem.SupplyItemID})
+			if err != nil { return err }
+			keys = append(keys, key)
+		}
+	}
+
+	geoKey, err := geo_key(sItem)
+	if err != nil { return err }
+
+	archiveKey, err := archive_key(sItem)
+	if err != nil { return err }
+
+	volumeKey, err := create_composite_key(VOLUME_ITEM_KEY_TYPE, []string{sItem.SupplyItemID})
+	if err != nil { return err }
+
+	keys = append(keys, geoKey, archiveKey, volumeKey)
+
+	for _, objectType := range []string{LOCATION_KEY_TYPE, TRANSFER_KEY_TYPE} {
+		iter, err := range_query_composite_key(stub, objectType, []string{sItem.SupplyItemID})
+		if err != nil { return errors.New("Unable to query the ledger") }
+
+		for iter.HasNext() {
+			key, _, err := iter.Next()
+			if err != nil { iter.Close(); return errors.New("Unable to query the ledger") }
+			keys = append(keys, key)
+		}
+		iter.Close()
+	}
+
+	for _, key := range keys {
+		err = stub.DelState(key)
+		if err != nil { return errors.New("Unable to delete the state of supplyItem " + sItem.SupplyItemID) }
+	}
+
+	err = update_holdings(stub, SupplyItem{SupplyItemID: sItem.SupplyItemID})			// A SupplyItem with no quantity holds nothing
+	if err != nil { return err }
+
+	purged := sItem
+	purged.Revision++
+
+	_, err = t.append_history(stub, &sItem, purged, actor, "purge_archived")
+	if err != nil { return err }
+
+	return t.emit_event(stub, SupplyItemEvent{SupplyItemID: sItem.SupplyItemID, Action: "purge_archived", Actor: actor, Revision: purged.Revision})
+}
+
+//==============================================================================================================================
+//	 retrieve_retention_config - Returns the stored RetentionConfig, or the defaults if none has been stored.
+//==============================================================================================================================
+func (t *Chaincode) retrieve_retention_config(stub Stub) (RetentionConfig, error) {
+
+	config := RetentionConfig{ArchiveRetentionDays: DEFAULT_ARCHIVE_RETENTION_DAYS}
+
+	key, err := config_key(RETENTION_CONFIG)
+	if err != nil { return config, err }
+
+	bytes, err := stub.GetState(key)
+	if err != nil { fmt.Printf("RETRIEVE_RETENTION_CONFIG: Failed to get config: %s", err); return config, errors.New("Error retrieving retention config") }
+
+	if bytes == nil { return config, nil }
+
+	err = json.Unmarshal(bytes, &config)
+	if err != nil { return config, ccerror.New(ccerror.CORRUPT_RECORD, "Corrupt retention config record " + string(bytes)) }
+
+	return config, nil
+}
+
+//=================================================================================================================================
+//	 update_retention_config - Replaces the RetentionConfig.
+//=================================================================================================================================
+func (t *Chaincode) update_retention_config(stub Stub, caller string, args []string) ([]byte, error) {
+
+	//Args
+	//		0
+	//	config JSON object of {archiveRetentionDays}
+
+	var config RetentionConfig
+	err := json.Unmarshal([]byte(args[0]), &config)
+	if err != nil { return nil, ccerror.New(ccerror.INVALID_ARGUMENT, "UPDATE_RETENTION_CONFIG: Invalid config object: " + err.Error()) }
+
+	verr := new_validation_error("update_retention_config")
+	if config.ArchiveRetentionDays < 1 { verr.Add("archiveRetentionDays", "Must be at least 1") }
+	if err := verr.Result(); err != nil { return nil, err }
+
+	key, err := config_key(RETENTION_CONFIG)
+	if err != nil { return nil, err }
+
+	bytes, err := json.Marshal(config)
+	if err != nil { fmt.Printf("UPDATE_RETENTION_CONFIG: Error converting config record: %s", err); return nil, errors.New("Error converting config record") }
+
+	err = stub.PutState(key, bytes)
+	if err != nil { fmt.Printf("UPDATE_RETENTION_CONFIG: Error storing config record: %s", err); return nil, errors.New("Error storing config record") }
+
+	return nil, nil
+}
+
+//=================================================================================================================================
+//	 get_retention_config - Returns the RetentionConfig in force.
+//=================================================================================================================================
+func (t *Chaincode) get_retention_config(stub Stub) ([]byte, error) {
+
+	config, err := t.retrieve_retention_config(stub)
+	if err != nil { return nil, err }
+
+	return json.Marshal(config)
+}
@@ -0,0 +1,256 @@
+/*
+Copyright IBM Corp 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+
+package supplychain
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func supplyItem_ids(t *testing.T, l *Ledger, function string, args ...string) []string {
+	bytes, err := l.query(TEST_AUDITOR, function, args...)
+	if err != nil { t.Fatalf("%s: %s", function, err) }
+
+	_, items := decode_page(t, bytes)
+	ids := []string{}
+	for _, sItem := range items {
+		ids = append(ids, sItem.SupplyItemID)
+	}
+	return ids
+}
+
+func TestArchiveSupplyItem(t *testing.T) {
+
+	tests := []struct {
+		name     string
+		caller   string
+		id       string
+		archived bool
+		transfer bool
+		want     string
+	}{
+		{"owner archives",     TEST_OWNER,    "A1",   false, false, ""},
+		{"operator",           TEST_OPERATOR, "A1",   false, false, `"code":"PERMISSION_DENIED"`},
+		{"another owner",      "owner2",      "A1",   false, false, `"code":"PERMISSION_DENIED"`},
+		{"already archived",   TEST_OWNER,    "A1",   true,  false, `"code":"CONFLICT"`},
+		{"transfer pending",   TEST_OWNER,    "A1",   false, true,  "pending transfer"},
+		{"unknown supplyItem", TEST_OWNER,    "NOPE", false, false, `"code":"NOT_FOUND"`},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			l := new_ledger(t)
+			l.register("owner2", ROLE_OWNER)
+			l.create(a_supplyItem("A1"))
+			if tc.archived {
+				if _, err := l.invoke(TEST_OWNER, "archive_supplyItem", "A1"); err != nil { t.Fatal(err) }
+			}
+			if tc.transfer {
+				if _, err := l.invoke(TEST_OWNER, "propose_transfer", "A1", TRANSFER_OWNERSHIP, "owner2"); err != nil { t.Fatal(err) }
+			}
+
+			_, err := l.invoke(tc.caller, "archive_supplyItem", tc.id)
+			check_error(t, err, tc.want)
+			if tc.want != "" { return }
+
+			bytes, err := l.query(TEST_OWNER, "get_supplyItem", "A1")
+			if err != nil { t.Fatal(err) }
+			if sItem := decode_supplyItem(t, bytes); sItem.ArchivedAt != "1970-01-01T00:00:00.000000000Z" { t.Errorf("archivedAt = %q", sItem.ArchivedAt) }
+		})
+	}
+}
+
+func TestArchivedListings(t *testing.T) {
+
+	l := new_ledger(t)
+	l.create(a_supplyItem("A1"))
+	l.create(a_supplyItem("A2"))
+
+	if _, err := l.invoke(TEST_OWNER, "archive_supplyItem", "A1"); err != nil { t.Fatal(err) }
+
+	tests := []struct {
+		name     string
+		function string
+		args     []string
+		want     string
+	}{
+		{"get_supplyItems",                       "get_supplyItems",   nil,                                                                "A2"},
+		{"get_supplyItems including archived",    "get_supplyItems",   []string{"", "", "true"},                                           "A1,A2"},
+		{"query_supplyItems",                     "query_supplyItems", []string{`{"ownerID":"` + TEST_OWNER + `"}`},                        "A2"},
+		{"query_supplyItems including archived",  "query_supplyItems", []string{`{"ownerID":"` + TEST_OWNER + `","includeArchived":true}`}, "A1,A2"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if ids := strings.Join(supplyItem_ids(t, l, tc.function, tc.args...), ","); ids != tc.want { t.Errorf("ids = %s, want %s", ids, tc.want) }
+		})
+	}
+
+	for _, includeArchived := range []string{"", "true"} {
+		bytes, err := l.query(TEST_AUDITOR, "find_supplyItems_near", "-0.1276", "51.5072", "1000", includeArchived)
+		if err != nil { t.Fatal(err) }
+
+		var result GeoResult
+		if err := json.Unmarshal(bytes, &result); err != nil { t.Fatal(err) }
+		if want := map[string]int{"": 1, "true": 2}[includeArchived]; len(result.Items) != want { t.Errorf("find_supplyItems_near %q found %d, want %d", includeArchived, len(result.Items), want) }
+	}
+
+	_, err := l.invoke(TEST_OWNER, "update_supplyItem", "A1", `{"description":"changed"}`)
+	check_error(t, err, "is archived")
+
+	_, err = l.invoke(TEST_OWNER, "restore_supplyItem", "A1")
+	check_error(t, err, "")
+
+	if ids := strings.Join(supplyItem_ids(t, l, "get_supplyItems"), ","); ids != "A1,A2" { t.Errorf("ids after restore = %s", ids) }
+
+	_, err = l.invoke(TEST_OWNER, "restore_supplyItem", "A1")
+	check_error(t, err, "is not archived")
+}
+
+func TestPurgeArchived(t *testing.T) {
+
+	day := 24 * time.Hour
+
+	l := new_ledger(t)
+	l.create(a_supplyItem("A1"))
+	l.create(a_supplyItem("A2"))
+	l.create(a_supplyItem("A3"))
+	l.create(a_supplyItem("A4"))
+
+	if _, err := l.invoke(TEST_ADMIN, "update_retention_config", `{"archiveRetentionDays":30}`); err != nil { t.Fatal(err) }
+
+	for _, id := range []string{"A1", "A2"} {
+		if _, err := l.invoke(TEST_OWNER, "archive_supplyItem", id); err != nil { t.Fatal(err) }
+	}
+	l.now = l.now.Add(10 * day)
+	if _, err := l.invoke(TEST_OWNER, "archive_supplyItem", "A3"); err != nil { t.Fatal(err) }
+
+	purge := func(args ...string) PurgeResult {
+		bytes, err := l.invoke(TEST_ADMIN, "purge_archived", args...)
+		if err != nil { t.Fatalf("purge_archived: %s", err) }
+
+		var result PurgeResult
+		if err := json.Unmarshal(bytes, &result); err != nil { t.Fatal(err) }
+		return result
+	}
+
+	l.now = l.now.Add(25 * day)
+
+	if result := purge("1"); strings.Join(result.Purged, ",") != "A1" || !result.More { t.Errorf("first purge = %+v", result) }
+	if result := purge(); strings.Join(result.Purged, ",") != "A2" || result.More { t.Errorf("second purge = %+v", result) }
+
+	_, err := l.query(TEST_OWNER, "get_supplyItem", "A1")
+	check_error(t, err, `"code":"NOT_FOUND"`)
+
+	for key := range l.state {
+		if strings.HasPrefix(key, "\x00" + HISTORY_KEY_TYPE + "\x00") { continue }
+		if strings.Contains(key, "\x00A1\x00") || strings.Contains(key, "\x00A2\x00") { t.Errorf("purge left %q", key) }
+	}
+
+	if entries := history_entries(t, l, "A1"); len(entries) != 3 || entries[2].Action != "purge_archived" || entries[2].Actor != TEST_ADMIN { t.Errorf("history after purge = %+v", entries) }
+
+	_, err = l.query(TEST_OWNER, "get_supplyItem_history", "A1")
+	check_error(t, err, `"code":"NOT_FOUND"`)
+
+	_, err = l.invoke(TEST_SUPPLIER, "create_supplyItem", a_supplyItem("A1").json())
+	check_error(t, err, "was purged")
+
+	if ids := strings.Join(supplyItem_ids(t, l, "get_supplyItems", "", "", "true"), ","); ids != "A3,A4" { t.Errorf("ids after purge = %s", ids) }
+
+	bytes, err := l.query(TEST_AUDITOR, "get_holdings")
+	if err != nil { t.Fatal(err) }
+	var holdings []Holding
+	if err := json.Unmarshal(bytes, &holdings); err != nil { t.Fatal(err) }
+	if len(holdings) != 1 || holdings[0].Count != 2 { t.Errorf("holdings = %s", bytes) }
+
+	l.now = l.now.Add(10 * day)
+	if result := purge(); strings.Join(result.Purged, ",") != "A3" { t.Errorf("third purge = %+v", result) }
+}
+
+func TestPurgeArchivedRefused(t *testing.T) {
+
+	l := new_ledger(t)
+
+	_, err := l.invoke(TEST_OWNER, "purge_archived")
+	check_error(t, err, `"code":"PERMISSION_DENIED"`)
+
+	for _, days := range []string{"-1", "0"} {
+		_, err = l.invoke(TEST_ADMIN, "update_retention_config", `{"archiveRetentionDays":` + days + `}`)
+		check_error(t, err, `"field":"archiveRetentionDays"`)
+	}
+
+	bytes, err := l.query(TEST_OWNER, "get_retention_config")
+	if err != nil { t.Fatal(err) }
+	if string(bytes) != `{"archiveRetentionDays":90}` { t.Errorf("get_retention_config = %s", bytes) }
+}
+
+func TestPurgeArchivedReferenced(t *testing.T) {
+
+	l := new_ledger(t)
+	l.create(a_supplyItem("A1").quantity(3))
+	l.create(a_supplyItem("A2"))
+	l.create(a_supplyItem("A3"))
+
+	if _, err := l.invoke(TEST_ADMIN, "update_retention_config", `{"archiveRetentionDays":1}`); err != nil { t.Fatal(err) }
+	if _, err := l.invoke(TEST_OWNER, "split_supplyItem", "A1", `[{"supplyItemID":"S1","materialQuantity":1},{"supplyItemID":"S2","materialQuantity":2}]`); err != nil { t.Fatal(err) }
+	if _, err := l.invoke(TEST_SUPPLIER, "issue_recall", TEST_SUPPLIER, `{"materialType":"steel"}`, "Cracked"); err != nil { t.Fatal(err) }
+	l.create(a_supplyItem("A4"))
+
+	for _, id := range []string{"A1", "A2", "A4"} {
+		if _, err := l.invoke(TEST_OWNER, "archive_supplyItem", id); err != nil { t.Fatal(err) }
+	}
+
+	l.now = l.now.Add(48 * time.Hour)
+
+	bytes, err := l.invoke(TEST_ADMIN, "purge_archived", "1")
+	if err != nil { t.Fatal(err) }
+
+	var result PurgeResult
+	if err := json.Unmarshal(bytes, &result); err != nil { t.Fatal(err) }
+	if strings.Join(result.Purged, ",") != "A4" || strings.Join(result.Retained, ",") != "A1,A2" || result.More { t.Errorf("purge = %s", bytes) }
+
+	bytes, err = l.query(TEST_AUDITOR, "get_supplyItem_origins", "S1")
+	if err != nil { t.Fatalf("get_supplyItem_origins after purge: %s", err) }
+	if !strings.Contains(string(bytes), `"supplyItemID":"A1"`) { t.Errorf("origins of S1 = %s", bytes) }
+}
+
+func TestRecallArchived(t *testing.T) {
+
+	l := new_ledger(t)
+	l.create(a_supplyItem("A1"))
+	l.create(a_supplyItem("A2"))
+
+	if _, err := l.invoke(TEST_OWNER, "archive_supplyItem", "A1"); err != nil { t.Fatal(err) }
+
+	bytes, err := l.invoke(TEST_SUPPLIER, "issue_recall", TEST_SUPPLIER, `{}`, "Contaminated")
+	if err != nil { t.Fatal(err) }
+
+	var recall Recall
+	if err := json.Unmarshal(bytes, &recall); err != nil { t.Fatal(err) }
+	if strings.Join(recall.AffectedIDs, ",") != "A1,A2" { t.Errorf("affectedIDs = %v", recall.AffectedIDs) }
+
+	if sItem := get_supplyItem(t, l, "A1"); sItem.Status != STATUS_RECALLED || !is_archived(sItem) { t.Errorf("archived A1 after recall = %+v", sItem) }
+
+	_, err = l.invoke(TEST_OWNER, "restore_supplyItem", "A1")
+	check_error(t, err, "")
+
+	_, err = l.invoke(TEST_OWNER, "propose_transfer", "A1", TRANSFER_OWNERSHIP, TEST_SUPPLIER)
+	check_error(t, err, `"code":"CONFLICT"`)
+}
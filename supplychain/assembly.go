@@ -0,0 +1,177 @@
+/*
+Copyright IBM Corp 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package supplychain
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/vsagineedu/learn-chaincode/ccerror"
+)
+
+const MAX_BOM_DEPTH = 32
+
+//==============================================================================================================================
+//	Component - A quantity of one SupplyItem used to build an assembled SupplyItem. When requesting an assembly a zero
+//				MaterialQty means the whole of the component.
+//==============================================================================================================================
+type Component struct {
+	SupplyItemID  string        `json:"supplyItemID"`
+	MaterialQty   Quantity      `json:"materialQuantity"`
+	UnitOfMeasure UnitOfMeasure `json:"unitOfMeasure"`
+}
+
+//==============================================================================================================================
+//	BOMNode - One entry of the bill of materials returned by trace_components.
+//==============================================================================================================================
+type BOMNode struct {
+	SupplyItemID  string        `json:"supplyItemID"`
+	SupplierID    string        `json:"supplierID"`
+	MaterialType  string        `json:"materialType"`
+	MaterialQty   Quantity      `json:"materialQuantity"`
+	UnitOfMeasure UnitOfMeasure `json:"unitOfMeasure"`
+	Longitude     float64       `json:"longitude"`
+	Latitude      float64       `json:"latitude"`
+	Components    []BOMNode     `json:"components"`
+}
+
+//=================================================================================================================================
+//	 assemble_supplyItem - Creates a new SupplyItem built from components the caller owns. Each component is consumed in
+//						   full, or reduced by the quantity used. The assembly lists its Components and each component
+//						   records the assembly in UsedInIDs.
+//=================================================================================================================================
+func (t *Chaincode) assemble_supplyItem(stub Stub, caller string, args []string) ([]byte, error) {
+
+	//Args
+	//			0							1
+	//	supplyItem JSON object	JSON array of {supplyItemID, materialQuantity}
+
+	if len(args) != 2 { return nil, ccerror.New(ccerror.INVALID_ARGUMENT, "ASSEMBLE_SUPPLYITEM: Incorrect number of arguments. Expecting a supplyItem JSON object and a JSON array of components") }
+
+	assembly, err := parse_supplyItem_json("assemble_supplyItem", args[0])
+	if err != nil { return nil, err }
+
+	if assembly.OwnerID != caller { return nil, ccerror.New(ccerror.PERMISSION_DENIED, "ASSEMBLE_SUPPLYITEM: The assembly must be owned by the caller") }
+
+	var requested []Component
+	err = json.Unmarshal([]byte(args[1]), &requested)
+	if err != nil { return nil, ccerror.New(ccerror.INVALID_ARGUMENT, "ASSEMBLE_SUPPLYITEM: Invalid JSON array of components: " + err.Error()) }
+
+	if len(requested) == 0 { return nil, ccerror.New(ccerror.INVALID_ARGUMENT, "ASSEMBLE_SUPPLYITEM: An assembly needs at least one component") }
+
+	verr := new_validation_error("assemble_supplyItem")
+	var components []SupplyItem
+
+	for i, c := range requested {
+		field := fmt.Sprintf("components[%d]", i)
+
+		if c.MaterialQty < 0 { verr.Add(field+".materialQuantity", "Must not be negative"); continue }
+
+		for _, seen := range components {
+			if seen.SupplyItemID == c.SupplyItemID { verr.Add(field+".supplyItemID", "Duplicate component") }
+		}
+
+		sItem, err := t.retrieve_SupplyItem(stub, c.SupplyItemID)
+		if err != nil { verr.Add(field+".supplyItemID", "No such supplyItem"); continue }
+
+		if sItem.OwnerID != caller { return nil, permission_denied("assemble_supplyItem") }
+
+		if c.MaterialQty == 0 {
+			c.MaterialQty = sItem.MaterialQty
+		}
+		if c.MaterialQty > sItem.MaterialQty { verr.Add(field+".materialQuantity", "Only "+sItem.MaterialQty.String()+" available") }
+
+		next := current_status(sItem)
+		if c.MaterialQty == sItem.MaterialQty {
+			next = STATUS_CONSUMED
+		}
+		if err := check_transition(sItem, "assemble_supplyItem", next); err != nil { return nil, err }
+
+		requested[i] = Component{SupplyItemID: sItem.SupplyItemID, MaterialQty: c.MaterialQty, UnitOfMeasure: sItem.UnitOfMeasure}
+		components = append(components, sItem)
+	}
+
+	if err := verr.Result(); err != nil { return nil, err }
+
+	assembly.Components = requested
+	assembly.Status = STATUS_CREATED
+
+	err = t.create_lot(stub, assembly, caller, "assemble_supplyItem")
+	if err != nil { fmt.Printf("ASSEMBLE_SUPPLYITEM: Error creating assembly: %s", err); return nil, err }
+
+	for i, sItem := range components {
+		sItem.MaterialQty -= requested[i].MaterialQty
+		sItem.UsedInIDs = append(sItem.UsedInIDs, assembly.SupplyItemID)
+		if sItem.MaterialQty == 0 {
+			sItem.Status = STATUS_CONSUMED
+		}
+
+		_, err = t.save_changes(stub, sItem, caller, "assemble_supplyItem")
+		if err != nil { fmt.Printf("ASSEMBLE_SUPPLYITEM: Error consuming component: %s", err); return nil, err }
+	}
+
+	return nil, nil
+}
+
+//=================================================================================================================================
+//	 build_bom - Builds the BOMNode for sItem, recursing into its components. used is the quantity the parent assembly
+//				 consumed, or the SupplyItem's own quantity at the root.
+//=================================================================================================================================
+func (t *Chaincode) build_bom(stub Stub, sItem SupplyItem, used Quantity, depth int) (BOMNode, error) {
+
+	node := BOMNode{
+		SupplyItemID:  sItem.SupplyItemID,
+		SupplierID:    sItem.SupplierID,
+		MaterialType:  sItem.MaterialType,
+		MaterialQty:   used,
+		UnitOfMeasure: sItem.UnitOfMeasure,
+		Longitude:     sItem.Longitude,
+		Latitude:      sItem.Latitude,
+		Components:    []BOMNode{},
+	}
+
+	if depth > MAX_BOM_DEPTH { return node, ccerror.New(ccerror.INVALID_ARGUMENT, "TRACE_COMPONENTS: Bill of materials is deeper than " + fmt.Sprint(MAX_BOM_DEPTH) + " levels") }
+
+	for _, c := range sItem.Components {
+		component, err := t.retrieve_SupplyItem(stub, c.SupplyItemID)
+		if err != nil { return node, err }
+
+		child, err := t.build_bom(stub, component, c.MaterialQty, depth+1)
+		if err != nil { return node, err }
+
+		node.Components = append(node.Components, child)
+	}
+
+	return node, nil
+}
+
+//=================================================================================================================================
+//	 trace_components - Returns the full bill of materials of a SupplyItem as a tree, with the supplier and location of
+//						every component.
+//=================================================================================================================================
+func (t *Chaincode) trace_components(stub Stub, caller string, supplyItemID string) ([]byte, error) {
+
+	sItem, err := t.retrieve_SupplyItem(stub, supplyItemID)
+	if err != nil { return nil, err }
+
+	if sItem.OwnerID != caller && sItem.OperatorID != caller && !t.reads_all(stub, caller) { return nil, permission_denied("trace_components") }
+
+	bom, err := t.build_bom(stub, sItem, sItem.MaterialQty, 0)
+	if err != nil { return nil, err }
+
+	return json.Marshal(bom)
+}
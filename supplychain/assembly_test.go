@@ -0,0 +1,103 @@
+/*
+Copyright IBM Corp 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package supplychain
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestAssembleSupplyItem(t *testing.T) {
+
+	twice := `[{"supplyItemID":"A1","materialQuantity":1},{"supplyItemID":"A1","materialQuantity":1}]`
+
+	tests := []struct {
+		name       string
+		caller     string
+		assembly   *SupplyItemBuilder
+		components string
+		want       string
+	}{
+		{"part and whole",       TEST_OWNER, a_supplyItem("ASM"),                 `[{"supplyItemID":"A1","materialQuantity":2.5},{"supplyItemID":"A2"}]`, ""},
+		{"assembly of another",  TEST_OWNER, a_supplyItem("ASM").owner("owner2"), `[{"supplyItemID":"A1"}]`,                                              `"code":"PERMISSION_DENIED"`},
+		{"component of another", "owner2",   a_supplyItem("ASM").owner("owner2"), `[{"supplyItemID":"A1"}]`,                                              `"code":"PERMISSION_DENIED"`},
+		{"no components",        TEST_OWNER, a_supplyItem("ASM"),                 `[]`,                                                                   "at least one component"},
+		{"more than available",  TEST_OWNER, a_supplyItem("ASM"),                 `[{"supplyItemID":"A1","materialQuantity":13}]`,                        "Only 12.5 available"},
+		{"negative quantity",    TEST_OWNER, a_supplyItem("ASM"),                 `[{"supplyItemID":"A1","materialQuantity":-1}]`,                        "Must not be negative"},
+		{"duplicate component",  TEST_OWNER, a_supplyItem("ASM"),                 twice,                                                                  "Duplicate component"},
+		{"unknown component",    TEST_OWNER, a_supplyItem("ASM"),                 `[{"supplyItemID":"NOPE"}]`,                                            "No such supplyItem"},
+		{"consumed component",   TEST_OWNER, a_supplyItem("ASM"),                 `[{"supplyItemID":"C1"}]`,                                              "Illegal transition"},
+		{"existing assembly ID", TEST_OWNER, a_supplyItem("A2"),                  `[{"supplyItemID":"A1"}]`,                                              `"code":"ALREADY_EXISTS"`},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			l := new_ledger(t)
+			l.register("owner2", ROLE_OWNER)
+			l.create(a_supplyItem("A1"))
+			l.create(a_supplyItem("A2").quantity(4))
+			l.create(a_supplyItem("C1"))
+			if _, err := l.invoke(TEST_OWNER, "update_status", "C1", string(STATUS_CONSUMED)); err != nil { t.Fatal(err) }
+
+			_, err := l.invoke(tc.caller, "assemble_supplyItem", tc.assembly.json(), tc.components)
+			check_error(t, err, tc.want)
+			if tc.want != "" {
+				if sItem := get_supplyItem(t, l, "A1"); sItem.MaterialQty.String() != "12.5" || len(sItem.UsedInIDs) != 0 { t.Errorf("rejected assembly changed A1: %+v", sItem) }
+				return
+			}
+
+			assembly := get_supplyItem(t, l, "ASM")
+			if len(assembly.Components) != 2 || assembly.Components[0].MaterialQty.String() != "2.5" || assembly.Components[1].MaterialQty.String() != "4" || assembly.Components[1].UnitOfMeasure != "KGM" {
+				t.Errorf("components = %+v", assembly.Components)
+			}
+
+			if a1 := get_supplyItem(t, l, "A1"); a1.MaterialQty.String() != "10" || a1.Status != STATUS_CREATED || len(a1.UsedInIDs) != 1 || a1.UsedInIDs[0] != "ASM" { t.Errorf("A1 = %+v", a1) }
+			if a2 := get_supplyItem(t, l, "A2"); a2.MaterialQty.String() != "0" || a2.Status != STATUS_CONSUMED || len(a2.UsedInIDs) != 1 { t.Errorf("A2 = %+v", a2) }
+		})
+	}
+}
+
+func TestTraceComponents(t *testing.T) {
+
+	l := new_ledger(t)
+	l.register("owner2", ROLE_OWNER)
+	l.create(a_supplyItem("A1"))
+	l.create(a_supplyItem("A2").quantity(4).with("longitude", 2.3522).with("latitude", 48.8566))
+
+	if _, err := l.invoke(TEST_OWNER, "assemble_supplyItem", a_supplyItem("SUB").quantity(1).json(), `[{"supplyItemID":"A1","materialQuantity":2.5}]`); err != nil { t.Fatal(err) }
+	if _, err := l.invoke(TEST_OWNER, "assemble_supplyItem", a_supplyItem("TOP").quantity(1).json(), `[{"supplyItemID":"SUB"},{"supplyItemID":"A2","materialQuantity":1}]`); err != nil { t.Fatal(err) }
+
+	bytes, err := l.query(TEST_AUDITOR, "trace_components", "TOP")
+	if err != nil { t.Fatal(err) }
+
+	var bom BOMNode
+	if err := json.Unmarshal(bytes, &bom); err != nil { t.Fatal(err) }
+
+	if bom.SupplyItemID != "TOP" || len(bom.Components) != 2 { t.Fatalf("bom = %s", bytes) }
+	sub, a2 := bom.Components[0], bom.Components[1]
+	if sub.SupplyItemID != "SUB" || sub.MaterialQty.String() != "1" || len(sub.Components) != 1 { t.Errorf("SUB = %+v", sub) }
+	if a2.SupplyItemID != "A2" || a2.MaterialQty.String() != "1" || a2.SupplierID != TEST_SUPPLIER || a2.Latitude != 48.8566 || len(a2.Components) != 0 { t.Errorf("A2 = %+v", a2) }
+	if len(sub.Components) == 1 {
+		if a1 := sub.Components[0]; a1.SupplyItemID != "A1" || a1.MaterialQty.String() != "2.5" { t.Errorf("A1 = %+v", a1) }
+	}
+
+	_, err = l.query("owner2", "trace_components", "TOP")
+	check_error(t, err, `"code":"PERMISSION_DENIED"`)
+
+	_, err = l.query(TEST_AUDITOR, "trace_components", "NOPE")
+	check_error(t, err, `"code":"NOT_FOUND"`)
+}
@@ -0,0 +1,358 @@
+/*
+Copyright IBM Corp 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package supplychain
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/vsagineedu/learn-chaincode/ccerror"
+)
+
+//==============================================================================================================================
+//	Attachment - A photo or document kept off the ledger at URI. The ledger holds only its SHA-256 digest, media type and
+//				 size, so the content can be checked against it later. Small content may instead be held inline in Data,
+//				 base64 encoded, up to the MaxInlineBytes of the AttachmentConfig.
+//==============================================================================================================================
+type Attachment struct {
+	Name      string `json:"name,omitempty"`
+	URI       string `json:"uri,omitempty"`
+	SHA256    string `json:"sha256"`
+	MediaType string `json:"mediaType"`
+	Size      int64  `json:"size"`
+	Data      string `json:"data,omitempty"`
+}
+
+//==============================================================================================================================
+//	 UnmarshalJSON - Accepts either an Attachment object or a plain string, so records written when Photo was a string
+//					 can still be read. The string is converted by legacy_attachment.
+//==============================================================================================================================
+func (a *Attachment) UnmarshalJSON(data []byte) error {
+
+	var legacy string
+	if err := json.Unmarshal(data, &legacy); err == nil {
+		*a = legacy_attachment(legacy)
+		return nil
+	}
+
+	type attachment Attachment			// Without the UnmarshalJSON method
+	*a = Attachment{}
+	return json.Unmarshal(data, (*attachment)(a))
+}
+
+//==============================================================================================================================
+//	 legacy_attachment - Converts a Photo stored as a string. bluechain clients stored either a URI or the photo itself,
+//						 as a data: URI or bare base64. Content becomes inline Data with its digest, media type and size,
+//						 so check_inline_size applies to it; anything else becomes the URI of an Attachment with no
+//						 digest.
+//==============================================================================================================================
+func legacy_attachment(value string) Attachment {
+
+	var content []byte
+	mediaType := ""
+
+	if strings.HasPrefix(value, "data:") {
+		comma := strings.Index(value, ",")
+		if comma < 0 { return Attachment{URI: value} }
+
+		meta, payload := value[len("data:"):comma], value[comma+1:]
+
+		var err error
+		if strings.HasSuffix(meta, ";base64") {
+			meta = strings.TrimSuffix(meta, ";base64")
+			content, err = base64.StdEncoding.DecodeString(payload)
+		} else {
+			var text string
+			text, err = url.QueryUnescape(strings.Replace(payload, "+", "%2B", -1))
+			content = []byte(text)
+		}
+		if err != nil { return Attachment{URI: value} }
+
+		mediaType = meta
+		if mediaType == "" || strings.HasPrefix(mediaType, ";") {
+			mediaType = "text/plain" + mediaType				// The default of RFC 2397
+		}
+	} else if u, err := url.Parse(value); (err != nil || u.Scheme == "") && len(value) >= MIN_LEGACY_BASE64_LENGTH {
+		content, err = base64.StdEncoding.DecodeString(value)
+		if err != nil { return Attachment{URI: value} }
+
+		mediaType = http.DetectContentType(content)
+	} else {
+		return Attachment{URI: value}
+	}
+
+	return Attachment{
+		SHA256:    sha256_hex(content),
+		MediaType: mediaType,
+		Size:      int64(len(content)),
+		Data:      base64.StdEncoding.EncodeToString(content),
+	}
+}
+
+func (a *Attachment) is_empty() bool {
+	return a == nil || *a == Attachment{}
+}
+
+const PHOTO_ATTACHMENT = "photo"
+const MAX_URI_LENGTH = 2048
+const MIN_LEGACY_BASE64_LENGTH = 16			// Shorter strings without a scheme are more likely names than content
+
+const ATTACHMENT_CONFIG = "attachments"
+const DEFAULT_MAX_INLINE_BYTES = 16 * 1024
+
+//==============================================================================================================================
+//	AttachmentConfig - Limits on attachments, changed by admins with update_attachment_config.
+//==============================================================================================================================
+type AttachmentConfig struct {
+	MaxInlineBytes int64 `json:"maxInlineBytes"`
+}
+
+//==============================================================================================================================
+//	VerifyResult - The response of verify_attachment.
+//==============================================================================================================================
+type VerifyResult struct {
+	Match    bool   `json:"match"`
+	SHA256   string `json:"sha256"`
+	Size     int64  `json:"size"`
+	Recorded string `json:"recorded"`
+}
+
+func sha256_hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+//==============================================================================================================================
+//	 validate_attachment - Adds a field error to verr for each problem with an attachment supplied as input.
+//==============================================================================================================================
+func validate_attachment(verr *ccerror.Error, field string, a Attachment) {
+
+	if a.URI == "" && a.Data == "" { verr.Add(field, "Needs a uri or inline data") }
+
+	if a.URI != "" {
+		u, err := url.Parse(a.URI)
+		if len(a.URI) > MAX_URI_LENGTH {
+			verr.Add(field+".uri", "Must be at most "+strconv.Itoa(MAX_URI_LENGTH)+" characters")
+		} else if err != nil || u.Scheme == "" {
+			verr.Add(field+".uri", "Must be an absolute URI")
+		} else if u.Scheme == "data" {
+			verr.Add(field+".uri", "Inline content goes in data")
+		}
+	}
+
+	if len(a.SHA256) != sha256.Size*2 || !is_lower_hex(a.SHA256) {
+		verr.Add(field+".sha256", "Must be the lower case hex SHA-256 digest of the content")
+	}
+
+	if _, _, err := mime.ParseMediaType(a.MediaType); err != nil { verr.Add(field+".mediaType", "Must be a media type such as image/jpeg") }
+
+	if a.Size < 0 { verr.Add(field+".size", "Must not be negative") }
+
+	if a.Data != "" {
+		data, err := base64.StdEncoding.DecodeString(a.Data)
+		if err != nil {
+			verr.Add(field+".data", "Must be base64 encoded")
+		} else {
+			if int64(len(data)) != a.Size { verr.Add(field+".size", "Must be the length of data, "+strconv.Itoa(len(data))) }
+			if !verr.Has(field+".sha256") && sha256_hex(data) != a.SHA256 { verr.Add(field+".sha256", "Does not match data") }
+		}
+	}
+}
+
+func is_lower_hex(value string) bool {
+	for _, c := range value {
+		if !(c >= '0' && c <= '9' || c >= 'a' && c <= 'f') { return false }
+	}
+	return true
+}
+
+//==============================================================================================================================
+//	 validate_attachments - Checks the photo and list of named attachments supplied as input. An empty photo removes it.
+//==============================================================================================================================
+func validate_attachments(verr *ccerror.Error, sItem *SupplyItem, photo bool, attachments bool) {
+
+	if photo && sItem.Photo.is_empty() {
+		sItem.Photo = nil
+	} else if photo {
+		validate_attachment(verr, PHOTO_ATTACHMENT, *sItem.Photo)
+	}
+
+	if !attachments { return }
+
+	var names []string
+
+	for i, a := range sItem.Attachments {
+		field := fmt.Sprintf("attachments[%d]", i)
+
+		validate_id(verr, field+".name", a.Name, true)
+		if a.Name == PHOTO_ATTACHMENT || contains_string(names, a.Name) { verr.Add(field+".name", "Duplicate name "+a.Name) }
+		names = append(names, a.Name)
+
+		validate_attachment(verr, field, a)
+	}
+}
+
+func config_key(name string) (string, error) {
+	return create_composite_key(CONFIG_KEY_TYPE, []string{name})
+}
+
+//==============================================================================================================================
+//	 retrieve_attachment_config - Returns the stored AttachmentConfig, or the defaults if none has been stored.
+//==============================================================================================================================
+func (t *Chaincode) retrieve_attachment_config(stub Stub) (AttachmentConfig, error) {
+
+	config := AttachmentConfig{MaxInlineBytes: DEFAULT_MAX_INLINE_BYTES}
+
+	key, err := config_key(ATTACHMENT_CONFIG)
+	if err != nil { return config, err }
+
+	bytes, err := stub.GetState(key)
+	if err != nil { fmt.Printf("RETRIEVE_ATTACHMENT_CONFIG: Failed to get config: %s", err); return config, errors.New("Error retrieving attachment config") }
+
+	if bytes == nil { return config, nil }
+
+	err = json.Unmarshal(bytes, &config)
+	if err != nil { return config, ccerror.New(ccerror.CORRUPT_RECORD, "Corrupt attachment config record " + string(bytes)) }
+
+	return config, nil
+}
+
+//==============================================================================================================================
+//	 check_inline_size - Adds a field error to verr for each attachment of sItem holding more inline data than allowed.
+//						 Relies on validate_attachment having checked that Size is the length of the data.
+//==============================================================================================================================
+func (t *Chaincode) check_inline_size(stub Stub, verr *ccerror.Error, sItem SupplyItem) error {
+
+	config, err := t.retrieve_attachment_config(stub)
+	if err != nil { return err }
+
+	check := func(field string, a Attachment) {
+		if a.Data != "" && a.Size > config.MaxInlineBytes {
+			verr.Add(field+".data", "Inline data is limited to "+strconv.FormatInt(config.MaxInlineBytes, 10)+" bytes; store it off the ledger and give its uri")
+		}
+	}
+
+	if sItem.Photo != nil { check(PHOTO_ATTACHMENT, *sItem.Photo) }
+	for i, a := range sItem.Attachments {
+		check(fmt.Sprintf("attachments[%d]", i), a)
+	}
+
+	return nil
+}
+
+//==============================================================================================================================
+//	 find_attachment - Returns the photo or the attachment with the given name.
+//==============================================================================================================================
+func find_attachment(sItem SupplyItem, name string) (Attachment, string, bool) {
+
+	if name == PHOTO_ATTACHMENT {
+		if sItem.Photo.is_empty() { return Attachment{}, "", false }
+		return *sItem.Photo, "photo", true
+	}
+
+	for _, a := range sItem.Attachments {
+		if a.Name == name { return a, "attachments", true }
+	}
+
+	return Attachment{}, "", false
+}
+
+//=================================================================================================================================
+//	 verify_attachment - Reports whether content matches the digest recorded for the photo or a named attachment of a
+//						 SupplyItem. The caller must be able to read that field of the SupplyItem.
+//=================================================================================================================================
+func (t *Chaincode) verify_attachment(stub Stub, caller string, args []string) ([]byte, error) {
+
+	//Args
+	//		0				1					2
+	//	supplyItemID	"photo" or name		base64 content
+
+	if len(args) != 3 { return nil, ccerror.New(ccerror.INVALID_ARGUMENT, "VERIFY_ATTACHMENT: Incorrect number of arguments. Expecting supplyItemID, attachment name and base64 content") }
+
+	content, err := base64.StdEncoding.DecodeString(args[2])
+	if err != nil { return nil, ccerror.New(ccerror.INVALID_ARGUMENT, "VERIFY_ATTACHMENT: Content must be base64 encoded") }
+
+	sItem, err := t.retrieve_SupplyItem(stub, args[0])
+	if err != nil { return nil, err }
+
+	viewer, err := t.new_viewer(stub, caller)
+	if err != nil { return nil, err }
+
+	a, field, found := find_attachment(sItem, args[1])
+
+	fields := viewer.fields(sItem)
+	if !contains_string(fields, ALL_FIELDS) && (!found || !contains_string(fields, field)) { return nil, permission_denied("verify_attachment") }
+
+	if !found { return nil, ccerror.New(ccerror.NOT_FOUND, "SupplyItem " + sItem.SupplyItemID + " has no attachment " + args[1]) }
+	if a.SHA256 == "" { return nil, ccerror.New(ccerror.NOT_FOUND, "No digest is recorded for attachment " + args[1] + " of " + sItem.SupplyItemID) }
+
+	result := VerifyResult{SHA256: sha256_hex(content), Size: int64(len(content)), Recorded: a.SHA256}
+	result.Match = result.SHA256 == a.SHA256 && (a.Size == 0 || a.Size == result.Size)
+
+	return json.Marshal(result)
+}
+
+//=================================================================================================================================
+//	 update_attachment_config - Replaces the AttachmentConfig.
+//=================================================================================================================================
+func (t *Chaincode) update_attachment_config(stub Stub, caller string, args []string) ([]byte, error) {
+
+	//Args
+	//		0
+	//	config JSON object of {maxInlineBytes}
+
+	if len(args) != 1 { return nil, ccerror.New(ccerror.INVALID_ARGUMENT, "UPDATE_ATTACHMENT_CONFIG: Incorrect number of arguments. Expecting a config JSON object") }
+
+	var config AttachmentConfig
+	err := json.Unmarshal([]byte(args[0]), &config)
+	if err != nil { return nil, ccerror.New(ccerror.INVALID_ARGUMENT, "UPDATE_ATTACHMENT_CONFIG: Invalid config object: " + err.Error()) }
+
+	verr := new_validation_error("update_attachment_config")
+	if config.MaxInlineBytes < 0 { verr.Add("maxInlineBytes", "Must not be negative") }
+	if err := verr.Result(); err != nil { return nil, err }
+
+	key, err := config_key(ATTACHMENT_CONFIG)
+	if err != nil { return nil, err }
+
+	bytes, err := json.Marshal(config)
+	if err != nil { fmt.Printf("UPDATE_ATTACHMENT_CONFIG: Error converting config record: %s", err); return nil, errors.New("Error converting config record") }
+
+	err = stub.PutState(key, bytes)
+	if err != nil { fmt.Printf("UPDATE_ATTACHMENT_CONFIG: Error storing config record: %s", err); return nil, errors.New("Error storing config record") }
+
+	return nil, nil
+}
+
+//=================================================================================================================================
+//	 get_attachment_config - Returns the AttachmentConfig in force.
+//=================================================================================================================================
+func (t *Chaincode) get_attachment_config(stub Stub) ([]byte, error) {
+
+	config, err := t.retrieve_attachment_config(stub)
+	if err != nil { return nil, err }
+
+	return json.Marshal(config)
+}
@@ -0,0 +1,101 @@
+/*
+Copyright IBM Corp 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+
+package supplychain
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestLegacyAttachment(t *testing.T) {
+
+	png := "\x89PNG\r\n\x1a\n" + strings.Repeat("\x00", 16)
+	encoded := base64.StdEncoding.EncodeToString([]byte(png))
+
+	tests := []struct {
+		name      string
+		photo     string
+		uri       string
+		mediaType string
+		content   string
+	}{
+		{"uri",              "https://example.com/a.jpg",        "https://example.com/a.jpg", "",           ""},
+		{"relative path",    "photos/item-0001.jpg",             "photos/item-0001.jpg",      "",           ""},
+		{"short name",       "IMG0001",                          "IMG0001",                   "",           ""},
+		{"base64 data uri",  "data:image/png;base64," + encoded, "",                          "image/png",  png},
+		{"plain data uri",   "data:,a%20b+c",                    "",                          "text/plain", "a b+c"},
+		{"bare base64",      encoded,                            "",                          "image/png",  png},
+		{"corrupt data uri", "data:image/png;base64,@@@",        "data:image/png;base64,@@@", "",           ""},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			input, _ := json.Marshal(tc.photo)
+
+			var a Attachment
+			if err := json.Unmarshal(input, &a); err != nil { t.Fatal(err) }
+
+			if a.URI != tc.uri || a.MediaType != tc.mediaType { t.Fatalf("attachment %+v", a) }
+			if tc.content == "" {
+				if a.Data != "" || a.SHA256 != "" { t.Errorf("a uri was taken for content: %+v", a) }
+				return
+			}
+
+			data, err := base64.StdEncoding.DecodeString(a.Data)
+			if err != nil || string(data) != tc.content { t.Fatalf("data %q", data) }
+			if a.SHA256 != sha256_hex([]byte(tc.content)) || a.Size != int64(len(tc.content)) { t.Errorf("attachment %+v", a) }
+		})
+	}
+}
+
+func TestCreateWithInlinePhoto(t *testing.T) {
+
+	small := []byte("small photo")
+	large := []byte(strings.Repeat("x", DEFAULT_MAX_INLINE_BYTES+1))
+
+	inline := func(data []byte) map[string]interface{} {
+		return map[string]interface{}{"sha256": sha256_hex(data), "mediaType": "image/jpeg", "size": len(data), "data": base64.StdEncoding.EncodeToString(data)}
+	}
+	offLedger := map[string]interface{}{"uri": "https://example.com/a.jpg", "sha256": sha256_hex(small), "mediaType": "image/jpeg", "size": len(small)}
+	mismatch := inline(small)
+	mismatch["sha256"] = sha256_hex(large)
+	dataURI := "data:image/jpeg;base64," + base64.StdEncoding.EncodeToString(large)
+
+	tests := []struct {
+		name  string
+		photo interface{}
+		want  string
+	}{
+		{"inline photo",           inline(small), ""},
+		{"off the ledger",         offLedger,     ""},
+		{"inline above the cap",   inline(large), `"field":"photo.data"`},
+		{"digest mismatch",        mismatch,      `"field":"photo.sha256"`},
+		{"data uri above the cap", dataURI,       `"field":"photo.data"`},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			l := new_ledger(t)
+
+			_, err := l.invoke(TEST_SUPPLIER, "create_supplyItem", a_supplyItem("A1").with("photo", tc.photo).json())
+			check_error(t, err, tc.want)
+		})
+	}
+}
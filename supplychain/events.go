@@ -0,0 +1,104 @@
+/*
+Copyright IBM Corp 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package supplychain
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+)
+
+//==============================================================================================================================
+//	Events - A transaction can only carry one chaincode event, so every change made by a transaction is collected into
+//			 a single EVENT_NAME event whose payload lists them in order. The payload format is versioned by
+//			 SchemaVersion; fields are only ever added within a version.
+//==============================================================================================================================
+const EVENT_NAME = "SupplyItemChanged"
+const EVENT_SCHEMA_VERSION = 1
+
+//==============================================================================================================================
+//	SupplyItemEvent - One change to a SupplyItem, or to a pending Transfer of it. ChangedFields names the SupplyItem
+//					  fields whose value changed; use get_supplyItem_history for the values. Transfer events carry the
+//					  Transfer instead and no Revision. A rejection or cancellation is also recorded as a new revision
+//					  with no changed fields, so it appears in the SupplyItem's history.
+//==============================================================================================================================
+type SupplyItemEvent struct {
+	SupplyItemID  string    `json:"supplyItemID"`
+	Action        string    `json:"action"`
+	Actor         string    `json:"actor"`
+	Revision      int       `json:"revision,omitempty"`
+	ChangedFields []string  `json:"changedFields"`
+	Transfer      *Transfer `json:"transfer,omitempty"`
+	TxID          string    `json:"txID"`
+}
+
+type EventPayload struct {
+	SchemaVersion int               `json:"schemaVersion"`
+	TxID          string            `json:"txID"`
+	Events        []SupplyItemEvent `json:"events"`
+}
+
+//==============================================================================================================================
+//	 emit_event - Adds ev to the events of the current transaction and sets the transaction's event to all of them.
+//==============================================================================================================================
+func (t *Chaincode) emit_event(stub Stub, ev SupplyItemEvent) error {
+
+	txID := stub.GetTxID()
+	ev.TxID = txID
+	if ev.ChangedFields == nil {
+		ev.ChangedFields = []string{}
+	}
+
+	t.eventsLock.Lock()
+	defer t.eventsLock.Unlock()
+
+	if t.events == nil {
+		t.events = map[string][]SupplyItemEvent{}
+	}
+	t.events[txID] = append(t.events[txID], ev)
+
+	bytes, err := json.Marshal(EventPayload{SchemaVersion: EVENT_SCHEMA_VERSION, TxID: txID, Events: t.events[txID]})
+	if err != nil { fmt.Printf("EMIT_EVENT: Error converting event: %s", err); return errors.New("Error converting event") }
+
+	err = stub.SetEvent(EVENT_NAME, bytes)
+	if err != nil { fmt.Printf("EMIT_EVENT: Error setting event: %s", err); return errors.New("Error setting event") }
+
+	return nil
+}
+
+//==============================================================================================================================
+//	 clear_events - Forgets the events collected for a finished transaction.
+//==============================================================================================================================
+func (t *Chaincode) clear_events(txID string) {
+	t.eventsLock.Lock()
+	defer t.eventsLock.Unlock()
+
+	delete(t.events, txID)
+}
+
+func (t *Chaincode) emit_transfer_event(stub Stub, transfer Transfer, actor string, action string) error {
+	return t.emit_event(stub, SupplyItemEvent{SupplyItemID: transfer.SupplyItemID, Action: action, Actor: actor, Transfer: &transfer})
+}
+
+func changed_field_names(changes []FieldChange) []string {
+	names := []string{}
+	for _, c := range changes {
+		names = append(names, c.Field)
+	}
+	return names
+}
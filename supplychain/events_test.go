@@ -0,0 +1,85 @@
+/*
+Copyright IBM Corp 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+
+package supplychain
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+//==============================================================================================================================
+//	 invoke_events - Invokes function and returns the events the transaction set, each as "supplyItemID action revision
+//					 changedFields".
+//==============================================================================================================================
+func invoke_events(t *testing.T, l *Ledger, user string, function string, args ...string) ([]string, error) {
+
+	stub := l.transaction(user)
+	_, err := l.cc.Invoke(stub, function, args)
+
+	bytes, ok := stub.events[EVENT_NAME]
+	if !ok { return []string{}, err }
+
+	var payload EventPayload
+	if err := json.Unmarshal(bytes, &payload); err != nil { t.Fatalf("not an EventPayload: %s: %s", err, bytes) }
+	if payload.SchemaVersion != EVENT_SCHEMA_VERSION || payload.TxID != stub.txID { t.Errorf("payload = %s", bytes) }
+
+	events := []string{}
+	for _, ev := range payload.Events {
+		if ev.TxID != stub.txID || ev.Actor != user { t.Errorf("event = %+v", ev) }
+		events = append(events, strings.TrimSpace(fmt.Sprintf("%s %s %d %s", ev.SupplyItemID, ev.Action, ev.Revision, strings.Join(ev.ChangedFields, ","))))
+	}
+	return events, err
+}
+
+//==============================================================================================================================
+//	TestEvents - Runs calls in order against one ledger, each with the events its transaction should set. A refused
+//				 call sets none.
+//==============================================================================================================================
+func TestEvents(t *testing.T) {
+
+	l := new_ledger(t)
+	l.register("owner2", ROLE_OWNER)
+
+	created := "baseUnit,description,latitude,longitude,materialQuantity,materialType,normalizedQuantity,operatorID,ownerID,schemaVersion,status,supplierID,supplyItemID,unitOfMeasure"
+	child := strings.Replace(created, "ownerID,", "ownerID,parentIDs,", 1)
+	split := `[{"supplyItemID":"S1","materialQuantity":5},{"supplyItemID":"S2","materialQuantity":7.5}]`
+
+	steps := []struct {
+		caller   string
+		function string
+		args     []string
+		want     string
+	}{
+		{TEST_SUPPLIER, "create_supplyItem", []string{a_supplyItem("A1").json()},          "A1 create_supplyItem 1 " + created},
+		{TEST_OPERATOR, "update_supplyItem", []string{"A1", `{"description":"Cut"}`},      "A1 update_supplyItem 2 description"},
+		{TEST_AUDITOR,  "update_supplyItem", []string{"A1", `{"description":"Cut"}`},      ""},
+		{TEST_OWNER,    "update_status",     []string{"A1", "InStorage"},                  "A1 update_status 3 status"},
+		{TEST_OWNER,    "propose_transfer",  []string{"A1", TRANSFER_OWNERSHIP, "owner2"}, "A1 propose_transfer 0"},
+		{TEST_OWNER,    "cancel_transfer",   []string{"A1", TRANSFER_OWNERSHIP},           "A1 cancel_transfer 4; A1 cancel_transfer 0"},
+		{TEST_OWNER,    "split_supplyItem",  []string{"A1", split},                        "S1 split_supplyItem 1 " + child + "; S2 split_supplyItem 1 " + child + "; A1 split_supplyItem 5 childIDs,materialQuantity,normalizedQuantity,status"},
+	}
+
+	for i, step := range steps {
+		events, _ := invoke_events(t, l, step.caller, step.function, step.args...)
+		if got := strings.Join(events, "; "); got != step.want { t.Errorf("step %d %s events = %q, want %q", i, step.function, got, step.want) }
+	}
+
+	if len(l.cc.events) != 0 { t.Errorf("events kept after their transactions: %v", l.cc.events) }
+}
@@ -0,0 +1,277 @@
+/*
+Copyright IBM Corp 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+
+package supplychain
+
+import (
+	"encoding/json"
+	"errors"
+	"sort"
+	"strconv"
+	"testing"
+	"time"
+)
+
+//==============================================================================================================================
+//	Test fixtures shared by the tests of this package.
+//==============================================================================================================================
+
+//==============================================================================================================================
+//	TestStub - An in-memory Stub. Every TestStub of a Ledger shares its state; each carries the caller and transaction
+//			   of one call. Writes are refused outside a transaction, so a query that writes fails its test.
+//==============================================================================================================================
+type TestStub struct {
+	state    map[string][]byte
+	user     string
+	metadata []byte
+	txID     string
+	txTime   time.Time
+	events   map[string][]byte
+}
+
+func (s *TestStub) GetState(key string) ([]byte, error) {
+	return s.state[key], nil
+}
+
+func (s *TestStub) PutState(key string, value []byte) error {
+	if s.txID == "" { return errors.New("PutState outside a transaction") }
+	s.state[key] = value
+	return nil
+}
+
+func (s *TestStub) DelState(key string) error {
+	if s.txID == "" { return errors.New("DelState outside a transaction") }
+	delete(s.state, key)
+	return nil
+}
+
+func (s *TestStub) RangeQueryState(startKey string, endKey string) (StateIterator, error) {
+	keys := []string{}
+	for key := range s.state {
+		if key >= startKey && key <= endKey { keys = append(keys, key) }
+	}
+	sort.Strings(keys)
+	return &TestIterator{stub: s, keys: keys}, nil
+}
+
+func (s *TestStub) GetTxID() string                 { return s.txID }
+func (s *TestStub) GetTxTime() (time.Time, error)   { return s.txTime, nil }
+func (s *TestStub) GetCallerMetadata() ([]byte, error) { return s.metadata, nil }
+
+func (s *TestStub) ReadCertAttribute(name string) ([]byte, error) {
+	if name == USERNAME_ATTRIBUTE && s.user != "" {
+		return []byte(s.user), nil
+	}
+	return nil, nil
+}
+
+func (s *TestStub) SetEvent(name string, payload []byte) error {
+	s.events[name] = payload
+	return nil
+}
+
+type TestIterator struct {
+	stub *TestStub
+	keys []string
+}
+
+func (i *TestIterator) HasNext() bool { return len(i.keys) > 0 }
+func (i *TestIterator) Close() error  { return nil }
+
+func (i *TestIterator) Next() (string, []byte, error) {
+	if len(i.keys) == 0 { return "", nil, errors.New("No more keys") }
+	key := i.keys[0]
+	i.keys = i.keys[1:]
+	return key, i.stub.state[key], nil
+}
+
+//==============================================================================================================================
+//	Ledger - An in-memory ledger with participants registered, a counter for transaction IDs and the time of the next
+//			 transaction, which starts at the Unix epoch.
+//==============================================================================================================================
+type Ledger struct {
+	t     *testing.T
+	cc    *Chaincode
+	state map[string][]byte
+	tx    int
+	now   time.Time
+}
+
+const TEST_ADMIN = "admin"
+const TEST_SUPPLIER = "supplier1"
+const TEST_OWNER = "owner1"
+const TEST_OPERATOR = "operator1"
+const TEST_AUDITOR = "auditor1"
+
+//==============================================================================================================================
+//	 new_ledger - Deploys the chaincode with TEST_ADMIN as the first admin and registers one participant for each of the
+//				  supplier, owner, operator and auditor roles.
+//==============================================================================================================================
+func new_ledger(t *testing.T) *Ledger {
+
+	l := empty_ledger(t)
+
+	_, err := l.init(participant_json(TEST_ADMIN, ROLE_ADMIN))
+	if err != nil { t.Fatalf("init: %s", err) }
+
+	l.register(TEST_SUPPLIER, ROLE_SUPPLIER)
+	l.register(TEST_OWNER, ROLE_OWNER)
+	l.register(TEST_OPERATOR, ROLE_OPERATOR)
+	l.register(TEST_AUDITOR, ROLE_AUDITOR)
+
+	return l
+}
+
+func empty_ledger(t *testing.T) *Ledger {
+	return &Ledger{t: t, cc: new(Chaincode), state: map[string][]byte{}, now: time.Unix(0, 0).UTC()}
+}
+
+func (l *Ledger) next_tx() string {
+	l.tx++
+	return "tx" + strconv.Itoa(l.tx)
+}
+
+func (l *Ledger) as(user string) *TestStub {
+	return &TestStub{state: l.state, user: user, txTime: l.now, events: map[string][]byte{}}
+}
+
+func (l *Ledger) transaction(user string) *TestStub {
+	s := l.as(user)
+	s.txID = l.next_tx()
+	return s
+}
+
+func (l *Ledger) init(args ...string) ([]byte, error) {
+	return l.cc.Init(l.transaction(""), "init", args)
+}
+
+func (l *Ledger) invoke(user string, function string, args ...string) ([]byte, error) {
+	return l.cc.Invoke(l.transaction(user), function, args)
+}
+
+func (l *Ledger) query(user string, function string, args ...string) ([]byte, error) {
+	return l.cc.Query(l.as(user), function, args)
+}
+
+func (l *Ledger) register(id string, roles ...string) {
+	_, err := l.invoke(TEST_ADMIN, "register_participant", participant_json(id, roles...))
+	if err != nil { l.t.Fatalf("register_participant %s: %s", id, err) }
+}
+
+//==============================================================================================================================
+//	 create - Creates the SupplyItem built by b as TEST_SUPPLIER and fails the test if it is refused.
+//==============================================================================================================================
+func (l *Ledger) create(b *SupplyItemBuilder) {
+	_, err := l.invoke(TEST_SUPPLIER, "create_supplyItem", b.json())
+	if err != nil { l.t.Fatalf("create_supplyItem %s: %s", b.fields["supplyItemID"], err) }
+}
+
+//==============================================================================================================================
+//	 put_raw - Writes bytes straight to the ledger under the key of a SupplyItem, bypassing the chaincode.
+//==============================================================================================================================
+func (l *Ledger) put_raw(supplyItemID string, bytes []byte) {
+	key, err := supplyItem_key(supplyItemID)
+	if err != nil { l.t.Fatal(err) }
+
+	l.state[key] = bytes
+}
+
+func participant_json(id string, roles ...string) string {
+	bytes, _ := json.Marshal(Participant{ParticipantID: id, Organization: "org1", Roles: roles})
+	return string(bytes)
+}
+
+//==============================================================================================================================
+//	SupplyItemBuilder - Builds the input for create_supplyItem. a_supplyItem starts from a valid SupplyItem; each method
+//						changes one field and returns the builder so calls can be chained.
+//==============================================================================================================================
+type SupplyItemBuilder struct {
+	fields map[string]interface{}
+}
+
+func a_supplyItem(supplyItemID string) *SupplyItemBuilder {
+	return &SupplyItemBuilder{fields: map[string]interface{}{
+		"supplyItemID":     supplyItemID,
+		"supplierID":       TEST_SUPPLIER,
+		"ownerID":          TEST_OWNER,
+		"operatorID":       TEST_OPERATOR,
+		"longitude":        -0.1276,
+		"latitude":         51.5072,
+		"description":      "Test item " + supplyItemID,
+		"materialType":     "steel",
+		"materialQuantity": 12.5,
+		"unitOfMeasure":    "KGM",
+	}}
+}
+
+func (b *SupplyItemBuilder) with(field string, value interface{}) *SupplyItemBuilder {
+	b.fields[field] = value
+	return b
+}
+
+func (b *SupplyItemBuilder) without(field string) *SupplyItemBuilder {
+	delete(b.fields, field)
+	return b
+}
+
+func (b *SupplyItemBuilder) owner(id string) *SupplyItemBuilder       { return b.with("ownerID", id) }
+func (b *SupplyItemBuilder) operator(id string) *SupplyItemBuilder    { return b.with("operatorID", id) }
+func (b *SupplyItemBuilder) quantity(qty float64) *SupplyItemBuilder  { return b.with("materialQuantity", qty) }
+func (b *SupplyItemBuilder) unit(code string) *SupplyItemBuilder      { return b.with("unitOfMeasure", code) }
+
+func (b *SupplyItemBuilder) json() string {
+	bytes, _ := json.Marshal(b.fields)
+	return string(bytes)
+}
+
+//==============================================================================================================================
+//	 args - The 11 positional arguments of create_supplyItem for the same SupplyItem.
+//==============================================================================================================================
+func (b *SupplyItemBuilder) args() []string {
+	value := func(field string) string {
+		switch v := b.fields[field].(type) {
+		case string:
+			return v
+		case float64:
+			return strconv.FormatFloat(v, 'f', -1, 64)
+		}
+		return ""
+	}
+	return []string{value("supplyItemID"), value("supplierID"), value("operatorID"), value("ownerID"), value("longitude"),
+		value("latitude"), value("description"), value("materialType"), value("materialQuantity"), value("unitOfMeasure"), ""}
+}
+
+//==============================================================================================================================
+//	 decode_page / decode_supplyItem - Unmarshal query responses, failing the test if they are not valid.
+//==============================================================================================================================
+func decode_page(t *testing.T, bytes []byte) (Page, []SupplyItem) {
+
+	var page Page
+	if err := json.Unmarshal(bytes, &page); err != nil { t.Fatalf("not a Page: %s: %s", err, bytes) }
+
+	items := make([]SupplyItem, len(page.Items))
+	for i, raw := range page.Items {
+		if err := json.Unmarshal(raw, &items[i]); err != nil { t.Fatalf("not a SupplyItem: %s: %s", err, raw) }
+	}
+	return page, items
+}
+
+func decode_supplyItem(t *testing.T, bytes []byte) SupplyItem {
+	var sItem SupplyItem
+	if err := json.Unmarshal(bytes, &sItem); err != nil { t.Fatalf("not a SupplyItem: %s: %s", err, bytes) }
+	return sItem
+}
@@ -0,0 +1,540 @@
+/*
+Copyright IBM Corp 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package supplychain
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/vsagineedu/learn-chaincode/ccerror"
+)
+
+//==============================================================================================================================
+//	Geohash index - Each SupplyItem has an empty record under (GEO_KEY_TYPE, geohash, supplyItemID), where geohash is
+//					the GEOHASH_PRECISION character geohash of its position. Area queries cover the area with geohash
+//					cells, range query the keys below each cell and then check the exact position of each SupplyItem.
+//==============================================================================================================================
+const GEO_KEY_TYPE = "geo"
+const LOCATION_KEY_TYPE = "location"
+
+const GEOHASH_PRECISION = 8				// About 38m x 19m at the equator
+const GEOHASH_ALPHABET = "0123456789bcdefghjkmnpqrstuvwxyz"
+const MAX_GEO_CELLS = 64				// Upper bound on the cells searched by one area query
+const MAX_GEO_RESULTS = MAX_PAGE_SIZE
+const EARTH_RADIUS_METRES = 6371008.8
+
+//==============================================================================================================================
+//	LocationEntry - One position in the trail of a SupplyItem, keyed by the revision that moved it there.
+//==============================================================================================================================
+type LocationEntry struct {
+	SupplyItemID string  `json:"supplyItemID"`
+	Revision     int     `json:"revision"`
+	Longitude    float64 `json:"longitude"`
+	Latitude     float64 `json:"latitude"`
+	Timestamp    string  `json:"timestamp"`
+	Actor        string  `json:"actor"`
+	TxID         string  `json:"txID"`
+}
+
+//==============================================================================================================================
+//	GeoResult - The response of the area queries. Each call returns at most MAX_GEO_RESULTS SupplyItems. NextCursor is
+//				set when there may be more; pass it back to continue the search.
+//==============================================================================================================================
+type GeoResult struct {
+	Items      []json.RawMessage `json:"items"`
+	NextCursor string            `json:"nextCursor"`
+}
+
+//==============================================================================================================================
+//	GeoBox - A latitude and longitude range. MinLon is never greater than MaxLon; a search that crosses the
+//			 antimeridian is made of two boxes.
+//==============================================================================================================================
+type GeoBox struct {
+	MinLat float64
+	MinLon float64
+	MaxLat float64
+	MaxLon float64
+}
+
+func (b GeoBox) contains(sItem SupplyItem) bool {
+	return sItem.Latitude >= b.MinLat && sItem.Latitude <= b.MaxLat && sItem.Longitude >= b.MinLon && sItem.Longitude <= b.MaxLon
+}
+
+//==============================================================================================================================
+//	 encode_geohash - Returns the geohash of a position to the given number of characters.
+//==============================================================================================================================
+func encode_geohash(latitude float64, longitude float64, precision int) string {
+
+	latRange := [2]float64{-90, 90}
+	lonRange := [2]float64{-180, 180}
+
+	hash := make([]byte, 0, precision)
+	even := true
+	bit, ch := 0, 0
+
+	for len(hash) < precision {
+		r, value := &latRange, latitude
+		if even {
+			r, value = &lonRange, longitude
+		}
+
+		mid := (r[0] + r[1]) / 2
+		ch <<= 1
+		if value >= mid {
+			ch |= 1
+			r[0] = mid
+		} else {
+			r[1] = mid
+		}
+		even = !even
+
+		if bit++; bit == 5 {
+			hash = append(hash, GEOHASH_ALPHABET[ch])
+			bit, ch = 0, 0
+		}
+	}
+
+	return string(hash)
+}
+
+//==============================================================================================================================
+//	 geohash_cell_size - Returns the height and width in degrees of a geohash cell of the given precision.
+//==============================================================================================================================
+func geohash_cell_size(precision int) (float64, float64) {
+	bits := 5 * precision
+	lonBits := (bits + 1) / 2
+	latBits := bits / 2
+	return 180 / math.Pow(2, float64(latBits)), 360 / math.Pow(2, float64(lonBits))
+}
+
+//==============================================================================================================================
+//	 cover_box - Returns geohash cells that together cover the box, using the longest cells that keep their number
+//				 within MAX_GEO_CELLS.
+//==============================================================================================================================
+func cover_box(minLat float64, minLon float64, maxLat float64, maxLon float64) []string {
+
+	precision := 1
+	for p := GEOHASH_PRECISION; p >= 1; p-- {
+		height, width := geohash_cell_size(p)
+		if (math.Floor((maxLat-minLat)/height)+2)*(math.Floor((maxLon-minLon)/width)+2) <= MAX_GEO_CELLS {
+			precision = p
+			break
+		}
+	}
+
+	height, width := geohash_cell_size(precision)
+
+	seen := map[string]bool{}
+	cells := []string{}
+
+	for lat := minLat; ; lat += height {
+		lat = math.Min(lat, maxLat)
+		for lon := minLon; ; lon += width {
+			lon = math.Min(lon, maxLon)
+			if cell := encode_geohash(lat, lon, precision); !seen[cell] {
+				seen[cell] = true
+				cells = append(cells, cell)
+			}
+			if lon >= maxLon { break }
+		}
+		if lat >= maxLat { break }
+	}
+
+	sort.Strings(cells)
+	return cells
+}
+
+//==============================================================================================================================
+//	 validate_coordinates - Adds a field error to verr for a longitude or latitude outside its range.
+//==============================================================================================================================
+func validate_coordinates(verr *ccerror.Error, longitude float64, latitude float64) {
+	if math.IsNaN(longitude) || longitude < -180 || longitude > 180 {
+		verr.Add("longitude", "Must be between -180 and 180")
+	}
+	if math.IsNaN(latitude) || latitude < -90 || latitude > 90 {
+		verr.Add("latitude", "Must be between -90 and 90")
+	}
+}
+
+func parse_coordinate(verr *ccerror.Error, field string, value string) float64 {
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil { verr.Add(field, "Must be a number"); return 0 }
+	return f
+}
+
+func geo_key(sItem SupplyItem) (string, error) {
+	return create_composite_key(GEO_KEY_TYPE, []string{encode_geohash(sItem.Latitude, sItem.Longitude, GEOHASH_PRECISION), sItem.SupplyItemID})
+}
+
+//==============================================================================================================================
+//	 update_geo_index - Moves the geohash index entry of a SupplyItem whose position changed. A nil before adds it.
+//==============================================================================================================================
+func update_geo_index(stub Stub, before *SupplyItem, after SupplyItem) error {
+
+	newKey, err := geo_key(after)
+	if err != nil { return err }
+
+	if before != nil {
+		oldKey, err := geo_key(*before)
+		if err != nil { return err }
+
+		if oldKey == newKey { return nil }
+
+		err = stub.DelState(oldKey)
+		if err != nil { return errors.New("Unable to remove geohash index entry for " + after.SupplyItemID) }
+	}
+
+	err = stub.PutState(newKey, index_value)
+	if err != nil { return errors.New("Unable to store geohash index entry for " + after.SupplyItemID) }
+
+	return nil
+}
+
+//==============================================================================================================================
+//	 append_location - Adds the current position of sItem to its trail if it is new or has moved.
+//==============================================================================================================================
+func append_location(stub Stub, before *SupplyItem, after SupplyItem, actor string) error {
+
+	if before != nil && before.Longitude == after.Longitude && before.Latitude == after.Latitude { return nil }
+
+	txTime, err := get_tx_time(stub)
+	if err != nil { return err }
+
+	entry := LocationEntry{
+		SupplyItemID: after.SupplyItemID,
+		Revision:     after.Revision,
+		Longitude:    after.Longitude,
+		Latitude:     after.Latitude,
+		Timestamp:    txTime.Format(time.RFC3339Nano),
+		Actor:        actor,
+		TxID:         stub.GetTxID(),
+	}
+
+	key, err := create_composite_key(LOCATION_KEY_TYPE, []string{after.SupplyItemID, fmt.Sprintf("%010d", after.Revision)})
+	if err != nil { return err }
+
+	bytes, err := json.Marshal(entry)
+	if err != nil { fmt.Printf("APPEND_LOCATION: Error converting location record: %s", err); return errors.New("Error converting location record") }
+
+	err = stub.PutState(key, bytes)
+	if err != nil { fmt.Printf("APPEND_LOCATION: Error storing location record: %s", err); return errors.New("Error storing location record") }
+
+	return nil
+}
+
+//=================================================================================================================================
+//	 update_location - Moves a SupplyItem to a new position. May be called by the owner or the operator.
+//=================================================================================================================================
+func (t *Chaincode) update_location(stub Stub, caller string, args []string) ([]byte, error) {
+
+	//Args
+	//		0				1			2
+	//	supplyItemID	longitude	latitude
+
+	if len(args) != 3 { return nil, ccerror.New(ccerror.INVALID_ARGUMENT, "UPDATE_LOCATION: Incorrect number of arguments. Expecting supplyItemID, longitude and latitude") }
+
+	verr := new_validation_error("update_location")
+	longitude := parse_coordinate(verr, "longitude", args[1])
+	latitude := parse_coordinate(verr, "latitude", args[2])
+	if !verr.Has("longitude") && !verr.Has("latitude") {
+		validate_coordinates(verr, longitude, latitude)
+	}
+	if err := verr.Result(); err != nil { return nil, err }
+
+	sItem, err := t.retrieve_SupplyItem(stub, args[0])
+	if err != nil { fmt.Printf("UPDATE_LOCATION: Error retrieving supplyItem: %s", err); return nil, err }
+
+	if sItem.OwnerID != caller && sItem.OperatorID != caller { return nil, permission_denied("update_location") }
+
+	err = check_transition(sItem, "update_location", current_status(sItem))
+	if err != nil { return nil, err }
+
+	sItem.Longitude, sItem.Latitude = longitude, latitude
+
+	_, err = t.save_changes(stub, sItem, caller, "update_location")
+	if err != nil { fmt.Printf("UPDATE_LOCATION: Error saving changes: %s", err); return nil, err }
+
+	return nil, nil
+}
+
+//=================================================================================================================================
+//	 get_location_trail - Returns every recorded position of a SupplyItem, oldest first.
+//=================================================================================================================================
+func (t *Chaincode) get_location_trail(stub Stub, caller string, supplyItemID string) ([]byte, error) {
+
+	sItem, err := t.retrieve_SupplyItem(stub, supplyItemID)
+	if err != nil { return nil, err }
+
+	if sItem.OwnerID != caller && sItem.OperatorID != caller && !t.reads_all(stub, caller) { return nil, permission_denied("get_location_trail") }
+
+	iter, err := range_query_composite_key(stub, LOCATION_KEY_TYPE, []string{supplyItemID})
+	if err != nil { return nil, errors.New("Unable to query the ledger") }
+	defer iter.Close()
+
+	trail := []LocationEntry{}
+
+	for iter.HasNext() {
+		_, bytes, err := iter.Next()
+		if err != nil { return nil, errors.New("Unable to query the ledger") }
+
+		var entry LocationEntry
+		err = json.Unmarshal(bytes, &entry)
+		if err != nil { return nil, ccerror.New(ccerror.CORRUPT_RECORD, "Corrupt location record " + string(bytes)) }
+
+		trail = append(trail, entry)
+	}
+
+	return json.Marshal(trail)
+}
+
+//==============================================================================================================================
+//	 cover_boxes - Returns the geohash cells covering every box, in key order. A cell inside another cell of the list
+//				   is dropped so that no index entry is visited twice.
+//==============================================================================================================================
+func cover_boxes(boxes []GeoBox) []string {
+
+	all := []string{}
+	for _, b := range boxes {
+		all = append(all, cover_box(b.MinLat, b.MinLon, b.MaxLat, b.MaxLon)...)
+	}
+	sort.Strings(all)
+
+	cells := []string{}
+	for _, cell := range all {
+		if len(cells) > 0 && strings.HasPrefix(cell, cells[len(cells)-1]) { continue }		// Sorted, so any enclosing cell is the previous one kept
+		cells = append(cells, cell)
+	}
+	return cells
+}
+
+//==============================================================================================================================
+//	 find_in_boxes - Walks the geohash index of the cells covering the boxes in key order, starting after the cursor, and
+//					 passes each SupplyItem inside one of the boxes to visit, which reports whether it was kept. Stops
+//					 once MAX_GEO_RESULTS are kept, or after MAX_SCAN_FACTOR times as many were read, and returns the
+//					 cursor to continue from, or "" when every cell has been walked.
+//==============================================================================================================================
+func (t *Chaincode) find_in_boxes(stub Stub, boxes []GeoBox, cursor string, includeArchived bool, visit func(sItem SupplyItem) (bool, error)) (string, error) {
+
+	prefix, err := create_composite_key(GEO_KEY_TYPE, []string{})
+	if err != nil { return "", err }
+
+	after := ""
+	if cursor != "" {
+		after, err = decode_cursor(cursor, prefix)
+		if err != nil { return "", err }
+	}
+
+	kept, scanned := 0, 0
+	lastKey := ""
+
+	for _, cell := range cover_boxes(boxes) {
+
+		startKey, endKey := prefix+cell, prefix+cell+MAX_UNICODE_RUNE
+		if after >= endKey { continue }						// Walked by an earlier page
+		if after > startKey {
+			startKey = after
+		}
+
+		iter, err := stub.RangeQueryState(startKey, endKey)
+		if err != nil { return "", errors.New("Unable to query the ledger") }
+
+		for iter.HasNext() {
+			if kept >= MAX_GEO_RESULTS || scanned >= MAX_GEO_RESULTS*MAX_SCAN_FACTOR {
+				iter.Close()
+				return encode_cursor(lastKey), nil
+			}
+
+			key, _, err := iter.Next()
+			if err != nil { iter.Close(); return "", errors.New("Unable to query the ledger") }
+
+			if key == after { continue }					// The cursor names the last entry of the previous page
+
+			_, parts, err := split_composite_key(key)
+			if err != nil || len(parts) != 2 { iter.Close(); return "", ccerror.New(ccerror.CORRUPT_RECORD, "Corrupt geohash index entry " + key) }
+
+			sItem, err := t.retrieve_SupplyItem(stub, parts[1])
+			if err != nil { iter.Close(); return "", err }
+
+			scanned++
+			lastKey = key
+
+			if is_archived(sItem) && !includeArchived { continue }
+
+			for _, b := range boxes {
+				if !b.contains(sItem) { continue }
+
+				included, err := visit(sItem)
+				if err != nil { iter.Close(); return "", err }
+
+				if included { kept++ }
+				break
+			}
+		}
+		iter.Close()
+	}
+
+	return "", nil
+}
+
+//==============================================================================================================================
+//	 geo_boxes - Returns the boxes covering a longitude range that may run past -180 or 180, split at the antimeridian.
+//==============================================================================================================================
+func geo_boxes(minLat float64, minLon float64, maxLat float64, maxLon float64) []GeoBox {
+
+	if maxLon-minLon >= 360 {
+		return []GeoBox{{minLat, -180, maxLat, 180}}
+	}
+	if minLon < -180 {
+		return []GeoBox{{minLat, -180, maxLat, maxLon}, {minLat, minLon + 360, maxLat, 180}}
+	}
+	if maxLon > 180 {
+		return []GeoBox{{minLat, minLon, maxLat, 180}, {minLat, -180, maxLat, maxLon - 360}}
+	}
+	return []GeoBox{{minLat, minLon, maxLat, maxLon}}
+}
+
+//=================================================================================================================================
+//	 find_supplyItems_in_box - Returns the SupplyItems the caller can see whose position is inside a bounding box, in
+//							   geohash order and at most MAX_GEO_RESULTS per call. The box may not cross the
+//							   antimeridian.
+//=================================================================================================================================
+func (t *Chaincode) find_supplyItems_in_box(stub Stub, caller string, args []string) ([]byte, error) {
+
+	//Args
+	//		0			1			2			3				4							5
+	//	minLongitude	minLatitude	maxLongitude	maxLatitude	includeArchived (optional)	cursor (optional)
+
+	if len(args) < 4 || len(args) > 6 { return nil, ccerror.New(ccerror.INVALID_ARGUMENT, "FIND_SUPPLYITEMS_IN_BOX: Incorrect number of arguments. Expecting minLongitude, minLatitude, maxLongitude and maxLatitude") }
+
+	verr := new_validation_error("find_supplyItems_in_box")
+	minLon := parse_coordinate(verr, "minLongitude", args[0])
+	minLat := parse_coordinate(verr, "minLatitude", args[1])
+	maxLon := parse_coordinate(verr, "maxLongitude", args[2])
+	maxLat := parse_coordinate(verr, "maxLatitude", args[3])
+	if err := verr.Result(); err != nil { return nil, err }
+
+	validate_coordinates(verr, minLon, minLat)
+	validate_coordinates(verr, maxLon, maxLat)
+	if maxLon < minLon { verr.Add("maxLongitude", "Must not be less than minLongitude") }
+	if maxLat < minLat { verr.Add("maxLatitude", "Must not be less than minLatitude") }
+	if err := verr.Result(); err != nil { return nil, err }
+
+	viewer, err := t.new_viewer(stub, caller)
+	if err != nil { return nil, err }
+
+	result := GeoResult{Items: []json.RawMessage{}}
+
+	result.NextCursor, err = t.find_in_boxes(stub, []GeoBox{{minLat, minLon, maxLat, maxLon}}, cursor_arg(args, 5), parse_flag(args, 4), func(sItem SupplyItem) (bool, error) {
+
+		temp, err := view_supplyItem(viewer, sItem, "find_supplyItems_in_box")
+		if err != nil { return false, nil }
+
+		result.Items = append(result.Items, temp)
+		return true, nil
+	})
+	if err != nil { return nil, err }
+
+	return json.Marshal(result)
+}
+
+//==============================================================================================================================
+//	 distance_metres - The great circle distance between two positions.
+//==============================================================================================================================
+func distance_metres(lat1 float64, lon1 float64, lat2 float64, lon2 float64) float64 {
+	rad := math.Pi / 180
+	dLat := (lat2 - lat1) * rad
+	dLon := (lon2 - lon1) * rad
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(lat1*rad)*math.Cos(lat2*rad)*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * EARTH_RADIUS_METRES * math.Asin(math.Min(1, math.Sqrt(a)))
+}
+
+type by_distance struct {
+	items     []json.RawMessage
+	distances []float64
+}
+
+func (d by_distance) Len() int           { return len(d.items) }
+func (d by_distance) Less(i, j int) bool { return d.distances[i] < d.distances[j] }
+func (d by_distance) Swap(i, j int) {
+	d.items[i], d.items[j] = d.items[j], d.items[i]
+	d.distances[i], d.distances[j] = d.distances[j], d.distances[i]
+}
+
+//=================================================================================================================================
+//	 find_supplyItems_near - Returns the SupplyItems the caller can see within a radius of a point, at most
+//							 MAX_GEO_RESULTS per call and nearest first within each call. The search area is clipped at
+//							 the poles and wraps around the antimeridian.
+//=================================================================================================================================
+func (t *Chaincode) find_supplyItems_near(stub Stub, caller string, args []string) ([]byte, error) {
+
+	//Args
+	//		0			1			2					3							4
+	//	longitude	latitude	radius in metres	includeArchived (optional)	cursor (optional)
+
+	if len(args) < 3 || len(args) > 5 { return nil, ccerror.New(ccerror.INVALID_ARGUMENT, "FIND_SUPPLYITEMS_NEAR: Incorrect number of arguments. Expecting longitude, latitude and radius") }
+
+	verr := new_validation_error("find_supplyItems_near")
+	longitude := parse_coordinate(verr, "longitude", args[0])
+	latitude := parse_coordinate(verr, "latitude", args[1])
+	radius := parse_coordinate(verr, "radius", args[2])
+	if err := verr.Result(); err != nil { return nil, err }
+
+	validate_coordinates(verr, longitude, latitude)
+	if math.IsNaN(radius) || radius <= 0 || radius > math.Pi*EARTH_RADIUS_METRES { verr.Add("radius", "Must be greater than 0 and at most half the Earth's circumference") }
+	if err := verr.Result(); err != nil { return nil, err }
+
+	angle := radius / EARTH_RADIUS_METRES
+	dLat := angle * 180 / math.Pi
+	dLon := 180.0
+	if cos := math.Cos(latitude * math.Pi / 180); math.Sin(angle) < cos {			// Otherwise the circle contains a pole
+		dLon = math.Asin(math.Sin(angle)/cos) * 180 / math.Pi
+	}
+
+	viewer, err := t.new_viewer(stub, caller)
+	if err != nil { return nil, err }
+
+	result := GeoResult{Items: []json.RawMessage{}}
+	near := by_distance{}
+
+	boxes := geo_boxes(math.Max(-90, latitude-dLat), longitude-dLon, math.Min(90, latitude+dLat), longitude+dLon)
+
+	result.NextCursor, err = t.find_in_boxes(stub, boxes, cursor_arg(args, 4), parse_flag(args, 3), func(sItem SupplyItem) (bool, error) {
+
+		d := distance_metres(latitude, longitude, sItem.Latitude, sItem.Longitude)
+		if d > radius { return false, nil }
+
+		temp, err := view_supplyItem(viewer, sItem, "find_supplyItems_near")
+		if err != nil { return false, nil }
+
+		near.items = append(near.items, temp)
+		near.distances = append(near.distances, d)
+		return true, nil
+	})
+	if err != nil { return nil, err }
+
+	sort.Stable(near)
+
+	result.Items = append(result.Items, near.items...)
+
+	return json.Marshal(result)
+}
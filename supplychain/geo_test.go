@@ -0,0 +1,152 @@
+/*
+Copyright IBM Corp 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+
+package supplychain
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func decode_geo_result(t *testing.T, bytes []byte) (GeoResult, []string) {
+
+	var result GeoResult
+	if err := json.Unmarshal(bytes, &result); err != nil { t.Fatalf("not a GeoResult: %s: %s", err, bytes) }
+
+	ids := []string{}
+	for _, raw := range result.Items {
+		var sItem SupplyItem
+		if err := json.Unmarshal(raw, &sItem); err != nil { t.Fatalf("not a SupplyItem: %s: %s", err, raw) }
+		ids = append(ids, sItem.SupplyItemID)
+	}
+	return result, ids
+}
+
+func TestFindSupplyItemsNear(t *testing.T) {
+
+	tests := []struct {
+		name string
+		args []string
+		want string
+		err  string
+	}{
+		{"nearest first",               []string{"-0.1276", "51.5072", "5000"},   "LONDON,LONDON2", ""},
+		{"radius excludes",             []string{"-0.1276", "51.5072", "10"},     "LONDON",         ""},
+		{"east of the antimeridian",    []string{"179.9999", "0", "1000"},        "EAST,WEST",      ""},
+		{"west of the antimeridian",    []string{"-179.9999", "0", "1000"},       "WEST,EAST",      ""},
+		{"negative radius",             []string{"-0.1276", "51.5072", "-1"},     "",               `"field":"radius"`},
+		{"latitude out of range",       []string{"0", "91", "10"},                "",               `"field":"latitude"`},
+		{"bad cursor",                  []string{"0", "0", "10", "", "bm9wZQ=="}, "",               "Invalid cursor"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			l := new_ledger(t)
+			l.create(a_supplyItem("LONDON"))
+			l.create(a_supplyItem("LONDON2").with("longitude", -0.1).with("latitude", 51.5))
+			l.create(a_supplyItem("EAST").with("longitude", 179.9998).with("latitude", 0))
+			l.create(a_supplyItem("WEST").with("longitude", -179.9997).with("latitude", 0))
+
+			bytes, err := l.query(TEST_AUDITOR, "find_supplyItems_near", tc.args...)
+			check_error(t, err, tc.err)
+			if tc.err != "" { return }
+
+			result, ids := decode_geo_result(t, bytes)
+			if strings.Join(ids, ",") != tc.want { t.Errorf("got %v, want %s", ids, tc.want) }
+			if result.NextCursor != "" { t.Errorf("nextCursor %q", result.NextCursor) }
+		})
+	}
+}
+
+func TestFindSupplyItemsInBoxPages(t *testing.T) {
+
+	l := new_ledger(t)
+	for i := 0; i <= MAX_GEO_RESULTS; i++ {
+		l.create(a_supplyItem(fmt.Sprintf("P%04d", i)).with("longitude", 10+float64(i%40)*0.01).with("latitude", 20+float64(i/40)*0.01))
+	}
+	l.create(a_supplyItem("OUTSIDE").with("longitude", 11.5).with("latitude", 20))
+
+	bytes, err := l.query(TEST_AUDITOR, "find_supplyItems_in_box", "10", "20", "11", "21")
+	check_error(t, err, "")
+
+	first, ids := decode_geo_result(t, bytes)
+	if len(ids) != MAX_GEO_RESULTS || first.NextCursor == "" { t.Fatalf("first page has %d items, nextCursor %q", len(ids), first.NextCursor) }
+
+	bytes, err = l.query(TEST_AUDITOR, "find_supplyItems_in_box", "10", "20", "11", "21", "", first.NextCursor)
+	check_error(t, err, "")
+
+	second, more := decode_geo_result(t, bytes)
+	if len(more) != 1 || second.NextCursor != "" { t.Fatalf("second page %v, nextCursor %q", more, second.NextCursor) }
+
+	ids = append(ids, more...)
+	sort.Strings(ids)
+	for i, id := range ids {
+		if id != fmt.Sprintf("P%04d", i) { t.Fatalf("pages hold %s at %d", id, i) }
+	}
+
+	_, err = l.query(TEST_AUDITOR, "find_supplyItems_in_box", "11", "20", "10", "21")
+	check_error(t, err, `"field":"maxLongitude"`)
+}
+
+func TestLocationTrail(t *testing.T) {
+
+	l := new_ledger(t)
+	l.register("owner2", ROLE_OWNER)
+	l.create(a_supplyItem("A1"))
+
+	moves := []struct {
+		caller   string
+		function string
+		args     []string
+		want     string
+	}{
+		{TEST_OPERATOR, "update_location",   []string{"A1", "2.3522", "48.8566"},       ""},
+		{TEST_OWNER,    "update_supplyItem", []string{"A1", `{"description":"Moved"}`}, ""},
+		{TEST_OWNER,    "update_location",   []string{"A1", "2.3522", "48.8566"},       ""},
+		{TEST_OWNER,    "update_location",   []string{"A1", "13.405", "52.52"},         ""},
+		{TEST_OWNER,    "update_location",   []string{"A1", "13.405", "91"},            `"field":"latitude"`},
+		{TEST_OWNER,    "update_location",   []string{"A1", "east", "52.52"},           `"field":"longitude"`},
+		{TEST_AUDITOR,  "update_location",   []string{"A1", "0", "0"},                  `"code":"PERMISSION_DENIED"`},
+		{TEST_OWNER,    "update_location",   []string{"NOPE", "0", "0"},                `"code":"NOT_FOUND"`},
+	}
+
+	for _, m := range moves {
+		_, err := l.invoke(m.caller, m.function, m.args...)
+		check_error(t, err, m.want)
+	}
+
+	bytes, err := l.query(TEST_AUDITOR, "get_location_trail", "A1")
+	if err != nil { t.Fatal(err) }
+
+	var trail []LocationEntry
+	if err := json.Unmarshal(bytes, &trail); err != nil { t.Fatal(err) }
+
+	got := []string{}
+	for _, entry := range trail {
+		got = append(got, fmt.Sprintf("%d %s %g,%g", entry.Revision, entry.Actor, entry.Longitude, entry.Latitude))
+	}
+	want := "1 " + TEST_SUPPLIER + " -0.1276,51.5072|2 " + TEST_OPERATOR + " 2.3522,48.8566|5 " + TEST_OWNER + " 13.405,52.52"
+	if strings.Join(got, "|") != want { t.Errorf("trail = %s, want %s", strings.Join(got, "|"), want) }
+
+	if sItem := get_supplyItem(t, l, "A1"); sItem.Longitude != 13.405 || sItem.Latitude != 52.52 { t.Errorf("position = %g,%g", sItem.Longitude, sItem.Latitude) }
+
+	_, err = l.query("owner2", "get_location_trail", "A1")
+	check_error(t, err, `"code":"PERMISSION_DENIED"`)
+}
@@ -0,0 +1,187 @@
+/*
+Copyright IBM Corp 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package supplychain
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/vsagineedu/learn-chaincode/ccerror"
+)
+
+const HISTORY_KEY_TYPE = "history"
+
+//==============================================================================================================================
+//	HistoryEntry - An immutable record of one change to a SupplyItem. Entries are written under the history key prefix
+//				   of the SupplyItem with the revision number zero padded, so a range query returns them in order.
+//==============================================================================================================================
+type HistoryEntry struct {
+	SupplyItemID string        `json:"supplyItemID"`
+	Revision     int           `json:"revision"`
+	TxID         string        `json:"txID"`
+	Timestamp    string        `json:"timestamp"`
+	Actor        string        `json:"actor"`
+	Action       string        `json:"action"`
+	Changes      []FieldChange `json:"changes"`
+}
+
+//==============================================================================================================================
+//	FieldChange - The value of a single SupplyItem field before and after a change. Before is null on creation.
+//==============================================================================================================================
+type FieldChange struct {
+	Field  string          `json:"field"`
+	Before json.RawMessage `json:"before"`
+	After  json.RawMessage `json:"after"`
+}
+
+func history_key(supplyItemID string, revision int) (string, error) {
+	return create_composite_key(HISTORY_KEY_TYPE, []string{supplyItemID, fmt.Sprintf("%010d", revision)})
+}
+
+//==============================================================================================================================
+//	 get_tx_time - Returns the timestamp of the current transaction in UTC.
+//==============================================================================================================================
+func get_tx_time(stub Stub) (time.Time, error) {
+
+	ts, err := stub.GetTxTime()
+	if err != nil { return time.Time{}, errors.New("Unable to get transaction timestamp") }
+
+	return ts.UTC(), nil
+}
+
+//==============================================================================================================================
+//	 diff_supplyItems - Lists the fields whose JSON value differs between before and after. A nil before lists every field
+//						of after. The revision counter itself is not reported.
+//==============================================================================================================================
+func diff_supplyItems(before *SupplyItem, after SupplyItem) ([]FieldChange, error) {
+
+	beforeFields := map[string]json.RawMessage{}
+	afterFields := map[string]json.RawMessage{}
+
+	if before != nil {
+		if err := to_field_map(*before, &beforeFields); err != nil { return nil, err }
+	}
+	if err := to_field_map(after, &afterFields); err != nil { return nil, err }
+
+	var names []string
+	for name := range afterFields {
+		names = append(names, name)
+	}
+	for name := range beforeFields {
+		if _, ok := afterFields[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	changes := []FieldChange{}
+
+	for _, name := range names {
+		if name == "revision" { continue }
+
+		old, ok := beforeFields[name]
+		if !ok { old = json.RawMessage("null") }
+
+		current, ok := afterFields[name]
+		if !ok { current = json.RawMessage("null") }
+
+		if !bytes.Equal(old, current) {
+			changes = append(changes, FieldChange{Field: name, Before: old, After: current})
+		}
+	}
+
+	return changes, nil
+}
+
+func to_field_map(value interface{}, fields *map[string]json.RawMessage) error {
+	bytes, err := json.Marshal(value)
+	if err != nil { return err }
+	return json.Unmarshal(bytes, fields)
+}
+
+//==============================================================================================================================
+//	 append_history - Writes the HistoryEntry describing the change from before to after and returns its changes.
+//					  after.Revision must already be the new revision number.
+//==============================================================================================================================
+func (t *Chaincode) append_history(stub Stub, before *SupplyItem, after SupplyItem, actor string, action string) ([]FieldChange, error) {
+
+	changes, err := diff_supplyItems(before, after)
+	if err != nil { fmt.Printf("APPEND_HISTORY: Error comparing supplyitem records: %s", err); return nil, errors.New("Error comparing supplyitem records") }
+
+	txTime, err := get_tx_time(stub)
+	if err != nil { return nil, err }
+
+	entry := HistoryEntry{
+		SupplyItemID: after.SupplyItemID,
+		Revision:     after.Revision,
+		TxID:         stub.GetTxID(),
+		Timestamp:    txTime.Format(time.RFC3339Nano),
+		Actor:        actor,
+		Action:       action,
+		Changes:      changes,
+	}
+
+	key, err := history_key(after.SupplyItemID, after.Revision)
+	if err != nil { return nil, err }
+
+	existing, err := stub.GetState(key)
+	if err != nil { return nil, errors.New("Unable to check history record") }
+	if existing != nil { return nil, ccerror.New(ccerror.ALREADY_EXISTS, "History entry already exists for " + after.SupplyItemID + " revision " + fmt.Sprint(after.Revision)) }
+
+	bytes, err := json.Marshal(entry)
+	if err != nil { fmt.Printf("APPEND_HISTORY: Error converting history record: %s", err); return nil, errors.New("Error converting history record") }
+
+	err = stub.PutState(key, bytes)
+	if err != nil { fmt.Printf("APPEND_HISTORY: Error storing history record: %s", err); return nil, errors.New("Error storing history record") }
+
+	return changes, nil
+}
+
+//=================================================================================================================================
+//	 get_supplyItem_history - Returns every HistoryEntry of a SupplyItem, oldest first. Only the current owner may read it.
+//							  The history of a purged SupplyItem is kept, and the ReadAll roles may still read it.
+//=================================================================================================================================
+func (t *Chaincode) get_supplyItem_history(stub Stub, caller string, supplyItemID string) ([]byte, error) {
+
+	sItem, err := t.retrieve_SupplyItem(stub, supplyItemID)
+	if err != nil && (ccerror.CodeOf(err) != ccerror.NOT_FOUND || !t.reads_all(stub, caller)) { return nil, err }
+
+	if err == nil && sItem.OwnerID != caller && !t.reads_all(stub, caller) { return nil, permission_denied("get_supplyItem_history") }
+
+	iter, err := range_query_composite_key(stub, HISTORY_KEY_TYPE, []string{supplyItemID})
+	if err != nil { return nil, errors.New("Unable to query supplyItem history") }
+	defer iter.Close()
+
+	entries := []HistoryEntry{}
+
+	for iter.HasNext() {
+		_, bytes, err := iter.Next()
+		if err != nil { return nil, errors.New("Unable to query supplyItem history") }
+
+		var entry HistoryEntry
+		err = json.Unmarshal(bytes, &entry)
+		if err != nil { return nil, ccerror.New(ccerror.CORRUPT_RECORD, "Corrupt history record " + string(bytes)) }
+
+		entries = append(entries, entry)
+	}
+
+	return json.Marshal(entries)
+}
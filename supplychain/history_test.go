@@ -0,0 +1,59 @@
+/*
+Copyright IBM Corp 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package supplychain
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestSupplyItemHistory(t *testing.T) {
+
+	l := new_ledger(t)
+	l.register("owner2", ROLE_OWNER)
+	l.create(a_supplyItem("A1"))
+
+	if _, err := l.invoke(TEST_OPERATOR, "update_supplyItem", "A1", `{"description":"Rolled"}`); err != nil { t.Fatal(err) }
+	if _, err := l.invoke(TEST_OWNER, "update_status", "A1", string(STATUS_IN_STORAGE)); err != nil { t.Fatal(err) }
+
+	bytes, err := l.query(TEST_OWNER, "get_supplyItem_history", "A1")
+	if err != nil { t.Fatal(err) }
+
+	var entries []HistoryEntry
+	if err := json.Unmarshal(bytes, &entries); err != nil { t.Fatal(err) }
+
+	if len(entries) != 3 { t.Fatalf("history = %s", bytes) }
+
+	created, updated := entries[0], entries[1]
+	if created.Revision != 1 || created.Action != "create_supplyItem" || created.Actor != TEST_SUPPLIER || created.TxID == "" || created.Timestamp != "1970-01-01T00:00:00Z" { t.Errorf("created = %+v", created) }
+	for _, change := range created.Changes {
+		if string(change.Before) != "null" { t.Errorf("creation changed %s from %s", change.Field, change.Before) }
+	}
+
+	if updated.Revision != 2 || updated.Action != "update_supplyItem" || updated.Actor != TEST_OPERATOR || len(updated.Changes) != 1 { t.Fatalf("updated = %+v", updated) }
+	if change := updated.Changes[0]; change.Field != "description" || string(change.Before) != `"Test item A1"` || string(change.After) != `"Rolled"` { t.Errorf("change = %s %s %s", change.Field, change.Before, change.After) }
+
+	if stored := entries[2]; stored.Revision != 3 || stored.Action != "update_status" || stored.Changes[0].Field != "status" { t.Errorf("stored = %+v", stored) }
+
+	for _, caller := range []string{TEST_OPERATOR, "owner2"} {
+		_, err = l.query(caller, "get_supplyItem_history", "A1")
+		check_error(t, err, `"code":"PERMISSION_DENIED"`)
+	}
+
+	_, err = l.query(TEST_OWNER, "get_supplyItem_history", "NOPE")
+	check_error(t, err, `"code":"NOT_FOUND"`)
+}
@@ -0,0 +1,61 @@
+/*
+Copyright IBM Corp 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package supplychain
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/vsagineedu/learn-chaincode/ccerror"
+)
+
+//==============================================================================================================================
+//	Certificate attributes - The caller is the USERNAME_ATTRIBUTE of the transaction certificate. An active admin
+//							 Participant may act as another user, for testing, by sending {"impersonate": "<user>"}
+//							 as the caller metadata of the transaction.
+//==============================================================================================================================
+const USERNAME_ATTRIBUTE = "username"
+
+type CallerMetadata struct {
+	Impersonate string `json:"impersonate"`
+}
+
+//==============================================================================================================================
+//	 get_caller - Returns the user the transaction is acting for, taken from its certificate and never from arguments.
+//==============================================================================================================================
+func (t *Chaincode) get_caller(stub Stub) (string, error) {
+
+	username, err := stub.ReadCertAttribute(USERNAME_ATTRIBUTE)
+	if err != nil { fmt.Printf("GET_CALLER: Unable to read certificate attribute: %s", err); return "", errors.New("Unable to read the caller's certificate") }
+
+	if len(username) == 0 { return "", ccerror.New(ccerror.PERMISSION_DENIED, "The transaction certificate has no " + USERNAME_ATTRIBUTE + " attribute") }
+
+	metadata, err := stub.GetCallerMetadata()
+	if err != nil || len(metadata) == 0 { return string(username), nil }
+
+	var meta CallerMetadata
+	err = json.Unmarshal(metadata, &meta)
+	if err != nil || meta.Impersonate == "" { return string(username), nil }
+
+	err = t.check_admin(stub, string(username), "impersonate")
+	if err != nil { return "", ccerror.New(ccerror.PERMISSION_DENIED, "Permission Denied. Only an admin may impersonate another user") }
+
+	fmt.Printf("GET_CALLER: %s is impersonating %s", username, meta.Impersonate)
+
+	return meta.Impersonate, nil
+}
@@ -0,0 +1,224 @@
+/*
+Copyright IBM Corp 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package supplychain
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/vsagineedu/learn-chaincode/ccerror"
+)
+
+//==============================================================================================================================
+//	Secondary indexes - For each indexed field an empty record is kept under the composite key
+//						(INDEX_KEY_TYPE, field, value, supplyItemID), so the SupplyItems with a given value can be found
+//						with a range query instead of a full scan. save_changes keeps them up to date.
+//==============================================================================================================================
+const INDEX_KEY_TYPE = "index"
+
+var index_value = []byte{0x00}
+
+var indexed_fields = []string{"ownerID", "operatorID", "supplierID", "materialType"}
+
+func indexed_field_value(sItem SupplyItem, field string) string {
+	switch field {
+	case "ownerID":
+		return sItem.OwnerID
+	case "operatorID":
+		return sItem.OperatorID
+	case "supplierID":
+		return sItem.SupplierID
+	case "materialType":
+		return sItem.MaterialType
+	}
+	return ""
+}
+
+//==============================================================================================================================
+//	SupplyItemFilter - The argument of query_supplyItems. Every non-empty field must match. Archived SupplyItems only
+//					   match if IncludeArchived is set.
+//==============================================================================================================================
+type SupplyItemFilter struct {
+	OwnerID         string `json:"ownerID"`
+	OperatorID      string `json:"operatorID"`
+	SupplierID      string `json:"supplierID"`
+	MaterialType    string `json:"materialType"`
+	IncludeArchived bool   `json:"includeArchived"`
+}
+
+func (f SupplyItemFilter) value(field string) string {
+	return indexed_field_value(SupplyItem{OwnerID: f.OwnerID, OperatorID: f.OperatorID, SupplierID: f.SupplierID, MaterialType: f.MaterialType}, field)
+}
+
+func (f SupplyItemFilter) matches(sItem SupplyItem) bool {
+	if is_archived(sItem) && !f.IncludeArchived {
+		return false
+	}
+	for _, field := range indexed_fields {
+		if want := f.value(field); want != "" && indexed_field_value(sItem, field) != want {
+			return false
+		}
+	}
+	return true
+}
+
+//==============================================================================================================================
+//	 update_indexes - Moves the index entries of every indexed field whose value differs between before and after, and
+//					  the geohash and archive index entries if the position or archive time differs. A nil before adds
+//					  entries for every field of after.
+//					  The holding totals are moved to match after.
+//==============================================================================================================================
+func update_indexes(stub Stub, before *SupplyItem, after SupplyItem) error {
+
+	for _, field := range indexed_fields {
+
+		newValue := indexed_field_value(after, field)
+		oldValue := ""
+		if before != nil {
+			oldValue = indexed_field_value(*before, field)
+		}
+
+		if before != nil && oldValue == newValue { continue }
+
+		if oldValue != "" {
+			key, err := create_composite_key(INDEX_KEY_TYPE, []string{field, oldValue, after.SupplyItemID})
+			if err != nil { return err }
+
+			err = stub.DelState(key)
+			if err != nil { return errors.New("Unable to remove " + field + " index entry for " + after.SupplyItemID) }
+		}
+
+		if newValue != "" {
+			key, err := create_composite_key(INDEX_KEY_TYPE, []string{field, newValue, after.SupplyItemID})
+			if err != nil { return err }
+
+			err = stub.PutState(key, index_value)
+			if err != nil { return errors.New("Unable to store " + field + " index entry for " + after.SupplyItemID) }
+		}
+	}
+
+	err := update_geo_index(stub, before, after)
+	if err != nil { return err }
+
+	err = update_archive_index(stub, before, after)
+	if err != nil { return err }
+
+	return update_holdings(stub, after)
+}
+
+//=================================================================================================================================
+//	 query_supplyItems - Returns one page of the SupplyItems visible to the caller that match every field of the filter.
+//						 The first non-empty filter field, in indexed_fields order, selects the index to walk and the
+//						 others are checked on each SupplyItem found. An empty filter walks every SupplyItem. The page
+//						 has no ApproxTotal.
+//=================================================================================================================================
+func (t *Chaincode) query_supplyItems(stub Stub, caller string, filter SupplyItemFilter, pageSize int, cursor string) ([]byte, error) {
+
+	page := Page{Items: []json.RawMessage{}}
+
+	viewer, err := t.new_viewer(stub, caller)
+	if err != nil { return nil, err }
+
+	add := func(sItem SupplyItem) (bool, error) {
+		if !filter.matches(sItem) { return false, nil }
+
+		temp, err := view_supplyItem(viewer, sItem, "query_supplyItems")
+		if err != nil { return false, nil }
+
+		page.Items = append(page.Items, temp)
+		return true, nil
+	}
+
+	objectType, attributes := SUPPLYITEM_KEY_TYPE, []string{}
+	for _, field := range indexed_fields {
+		if value := filter.value(field); value != "" {
+			objectType, attributes = INDEX_KEY_TYPE, []string{field, value}
+			break
+		}
+	}
+
+	next, err := page_keys(stub, objectType, attributes, cursor, pageSize, func(key string, bytes []byte) (bool, error) {
+
+		if objectType == INDEX_KEY_TYPE {
+			_, parts, err := split_composite_key(key)
+			if err != nil || len(parts) != 3 { return false, ccerror.New(ccerror.CORRUPT_RECORD, "Corrupt index entry " + key) }
+
+			sItem, err := t.retrieve_SupplyItem(stub, parts[2])
+			if err != nil { return false, err }
+
+			return add(sItem)
+		}
+
+		sItem, err := decode_supplyItem_record(bytes)
+		if err != nil { return false, err }
+
+		return add(sItem)
+	})
+
+	if err != nil { return nil, err }
+
+	page.NextCursor = next
+
+	return json.Marshal(page)
+}
+
+//==============================================================================================================================
+//	ReindexResult - The response of reindex_supplyItems.
+//==============================================================================================================================
+type ReindexResult struct {
+	NextCursor string `json:"nextCursor"`
+}
+
+//=================================================================================================================================
+//	 reindex_supplyItems - Writes the index entries and running totals of SupplyItems saved before they existed. Works through one
+//						   page of SupplyItems per call and returns the cursor to pass to the next call, or "" when done.
+//=================================================================================================================================
+func (t *Chaincode) reindex_supplyItems(stub Stub, args []string) ([]byte, error) {
+
+	//Args
+	//		0			1
+	//	pageSize	cursor		(both optional)
+
+	if len(args) > 2 { return nil, ccerror.New(ccerror.INVALID_ARGUMENT, "REINDEX_SUPPLYITEMS: Incorrect number of arguments. Expecting an optional page size and cursor") }
+
+	pageSize, cursor, err := parse_page_args(args)
+	if err != nil { return nil, err }
+
+	next, err := page_keys(stub, SUPPLYITEM_KEY_TYPE, []string{}, cursor, pageSize, func(key string, bytes []byte) (bool, error) {
+
+		sItem, err := decode_supplyItem_record(bytes)
+		if err != nil { return false, err }
+
+		if sItem.SchemaVersion == SCHEMA_BLUECHAIN { return false, nil }		// Indexed when migrate_records upgrades it
+
+		err = t.normalize_supplyItem(stub, &sItem)
+		if err != nil { fmt.Printf("REINDEX_SUPPLYITEMS: Error normalizing supplyitem record: %s", err); return false, err }
+
+		err = update_indexes(stub, nil, sItem)
+		if err != nil { fmt.Printf("REINDEX_SUPPLYITEMS: Error indexing supplyitem record: %s", err); return false, err }
+
+		err = t.backfill_supply_volume(stub, sItem)
+		if err != nil { fmt.Printf("REINDEX_SUPPLYITEMS: Error counting supplyitem record: %s", err); return false, err }
+
+		return true, nil
+	})
+
+	if err != nil { return nil, err }
+
+	return json.Marshal(ReindexResult{NextCursor: next})
+}
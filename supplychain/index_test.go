@@ -0,0 +1,61 @@
+/*
+Copyright IBM Corp 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+
+package supplychain
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestQuerySupplyItems(t *testing.T) {
+
+	tests := []struct {
+		name   string
+		filter string
+		want   string
+		err    string
+	}{
+		{"by owner",              `{"ownerID":"owner2"}`,                         "B1,B2",    ""},
+		{"by owner and material", `{"ownerID":"owner2","materialType":"copper"}`, "B2",       ""},
+		{"by supplier",           `{"supplierID":"` + TEST_SUPPLIER + `"}`,       "A1,B1,B2", ""},
+		{"no match",              `{"materialType":"gold"}`,                      "",         ""},
+		{"not a JSON object",     `["owner2"]`,                                   "",         `"code":"INVALID_ARGUMENT"`},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			l := new_ledger(t)
+			l.register("owner2", ROLE_OWNER)
+			l.create(a_supplyItem("A1"))
+			l.create(a_supplyItem("B1").owner("owner2"))
+			l.create(a_supplyItem("B2").owner("owner2").with("materialType", "copper"))
+
+			bytes, err := l.query(TEST_AUDITOR, "query_supplyItems", tc.filter)
+			check_error(t, err, tc.err)
+			if tc.err != "" { return }
+
+			page, items := decode_page(t, bytes)
+			ids := []string{}
+			for _, sItem := range items {
+				ids = append(ids, sItem.SupplyItemID)
+			}
+			if strings.Join(ids, ",") != tc.want { t.Errorf("got %v, want %s", ids, tc.want) }
+			if page.ApproxTotal != nil || strings.Contains(string(bytes), "approxTotal") { t.Errorf("a filtered page reports approxTotal: %s", bytes) }
+		})
+	}
+}
@@ -0,0 +1,92 @@
+/*
+Copyright IBM Corp 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package supplychain
+
+import (
+	"strings"
+	"unicode/utf8"
+
+	"github.com/vsagineedu/learn-chaincode/ccerror"
+)
+
+//==============================================================================================================================
+//	Composite keys - Records other than the SupplyItems themselves are stored under keys made of an object type followed
+//					 by one or more attributes, each terminated by a null byte. Because validated IDs never contain a
+//					 null byte, a prefix of the attributes selects exactly the records below it with RangeQueryState.
+//==============================================================================================================================
+const COMPOSITE_KEY_NAMESPACE = "\x00"
+const COMPOSITE_KEY_SEPARATOR = "\x00"
+const MAX_UNICODE_RUNE = string(utf8.MaxRune)
+
+//==============================================================================================================================
+//	 create_composite_key - Joins the object type and attributes into a single ledger key.
+//==============================================================================================================================
+func create_composite_key(objectType string, attributes []string) (string, error) {
+
+	if err := validate_composite_key_attribute(objectType); err != nil {
+		return "", err
+	}
+
+	key := COMPOSITE_KEY_NAMESPACE + objectType + COMPOSITE_KEY_SEPARATOR
+
+	for _, attribute := range attributes {
+		if err := validate_composite_key_attribute(attribute); err != nil {
+			return "", err
+		}
+		key += attribute + COMPOSITE_KEY_SEPARATOR
+	}
+
+	return key, nil
+}
+
+//==============================================================================================================================
+//	 split_composite_key - Returns the object type and attributes that make up a key built by create_composite_key.
+//==============================================================================================================================
+func split_composite_key(key string) (string, []string, error) {
+
+	if !strings.HasPrefix(key, COMPOSITE_KEY_NAMESPACE) || !strings.HasSuffix(key, COMPOSITE_KEY_SEPARATOR) {
+		return "", nil, ccerror.New(ccerror.CORRUPT_RECORD, "Not a composite key: " + key)
+	}
+
+	parts := strings.Split(key[len(COMPOSITE_KEY_NAMESPACE):len(key)-len(COMPOSITE_KEY_SEPARATOR)], COMPOSITE_KEY_SEPARATOR)
+
+	return parts[0], parts[1:], nil
+}
+
+func validate_composite_key_attribute(value string) error {
+	if !utf8.ValidString(value) {
+		return ccerror.New(ccerror.INVALID_ARGUMENT, "Key attribute is not valid UTF-8: " + value)
+	}
+	if strings.Contains(value, COMPOSITE_KEY_SEPARATOR) || strings.Contains(value, MAX_UNICODE_RUNE) {
+		return ccerror.New(ccerror.INVALID_ARGUMENT, "Key attribute contains a reserved character: " + value)
+	}
+	return nil
+}
+
+//==============================================================================================================================
+//	 range_query_composite_key - Returns an iterator over every record whose key starts with the given object type and
+//								 leading attributes.
+//==============================================================================================================================
+func range_query_composite_key(stub Stub, objectType string, attributes []string) (StateIterator, error) {
+
+	startKey, err := create_composite_key(objectType, attributes)
+	if err != nil {
+		return nil, err
+	}
+
+	return stub.RangeQueryState(startKey, startKey+MAX_UNICODE_RUNE)
+}
@@ -0,0 +1,328 @@
+/*
+Copyright IBM Corp 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package supplychain
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/vsagineedu/learn-chaincode/ccerror"
+)
+
+//==============================================================================================================================
+//	SplitPart - One child lot requested by split_supplyItem.
+//==============================================================================================================================
+type SplitPart struct {
+	SupplyItemID string   `json:"supplyItemID"`
+	MaterialQty  Quantity `json:"materialQuantity"`
+}
+
+//==============================================================================================================================
+//	LineageNode - A SupplyItem reached while walking lineage, reduced to the fields needed to follow a lot.
+//==============================================================================================================================
+type LineageNode struct {
+	SupplyItemID  string           `json:"supplyItemID"`
+	SupplierID    string           `json:"supplierID"`
+	OwnerID       string           `json:"ownerID"`
+	MaterialType  string           `json:"materialType"`
+	MaterialQty   Quantity         `json:"materialQuantity"`
+	UnitOfMeasure UnitOfMeasure    `json:"unitOfMeasure"`
+	Status        SupplyItemStatus `json:"status"`
+	ParentIDs     []string         `json:"parentIDs"`
+	ChildIDs      []string         `json:"childIDs"`
+	Depth         int              `json:"depth"`
+}
+
+func lineage_node(sItem SupplyItem, depth int) LineageNode {
+	return LineageNode{
+		SupplyItemID:  sItem.SupplyItemID,
+		SupplierID:    sItem.SupplierID,
+		OwnerID:       sItem.OwnerID,
+		MaterialType:  sItem.MaterialType,
+		MaterialQty:   sItem.MaterialQty,
+		UnitOfMeasure: sItem.UnitOfMeasure,
+		Status:        current_status(sItem),
+		ParentIDs:     append([]string{}, sItem.ParentIDs...),
+		ChildIDs:      append([]string{}, sItem.ChildIDs...),
+		Depth:         depth,
+	}
+}
+
+//==============================================================================================================================
+//	 retire_lot - Sets the quantity of a lot that has been split or merged to zero, marks it Consumed and links it to the
+//				  lots that replaced it.
+//==============================================================================================================================
+func (t *Chaincode) retire_lot(stub Stub, sItem SupplyItem, childIDs []string, caller string, action string) error {
+
+	sItem.MaterialQty = 0
+	sItem.Status = STATUS_CONSUMED
+	sItem.ChildIDs = append(sItem.ChildIDs, childIDs...)
+
+	_, err := t.save_changes(stub, sItem, caller, action)
+	return err
+}
+
+//==============================================================================================================================
+//	 create_lot - Validates and saves a new lot produced by split, merge or assembly.
+//==============================================================================================================================
+func (t *Chaincode) create_lot(stub Stub, sItem SupplyItem, caller string, action string) error {
+
+	if _, err := t.check_unique_supplyItem(stub, sItem.SupplyItemID); err != nil {
+		return err
+	}
+
+	if err := validate_supplyItem(action, sItem); err != nil {
+		return err
+	}
+
+	verr := new_validation_error(action)
+	t.check_participants(stub, verr, sItem, []string{"operatorID", "ownerID"})
+	if err := t.resolve_unit(stub, verr, &sItem); err != nil {
+		return err
+	}
+	if err := verr.Result(); err != nil {
+		return err
+	}
+
+	sItem.Revision = 0
+
+	_, err := t.save_changes(stub, sItem, caller, action)
+	return err
+}
+
+//=================================================================================================================================
+//	 split_supplyItem - Divides a lot into child lots whose quantities add up to exactly the parent's. Each child copies
+//						the parent's details and records the parent in ParentIDs. The parent is retired.
+//=================================================================================================================================
+func (t *Chaincode) split_supplyItem(stub Stub, caller string, args []string) ([]byte, error) {
+
+	//Args
+	//		0					1
+	//	supplyItemID	JSON array of {supplyItemID, materialQuantity}
+
+	if len(args) != 2 { return nil, ccerror.New(ccerror.INVALID_ARGUMENT, "SPLIT_SUPPLYITEM: Incorrect number of arguments. Expecting supplyItemID and a JSON array of parts") }
+
+	var parts []SplitPart
+	err := json.Unmarshal([]byte(args[1]), &parts)
+	if err != nil { return nil, ccerror.New(ccerror.INVALID_ARGUMENT, "SPLIT_SUPPLYITEM: Invalid JSON array of parts: " + err.Error()) }
+
+	if len(parts) < 2 { return nil, ccerror.New(ccerror.INVALID_ARGUMENT, "SPLIT_SUPPLYITEM: A split needs at least two parts") }
+
+	parent, err := t.retrieve_SupplyItem(stub, args[0])
+	if err != nil { fmt.Printf("SPLIT_SUPPLYITEM: Error retrieving supplyItem: %s", err); return nil, err }
+
+	if parent.OwnerID != caller { return nil, permission_denied("split_supplyItem") }
+
+	err = check_transition(parent, "split_supplyItem", STATUS_CONSUMED)
+	if err != nil { return nil, err }
+
+	var total Quantity
+	var childIDs []string
+
+	verr := new_validation_error("split_supplyItem")
+
+	for i, part := range parts {
+		field := fmt.Sprintf("parts[%d]", i)
+		validate_id(verr, field+".supplyItemID", part.SupplyItemID, true)
+		if part.MaterialQty <= 0 { verr.Add(field+".materialQuantity", "Must be greater than 0") }
+		if contains_string(childIDs, part.SupplyItemID) { verr.Add(field+".supplyItemID", "Duplicate supplyItemID") }
+
+		total += part.MaterialQty
+		childIDs = append(childIDs, part.SupplyItemID)
+	}
+
+	if total != parent.MaterialQty { verr.Add("parts", "Quantities add up to "+total.String()+" but the parent holds "+parent.MaterialQty.String()) }
+
+	if err := verr.Result(); err != nil { return nil, err }
+
+	for _, part := range parts {
+		child := parent
+		child.SupplyItemID = part.SupplyItemID
+		child.MaterialQty = part.MaterialQty
+		child.ParentIDs = []string{parent.SupplyItemID}
+		child.ChildIDs = nil
+		child.Components = nil
+		child.UsedInIDs = nil
+
+		err = t.create_lot(stub, child, caller, "split_supplyItem")
+		if err != nil { fmt.Printf("SPLIT_SUPPLYITEM: Error creating child lot: %s", err); return nil, err }
+	}
+
+	err = t.retire_lot(stub, parent, childIDs, caller, "split_supplyItem")
+	if err != nil { fmt.Printf("SPLIT_SUPPLYITEM: Error retiring parent lot: %s", err); return nil, err }
+
+	return nil, nil
+}
+
+//=================================================================================================================================
+//	 merge_supplyItems - Combines lots of the same MaterialType and UnitOfMeasure, all owned by the caller, into a new lot
+//						 holding their total quantity. The new lot records every source in ParentIDs and the sources are
+//						 retired. Supplier, operator, location and description are kept only where every source agrees;
+//						 photos and attachments stay with the sources.
+//=================================================================================================================================
+func (t *Chaincode) merge_supplyItems(stub Stub, caller string, args []string) ([]byte, error) {
+
+	//Args
+	//		0					1
+	//	new supplyItemID	JSON array of source supplyItemIDs
+
+	if len(args) != 2 { return nil, ccerror.New(ccerror.INVALID_ARGUMENT, "MERGE_SUPPLYITEMS: Incorrect number of arguments. Expecting new supplyItemID and a JSON array of source supplyItemIDs") }
+
+	var sourceIDs []string
+	err := json.Unmarshal([]byte(args[1]), &sourceIDs)
+	if err != nil { return nil, ccerror.New(ccerror.INVALID_ARGUMENT, "MERGE_SUPPLYITEMS: Invalid JSON array of supplyItemIDs: " + err.Error()) }
+
+	if len(sourceIDs) < 2 { return nil, ccerror.New(ccerror.INVALID_ARGUMENT, "MERGE_SUPPLYITEMS: A merge needs at least two source lots") }
+
+	var sources []SupplyItem
+
+	for _, id := range sourceIDs {
+		for _, seen := range sources {
+			if seen.SupplyItemID == id { return nil, ccerror.New(ccerror.INVALID_ARGUMENT, "MERGE_SUPPLYITEMS: Duplicate source " + id) }
+		}
+
+		sItem, err := t.retrieve_SupplyItem(stub, id)
+		if err != nil { fmt.Printf("MERGE_SUPPLYITEMS: Error retrieving supplyItem: %s", err); return nil, err }
+
+		if sItem.OwnerID != caller { return nil, permission_denied("merge_supplyItems") }
+
+		err = check_transition(sItem, "merge_supplyItems", STATUS_CONSUMED)
+		if err != nil { return nil, err }
+
+		if len(sources) > 0 && (sItem.MaterialType != sources[0].MaterialType || sItem.UnitOfMeasure != sources[0].UnitOfMeasure) {
+			return nil, ccerror.New(ccerror.INVALID_ARGUMENT, "MERGE_SUPPLYITEMS: " + id + " is " + sItem.MaterialType + " in " + string(sItem.UnitOfMeasure) + " but " + sources[0].SupplyItemID + " is " + sources[0].MaterialType + " in " + string(sources[0].UnitOfMeasure))
+		}
+
+		sources = append(sources, sItem)
+	}
+
+	first := sources[0]
+
+	merged := SupplyItem{
+		SupplyItemID:  args[0],
+		SupplierID:    first.SupplierID,
+		OperatorID:    first.OperatorID,
+		OwnerID:       caller,
+		Longitude:     first.Longitude,
+		Latitude:      first.Latitude,
+		Description:   first.Description,
+		MaterialType:  first.MaterialType,
+		UnitOfMeasure: first.UnitOfMeasure,
+		Status:        STATUS_CREATED,
+		ParentIDs:     sourceIDs,
+	}
+
+	for _, sItem := range sources {										// Only fields every source agrees on are copied
+		merged.MaterialQty += sItem.MaterialQty
+
+		if sItem.SupplierID != merged.SupplierID { merged.SupplierID = "" }			// A lot merged from several suppliers has none; get_supplyItem_origins lists them
+		if sItem.OperatorID != merged.OperatorID { merged.OperatorID = "" }
+		if sItem.Description != merged.Description { merged.Description = "" }
+		if sItem.Longitude != merged.Longitude || sItem.Latitude != merged.Latitude {
+			merged.Longitude, merged.Latitude = 0, 0
+		}
+	}
+
+	err = t.create_lot(stub, merged, caller, "merge_supplyItems")
+	if err != nil { fmt.Printf("MERGE_SUPPLYITEMS: Error creating merged lot: %s", err); return nil, err }
+
+	for _, sItem := range sources {
+		err = t.retire_lot(stub, sItem, []string{merged.SupplyItemID}, caller, "merge_supplyItems")
+		if err != nil { fmt.Printf("MERGE_SUPPLYITEMS: Error retiring source lot: %s", err); return nil, err }
+	}
+
+	return nil, nil
+}
+
+//=================================================================================================================================
+//	 walk_lineage - Visits every lot reachable from supplyItemID by following ParentIDs (up) or ChildIDs (down), breadth
+//					first. Each lot is visited once even where lineage rejoins.
+//=================================================================================================================================
+func (t *Chaincode) walk_lineage(stub Stub, start SupplyItem, up bool) ([]LineageNode, error) {
+
+	nodes := []LineageNode{}
+	visited := map[string]bool{start.SupplyItemID: true}
+	queue := []LineageNode{lineage_node(start, 0)}
+
+	for len(queue) > 0 {
+		node := queue[0]
+		queue = queue[1:]
+
+		next := node.ChildIDs
+		if up {
+			next = node.ParentIDs
+		}
+
+		for _, id := range next {
+			if visited[id] { continue }
+			visited[id] = true
+
+			sItem, err := t.retrieve_SupplyItem(stub, id)
+			if err != nil { return nil, err }
+
+			related := lineage_node(sItem, node.Depth+1)
+			nodes = append(nodes, related)
+			queue = append(queue, related)
+		}
+	}
+
+	return nodes, nil
+}
+
+//=================================================================================================================================
+//	 get_supplyItem_origins - Returns the origin lots of a SupplyItem: the lots reached by following ParentIDs that have
+//							  no parents themselves. A lot that was never split or merged is its own origin.
+//=================================================================================================================================
+func (t *Chaincode) get_supplyItem_origins(stub Stub, caller string, supplyItemID string) ([]byte, error) {
+
+	sItem, err := t.retrieve_SupplyItem(stub, supplyItemID)
+	if err != nil { return nil, err }
+
+	if sItem.OwnerID != caller && sItem.OperatorID != caller && !t.reads_all(stub, caller) { return nil, permission_denied("get_supplyItem_origins") }
+
+	ancestors, err := t.walk_lineage(stub, sItem, true)
+	if err != nil { return nil, err }
+
+	origins := []LineageNode{}
+
+	if len(sItem.ParentIDs) == 0 {
+		origins = append(origins, lineage_node(sItem, 0))
+	}
+	for _, node := range ancestors {
+		if len(node.ParentIDs) == 0 {
+			origins = append(origins, node)
+		}
+	}
+
+	return json.Marshal(origins)
+}
+
+//=================================================================================================================================
+//	 get_supplyItem_descendants - Returns every lot produced from a SupplyItem by splitting or merging, nearest first.
+//=================================================================================================================================
+func (t *Chaincode) get_supplyItem_descendants(stub Stub, caller string, supplyItemID string) ([]byte, error) {
+
+	sItem, err := t.retrieve_SupplyItem(stub, supplyItemID)
+	if err != nil { return nil, err }
+
+	if sItem.OwnerID != caller && sItem.OperatorID != caller && !t.reads_all(stub, caller) { return nil, permission_denied("get_supplyItem_descendants") }
+
+	descendants, err := t.walk_lineage(stub, sItem, false)
+	if err != nil { return nil, err }
+
+	return json.Marshal(descendants)
+}
@@ -0,0 +1,127 @@
+/*
+Copyright IBM Corp 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+
+package supplychain
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func get_supplyItem(t *testing.T, l *Ledger, supplyItemID string) SupplyItem {
+	bytes, err := l.query(TEST_AUDITOR, "get_supplyItem", supplyItemID)
+	if err != nil { t.Fatalf("get_supplyItem %s: %s", supplyItemID, err) }
+	return decode_supplyItem(t, bytes)
+}
+
+func TestMergeSupplyItems(t *testing.T) {
+
+	l := new_ledger(t)
+	l.register("supplier2", ROLE_SUPPLIER)
+	l.create(a_supplyItem("A1").quantity(2))
+	if _, err := l.invoke("supplier2", "create_supplyItem", a_supplyItem("A2").with("supplierID", "supplier2").with("longitude", 2.35).quantity(3).json()); err != nil { t.Fatal(err) }
+	l.create(a_supplyItem("C1").quantity(1))
+	if _, err := l.invoke(TEST_OWNER, "assemble_supplyItem", a_supplyItem("ASM").owner(TEST_OWNER).json(), `[{"supplyItemID":"A1","materialQuantity":1}]`); err != nil { t.Fatal(err) }
+
+	_, err := l.invoke(TEST_OWNER, "merge_supplyItems", "M1", `["A1","A2"]`)
+	check_error(t, err, "")
+
+	merged := get_supplyItem(t, l, "M1")
+	if merged.MaterialQty.String() != "4" || merged.OwnerID != TEST_OWNER || merged.OperatorID != TEST_OPERATOR || merged.MaterialType != "steel" {
+		t.Errorf("merged %+v", merged)
+	}
+	if merged.SupplierID != "" { t.Errorf("a lot merged from two suppliers has supplierID %q", merged.SupplierID) }
+	if merged.Description != "" || merged.Longitude != 0 || merged.Latitude != 0 { t.Errorf("merged lot kept unshared fields %+v", merged) }
+	if len(merged.UsedInIDs) != 0 || len(merged.Components) != 0 || len(merged.ChildIDs) != 0 { t.Errorf("merged lot inherited links %+v", merged) }
+	if len(merged.ParentIDs) != 2 { t.Errorf("parentIDs %v", merged.ParentIDs) }
+
+	_, err = l.invoke(TEST_OWNER, "merge_supplyItems", "M2", `["C1","A1"]`)
+	check_error(t, err, `"code":"CONFLICT"`)
+}
+
+func TestSplitSupplyItem(t *testing.T) {
+
+	l := new_ledger(t)
+	l.create(a_supplyItem("A1").quantity(4))
+	if _, err := l.invoke(TEST_OWNER, "assemble_supplyItem", a_supplyItem("ASM").owner(TEST_OWNER).json(), `[{"supplyItemID":"A1","materialQuantity":1}]`); err != nil { t.Fatal(err) }
+
+	_, err := l.invoke(TEST_OWNER, "split_supplyItem", "A1", `[{"supplyItemID":"S1","materialQuantity":1},{"supplyItemID":"S2","materialQuantity":1}]`)
+	check_error(t, err, "Quantities add up to 2")
+
+	_, err = l.invoke(TEST_OWNER, "split_supplyItem", "A1", `[{"supplyItemID":"S1","materialQuantity":1},{"supplyItemID":"S2","materialQuantity":2}]`)
+	check_error(t, err, "")
+
+	_, err = l.invoke(TEST_OWNER, "split_supplyItem", "ASM", `[{"supplyItemID":"S3","materialQuantity":5},{"supplyItemID":"S4","materialQuantity":7.5}]`)
+	check_error(t, err, "")
+
+	for _, id := range []string{"S1", "S2", "S3", "S4"} {
+		child := get_supplyItem(t, l, id)
+		if len(child.UsedInIDs) != 0 || len(child.Components) != 0 || len(child.ChildIDs) != 0 || len(child.ParentIDs) != 1 {
+			t.Errorf("child %s inherited links %+v", id, child)
+		}
+	}
+	if parent := get_supplyItem(t, l, "A1"); parent.Status != STATUS_CONSUMED || len(parent.ChildIDs) != 2 { t.Errorf("parent %+v", parent) }
+}
+
+func TestLineageWalks(t *testing.T) {
+
+	l := new_ledger(t)
+	l.register("owner2", ROLE_OWNER)
+	l.create(a_supplyItem("A1").quantity(3))
+	l.create(a_supplyItem("B1").quantity(2))
+
+	if _, err := l.invoke(TEST_OWNER, "split_supplyItem", "A1", `[{"supplyItemID":"S1","materialQuantity":1},{"supplyItemID":"S2","materialQuantity":2}]`); err != nil { t.Fatal(err) }
+	if _, err := l.invoke(TEST_OWNER, "merge_supplyItems", "M1", `["S2","B1"]`); err != nil { t.Fatal(err) }
+
+	walk := func(function string, id string) string {
+		bytes, err := l.query(TEST_AUDITOR, function, id)
+		if err != nil { t.Fatalf("%s %s: %s", function, id, err) }
+
+		var nodes []LineageNode
+		if err := json.Unmarshal(bytes, &nodes); err != nil { t.Fatal(err) }
+
+		got := []string{}
+		for _, node := range nodes {
+			got = append(got, fmt.Sprintf("%s:%d", node.SupplyItemID, node.Depth))
+		}
+		return strings.Join(got, ",")
+	}
+
+	tests := []struct {
+		function string
+		id       string
+		want     string
+	}{
+		{"get_supplyItem_descendants", "A1", "S1:1,S2:1,M1:2"},
+		{"get_supplyItem_descendants", "M1", ""},
+		{"get_supplyItem_origins",     "M1", "B1:1,A1:2"},
+		{"get_supplyItem_origins",     "S1", "A1:1"},
+		{"get_supplyItem_origins",     "A1", "A1:0"},
+	}
+
+	for _, tc := range tests {
+		if got := walk(tc.function, tc.id); got != tc.want { t.Errorf("%s %s = %s, want %s", tc.function, tc.id, got, tc.want) }
+	}
+
+	_, err := l.query("owner2", "get_supplyItem_descendants", "A1")
+	check_error(t, err, `"code":"PERMISSION_DENIED"`)
+
+	_, err = l.query(TEST_AUDITOR, "get_supplyItem_origins", "NOPE")
+	check_error(t, err, `"code":"NOT_FOUND"`)
+}
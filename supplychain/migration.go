@@ -0,0 +1,112 @@
+/*
+Copyright IBM Corp 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package supplychain
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/vsagineedu/learn-chaincode/ccerror"
+)
+
+const LEGACY_HOLDER_KEY = "supplyItemIDs"
+const DEFAULT_MIGRATION_BATCH = 500
+
+//==============================================================================================================================
+//	IndexMigrationResult - The response of migrate_supplyItem_index.
+//==============================================================================================================================
+type IndexMigrationResult struct {
+	Migrated  int `json:"migrated"`
+	Remaining int `json:"remaining"`
+}
+
+//=================================================================================================================================
+//	 migrate_supplyItem_index - Converts a ledger that still lists its SupplyItems in a SupplyItemIDs_Holder. Each record
+//								is moved byte for byte from its bare SupplyItemID key to its SUPPLYITEM_KEY_TYPE key and
+//								removed from the holder. At most batchSize records are moved per call so large ledgers
+//								can be converted over several transactions; the holder is deleted once it is empty.
+//=================================================================================================================================
+func (t *Chaincode) migrate_supplyItem_index(stub Stub, args []string) ([]byte, error) {
+
+	//Args
+	//		0
+	//	batchSize (optional)
+
+	if len(args) > 1 { return nil, ccerror.New(ccerror.INVALID_ARGUMENT, "MIGRATE_SUPPLYITEM_INDEX: Incorrect number of arguments. Expecting an optional batch size") }
+
+	batchSize := DEFAULT_MIGRATION_BATCH
+	if len(args) == 1 {
+		size, err := strconv.Atoi(args[0])
+		if err != nil || size <= 0 { return nil, ccerror.New(ccerror.INVALID_ARGUMENT, "MIGRATE_SUPPLYITEM_INDEX: Batch size must be a positive integer") }
+		batchSize = size
+	}
+
+	result := IndexMigrationResult{}
+
+	bytes, err := stub.GetState(LEGACY_HOLDER_KEY)
+	if err != nil { return nil, errors.New("Unable to get supplyItemIDs") }
+
+	if bytes == nil { return json.Marshal(result) }				// Nothing left to migrate
+
+	var holder SupplyItemIDs_Holder
+	err = json.Unmarshal(bytes, &holder)
+	if err != nil { return nil, ccerror.New(ccerror.CORRUPT_RECORD, "Corrupt SupplyItemIDs_Holder record") }
+
+	for len(holder.SupplyItemIDs) > 0 && result.Migrated < batchSize {
+
+		supplyItemID := holder.SupplyItemIDs[0]
+
+		key, err := supplyItem_key(supplyItemID)
+		if err != nil { return nil, err }
+
+		record, err := stub.GetState(supplyItemID)
+		if err != nil { return nil, errors.New("Unable to get supplyItem " + supplyItemID) }
+
+		existing, err := stub.GetState(key)
+		if err != nil { return nil, errors.New("Unable to get supplyItem " + supplyItemID) }
+
+		if record != nil && existing == nil {
+			err = stub.PutState(key, record)
+			if err != nil { fmt.Printf("MIGRATE_SUPPLYITEM_INDEX: Error storing supplyitem record: %s", err); return nil, errors.New("Error storing supplyitem record") }
+		}
+
+		if record != nil {
+			err = stub.DelState(supplyItemID)
+			if err != nil { return nil, errors.New("Unable to delete legacy record " + supplyItemID) }
+		}
+
+		holder.SupplyItemIDs = holder.SupplyItemIDs[1:]
+		result.Migrated++
+	}
+
+	result.Remaining = len(holder.SupplyItemIDs)
+
+	if result.Remaining == 0 {
+		err = stub.DelState(LEGACY_HOLDER_KEY)
+		if err != nil { return nil, errors.New("Unable to delete SupplyItemIDs_Holder record") }
+	} else {
+		bytes, err = json.Marshal(holder)
+		if err != nil { return nil, errors.New("Error creating SupplyItemIDs_Holder record") }
+
+		err = stub.PutState(LEGACY_HOLDER_KEY, bytes)
+		if err != nil { return nil, errors.New("Unable to put the state") }
+	}
+
+	return json.Marshal(result)
+}
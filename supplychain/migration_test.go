@@ -0,0 +1,57 @@
+/*
+Copyright IBM Corp 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+
+package supplychain
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestMigrateSupplyItemIndex(t *testing.T) {
+
+	l := new_ledger(t)
+	for _, id := range []string{"A1", "A2", "A3"} {
+		l.state[id] = bluechain_record(id, "", "-0.1276")
+	}
+	l.state[LEGACY_HOLDER_KEY] = []byte(`{"supplyItemIDs":["A1","A2","A3","MISSING"]}`)
+
+	_, err := l.invoke(TEST_OWNER, "migrate_supplyItem_index")
+	check_error(t, err, `"code":"PERMISSION_DENIED"`)
+
+	_, err = l.invoke(TEST_ADMIN, "migrate_supplyItem_index", "0")
+	check_error(t, err, `"code":"INVALID_ARGUMENT"`)
+
+	for _, want := range []IndexMigrationResult{{Migrated: 2, Remaining: 2}, {Migrated: 2, Remaining: 0}, {Migrated: 0, Remaining: 0}} {
+		bytes, err := l.invoke(TEST_ADMIN, "migrate_supplyItem_index", "2")
+		if err != nil { t.Fatalf("migrate_supplyItem_index: %s", err) }
+
+		var result IndexMigrationResult
+		if err := json.Unmarshal(bytes, &result); err != nil { t.Fatalf("not an IndexMigrationResult: %s: %s", err, bytes) }
+		if result != want { t.Fatalf("result = %+v, want %+v", result, want) }
+	}
+
+	if _, ok := l.state[LEGACY_HOLDER_KEY]; ok { t.Errorf("SupplyItemIDs_Holder kept") }
+
+	for _, id := range []string{"A1", "A2", "A3"} {
+		key, _ := supplyItem_key(id)
+		if _, ok := l.state[id]; ok { t.Errorf("%s kept under its bare key", id) }
+		if string(l.state[key]) != string(bluechain_record(id, "", "-0.1276")) { t.Errorf("%s moved as %s", id, l.state[key]) }
+	}
+
+	if counts := schema_versions(t, l); counts.Versions["1"] != 3 || counts.Unindexed != 0 { t.Errorf("versions = %+v", counts) }
+}
@@ -0,0 +1,187 @@
+/*
+Copyright IBM Corp 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package supplychain
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"strconv"
+	"strings"
+
+	"github.com/vsagineedu/learn-chaincode/ccerror"
+)
+
+const DEFAULT_PAGE_SIZE = 100
+const MAX_PAGE_SIZE = 1000
+const MAX_SCAN_FACTOR = 10			// A page stops after scanning this many records per requested item, even if short
+
+const COUNTER_KEY_TYPE = "supplyItemCount"
+const COUNTER_SHARDS = 16
+
+//==============================================================================================================================
+//	Page - The response envelope of paged queries. NextCursor is empty on the last page. ApproxTotal is only set by
+//		   get_supplyItems and counts every SupplyItem on the ledger, not only those visible to the caller, and
+//		   excludes records moved by migrate_supplyItem_index. Filtered queries leave it out, since counting their
+//		   matches would mean reading every page.
+//==============================================================================================================================
+type Page struct {
+	Items       []json.RawMessage `json:"items"`
+	NextCursor  string            `json:"nextCursor"`
+	ApproxTotal *int              `json:"approxTotal,omitempty"`
+}
+
+//==============================================================================================================================
+//	 parse_page_args - Reads the optional page size and cursor arguments.
+//==============================================================================================================================
+func parse_page_args(args []string) (int, string, error) {
+
+	pageSize := DEFAULT_PAGE_SIZE
+	cursor := ""
+
+	if len(args) > 0 && args[0] != "" {
+		size, err := strconv.Atoi(args[0])
+		if err != nil || size <= 0 || size > MAX_PAGE_SIZE { return 0, "", ccerror.New(ccerror.INVALID_ARGUMENT, "Page size must be between 1 and " + strconv.Itoa(MAX_PAGE_SIZE)) }
+		pageSize = size
+	}
+	if len(args) > 1 {
+		cursor = args[1]
+	}
+
+	return pageSize, cursor, nil
+}
+
+//==============================================================================================================================
+//	 parse_flag - Reads the optional boolean argument at index i. Left out or "" is false; the registry has checked the
+//				  rest parse.
+//==============================================================================================================================
+func parse_flag(args []string, i int) bool {
+	if len(args) <= i || args[i] == "" { return false }
+	flag, _ := strconv.ParseBool(args[i])
+	return flag
+}
+
+func cursor_arg(args []string, i int) string {
+	if len(args) <= i { return "" }
+	return args[i]
+}
+
+func encode_cursor(key string) string {
+	return base64.URLEncoding.EncodeToString([]byte(key))
+}
+
+func decode_cursor(cursor string, prefix string) (string, error) {
+	key, err := base64.URLEncoding.DecodeString(cursor)
+	if err != nil || !strings.HasPrefix(string(key), prefix) {
+		return "", ccerror.New(ccerror.INVALID_ARGUMENT, "Invalid cursor")
+	}
+	return string(key), nil
+}
+
+//==============================================================================================================================
+//	 page_keys - Walks the records below a composite key prefix in key order, starting after the cursor, and passes each
+//				 to visit. visit reports whether the record was added to the page. Returns the cursor of the next page,
+//				 or "" if the range is exhausted.
+//==============================================================================================================================
+func page_keys(stub Stub, objectType string, attributes []string, cursor string, pageSize int, visit func(key string, value []byte) (bool, error)) (string, error) {
+
+	prefix, err := create_composite_key(objectType, attributes)
+	if err != nil { return "", err }
+
+	startKey := prefix
+	if cursor != "" {
+		startKey, err = decode_cursor(cursor, prefix)
+		if err != nil { return "", err }
+	}
+
+	iter, err := stub.RangeQueryState(startKey, prefix+MAX_UNICODE_RUNE)
+	if err != nil { return "", errors.New("Unable to query the ledger") }
+	defer iter.Close()
+
+	added, scanned := 0, 0
+	lastKey := ""
+
+	for iter.HasNext() {
+		if added >= pageSize || scanned >= pageSize*MAX_SCAN_FACTOR {
+			return encode_cursor(lastKey), nil
+		}
+
+		key, value, err := iter.Next()
+		if err != nil { return "", errors.New("Unable to query the ledger") }
+
+		if cursor != "" && key == startKey { continue }			// The cursor names the last record of the previous page
+
+		included, err := visit(key, value)
+		if err != nil { return "", err }
+
+		if included { added++ }
+		scanned++
+		lastKey = key
+	}
+
+	return "", nil
+}
+
+//==============================================================================================================================
+//	 SupplyItem counter - The number of SupplyItems is kept in COUNTER_SHARDS separate keys, chosen by a hash of the
+//						  SupplyItemID, so that concurrent creates rarely write the same key.
+//==============================================================================================================================
+func counter_key(supplyItemID string) (string, error) {
+	h := fnv.New32a()
+	h.Write([]byte(supplyItemID))
+	return create_composite_key(COUNTER_KEY_TYPE, []string{fmt.Sprintf("%02d", h.Sum32()%COUNTER_SHARDS)})
+}
+
+func add_supplyItem_count(stub Stub, supplyItemID string, delta int) error {
+
+	key, err := counter_key(supplyItemID)
+	if err != nil { return err }
+
+	bytes, err := stub.GetState(key)
+	if err != nil { return errors.New("Unable to get supplyItem count") }
+
+	count := 0
+	if bytes != nil {
+		count, err = strconv.Atoi(string(bytes))
+		if err != nil { return ccerror.New(ccerror.CORRUPT_RECORD, "Corrupt supplyItem count " + string(bytes)) }
+	}
+
+	return stub.PutState(key, []byte(strconv.Itoa(count+delta)))
+}
+
+func get_supplyItem_count(stub Stub) (int, error) {
+
+	iter, err := range_query_composite_key(stub, COUNTER_KEY_TYPE, []string{})
+	if err != nil { return 0, errors.New("Unable to get supplyItem count") }
+	defer iter.Close()
+
+	total := 0
+
+	for iter.HasNext() {
+		_, bytes, err := iter.Next()
+		if err != nil { return 0, errors.New("Unable to get supplyItem count") }
+
+		count, err := strconv.Atoi(string(bytes))
+		if err != nil { return 0, ccerror.New(ccerror.CORRUPT_RECORD, "Corrupt supplyItem count " + string(bytes)) }
+
+		total += count
+	}
+
+	return total, nil
+}
@@ -0,0 +1,302 @@
+/*
+Copyright IBM Corp 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package supplychain
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/vsagineedu/learn-chaincode/ccerror"
+)
+
+//==============================================================================================================================
+//	Participant roles and statuses
+//==============================================================================================================================
+const ROLE_SUPPLIER = "supplier"
+const ROLE_OPERATOR = "operator"
+const ROLE_OWNER = "owner"
+const ROLE_AUDITOR = "auditor"
+const ROLE_REGULATOR = "regulator"
+const ROLE_ADMIN = "admin"
+
+var participant_roles = []string{ROLE_SUPPLIER, ROLE_OPERATOR, ROLE_OWNER, ROLE_AUDITOR, ROLE_REGULATOR, ROLE_ADMIN}
+
+const PARTICIPANT_ACTIVE = "Active"
+const PARTICIPANT_SUSPENDED = "Suspended"
+
+const PARTICIPANT_KEY_TYPE = "participant"
+
+//==============================================================================================================================
+//	Participant - A known party. The ParticipantID is the username in the party's transaction certificate and is the
+//				  value used in the SupplierID, OperatorID and OwnerID of a SupplyItem.
+//==============================================================================================================================
+type Participant struct {
+	ParticipantID string      `json:"participantID"`
+	Organization  string      `json:"organization"`
+	Roles         []string    `json:"roles"`
+	Contact       ContactInfo `json:"contact"`
+	Status        string      `json:"status"`
+}
+
+type ContactInfo struct {
+	Name    string `json:"name"`
+	Email   string `json:"email"`
+	Phone   string `json:"phone"`
+	Address string `json:"address"`
+}
+
+func (p Participant) has_role(role string) bool {
+	return contains_string(p.Roles, role)
+}
+
+func participant_key(participantID string) (string, error) {
+	return create_composite_key(PARTICIPANT_KEY_TYPE, []string{participantID})
+}
+
+//==============================================================================================================================
+//	 retrieve_participant - Gets the Participant with the given ID. Returns an error if there is none.
+//==============================================================================================================================
+func (t *Chaincode) retrieve_participant(stub Stub, participantID string) (Participant, error) {
+
+	var p Participant
+
+	key, err := participant_key(participantID)
+	if err != nil { return p, err }
+
+	bytes, err := stub.GetState(key)
+	if err != nil { fmt.Printf("RETRIEVE_PARTICIPANT: Failed to get participant: %s", err); return p, errors.New("RETRIEVE_PARTICIPANT: Error retrieving participant " + participantID) }
+
+	if bytes == nil { return p, ccerror.New(ccerror.NOT_FOUND, "No participant " + participantID) }
+
+	err = json.Unmarshal(bytes, &p)
+	if err != nil { fmt.Printf("RETRIEVE_PARTICIPANT: Corrupt participant record "+string(bytes)+": %s", err); return p, ccerror.New(ccerror.CORRUPT_RECORD, "RETRIEVE_PARTICIPANT: Corrupt participant record" + string(bytes)) }
+
+	return p, nil
+}
+
+func (t *Chaincode) save_participant(stub Stub, p Participant) error {
+
+	key, err := participant_key(p.ParticipantID)
+	if err != nil { return err }
+
+	bytes, err := json.Marshal(p)
+	if err != nil { fmt.Printf("SAVE_PARTICIPANT: Error converting participant record: %s", err); return errors.New("Error converting participant record") }
+
+	err = stub.PutState(key, bytes)
+	if err != nil { fmt.Printf("SAVE_PARTICIPANT: Error storing participant record: %s", err); return errors.New("Error storing participant record") }
+
+	return nil
+}
+
+//==============================================================================================================================
+//	 validate_participant - Adds a field error to verr for each invalid field of p.
+//==============================================================================================================================
+func validate_participant(verr *ccerror.Error, p Participant) {
+
+	validate_id(verr, "participantID", p.ParticipantID, true)
+
+	if strings.TrimSpace(p.Organization) == "" {
+		verr.Add("organization", "Required")
+	} else if len(p.Organization) > MAX_TEXT_LENGTH {
+		verr.Add("organization", "Must be at most "+fmt.Sprint(MAX_TEXT_LENGTH)+" characters")
+	}
+
+	if len(p.Roles) == 0 { verr.Add("roles", "At least one role is required") }
+
+	for i, role := range p.Roles {
+		if !contains_string(participant_roles, role) {
+			verr.Add(fmt.Sprintf("roles[%d]", i), "Unknown role "+role+". Expecting one of "+strings.Join(participant_roles, ", "))
+		} else if contains_string(p.Roles[:i], role) {
+			verr.Add(fmt.Sprintf("roles[%d]", i), "Duplicate role "+role)
+		}
+	}
+
+	contact := []string{p.Contact.Name, p.Contact.Email, p.Contact.Phone, p.Contact.Address}
+	for i, field := range []string{"contact.name", "contact.email", "contact.phone", "contact.address"} {
+		if len(contact[i]) > MAX_TEXT_LENGTH { verr.Add(field, "Must be at most "+fmt.Sprint(MAX_TEXT_LENGTH)+" characters") }
+	}
+
+	if p.Status != PARTICIPANT_ACTIVE && p.Status != PARTICIPANT_SUSPENDED {
+		verr.Add("status", "Expecting "+PARTICIPANT_ACTIVE+" or "+PARTICIPANT_SUSPENDED)
+	}
+}
+
+//==============================================================================================================================
+//	 check_active_participant - Returns an error unless participantID names a registered, active Participant.
+//==============================================================================================================================
+func (t *Chaincode) check_active_participant(stub Stub, participantID string) (Participant, error) {
+
+	p, err := t.retrieve_participant(stub, participantID)
+	if err != nil { return p, ccerror.New(ccerror.NOT_FOUND, "Unknown participant " + participantID) }
+
+	if p.Status != PARTICIPANT_ACTIVE { return p, ccerror.New(ccerror.CONFLICT, "Participant " + participantID + " is suspended") }
+
+	return p, nil
+}
+
+//==============================================================================================================================
+//	 check_participants - Adds a field error to verr for each of the given SupplyItem fields that is set but does not
+//						  name an active Participant.
+//==============================================================================================================================
+func (t *Chaincode) check_participants(stub Stub, verr *ccerror.Error, sItem SupplyItem, fields []string) {
+
+	for _, field := range fields {
+		if id := indexed_field_value(sItem, field); id != "" && !verr.Has(field) {
+			if _, err := t.check_active_participant(stub, id); err != nil { verr.Add(field, ccerror.MessageOf(err)) }
+		}
+	}
+}
+
+//==============================================================================================================================
+//	 check_admin - Returns an error unless the caller is an active Participant with the admin role.
+//==============================================================================================================================
+func (t *Chaincode) check_admin(stub Stub, caller string, function string) error {
+
+	p, err := t.check_active_participant(stub, caller)
+	if err != nil || !p.has_role(ROLE_ADMIN) { return permission_denied(function) }
+
+	return nil
+}
+
+//==============================================================================================================================
+//	 seed_admin - Registers the first admin from the Init argument. The admin role is added if it is missing. A participant
+//				  already registered under the same ID is left as it is.
+//==============================================================================================================================
+func (t *Chaincode) seed_admin(stub Stub, input string) error {
+
+	var p Participant
+	err := json.Unmarshal([]byte(input), &p)
+	if err != nil { return ccerror.New(ccerror.INVALID_ARGUMENT, "INIT: Invalid participant object: " + err.Error()) }
+
+	if !p.has_role(ROLE_ADMIN) {
+		p.Roles = append(p.Roles, ROLE_ADMIN)
+	}
+	p.Status = PARTICIPANT_ACTIVE
+
+	verr := new_validation_error("init")
+	validate_participant(verr, p)
+	if err := verr.Result(); err != nil { return err }
+
+	_, err = t.retrieve_participant(stub, p.ParticipantID)
+	if err == nil { return nil }													// Already registered, e.g. when Init runs again on upgrade
+	if ccerror.CodeOf(err) != ccerror.NOT_FOUND { return err }
+
+	return t.save_participant(stub, p)
+}
+
+//=================================================================================================================================
+//	 register_participant - Adds a new Participant. New participants are always Active.
+//=================================================================================================================================
+func (t *Chaincode) register_participant(stub Stub, caller string, args []string) ([]byte, error) {
+
+	//Args
+	//		0
+	//	participant JSON object
+
+	if len(args) != 1 { return nil, ccerror.New(ccerror.INVALID_ARGUMENT, "REGISTER_PARTICIPANT: Incorrect number of arguments. Expecting a participant JSON object") }
+
+	var p Participant
+	err := json.Unmarshal([]byte(args[0]), &p)
+	if err != nil { return nil, ccerror.New(ccerror.INVALID_ARGUMENT, "REGISTER_PARTICIPANT: Invalid participant object: " + err.Error()) }
+
+	p.Status = PARTICIPANT_ACTIVE
+
+	verr := new_validation_error("register_participant")
+	validate_participant(verr, p)
+	if err := verr.Result(); err != nil { return nil, err }
+
+	if _, err := t.retrieve_participant(stub, p.ParticipantID); err == nil { return nil, ccerror.New(ccerror.ALREADY_EXISTS, "Participant " + p.ParticipantID + " already exists") }
+
+	err = t.save_participant(stub, p)
+	if err != nil { fmt.Printf("REGISTER_PARTICIPANT: Error saving changes: %s", err); return nil, err }
+
+	return nil, nil
+}
+
+//=================================================================================================================================
+//	 update_participant - Changes the organization, roles, contact details or status of a Participant.
+//						  Fields missing from the JSON object are left unchanged. Admins cannot remove their own admin
+//						  role or suspend themselves, so the registry always keeps an admin.
+//=================================================================================================================================
+func (t *Chaincode) update_participant(stub Stub, caller string, args []string) ([]byte, error) {
+
+	//Args
+	//		0					1
+	//	participantID	JSON object of {organization, roles, contact, status}
+
+	if len(args) != 2 { return nil, ccerror.New(ccerror.INVALID_ARGUMENT, "UPDATE_PARTICIPANT: Incorrect number of arguments. Expecting participantID and a JSON object") }
+
+	p, err := t.retrieve_participant(stub, args[0])
+	if err != nil { return nil, err }
+
+	err = json.Unmarshal([]byte(args[1]), &p)
+	if err != nil { return nil, ccerror.New(ccerror.INVALID_ARGUMENT, "UPDATE_PARTICIPANT: Invalid participant object: " + err.Error()) }
+
+	verr := new_validation_error("update_participant")
+	if p.ParticipantID != args[0] { verr.Add("participantID", "Cannot be changed") }
+	validate_participant(verr, p)
+	if err := verr.Result(); err != nil { return nil, err }
+
+	if p.ParticipantID == caller && (!p.has_role(ROLE_ADMIN) || p.Status != PARTICIPANT_ACTIVE) { return nil, ccerror.New(ccerror.CONFLICT, "UPDATE_PARTICIPANT: An admin cannot remove their own admin role or suspend themselves") }
+
+	err = t.save_participant(stub, p)
+	if err != nil { fmt.Printf("UPDATE_PARTICIPANT: Error saving changes: %s", err); return nil, err }
+
+	return nil, nil
+}
+
+//=================================================================================================================================
+//	 suspend_participant - Marks a Participant Suspended. A suspended participant cannot be named on new
+//						   SupplyItems or receive transfers until an admin sets its status back to Active.
+//=================================================================================================================================
+func (t *Chaincode) suspend_participant(stub Stub, caller string, args []string) ([]byte, error) {
+
+	//Args
+	//		0
+	//	participantID
+
+	if len(args) != 1 { return nil, ccerror.New(ccerror.INVALID_ARGUMENT, "SUSPEND_PARTICIPANT: Incorrect number of arguments. Expecting participantID") }
+
+	if args[0] == caller { return nil, ccerror.New(ccerror.CONFLICT, "SUSPEND_PARTICIPANT: An admin cannot suspend themselves") }
+
+	p, err := t.retrieve_participant(stub, args[0])
+	if err != nil { return nil, err }
+
+	p.Status = PARTICIPANT_SUSPENDED
+
+	err = t.save_participant(stub, p)
+	if err != nil { fmt.Printf("SUSPEND_PARTICIPANT: Error saving changes: %s", err); return nil, err }
+
+	return nil, nil
+}
+
+//=================================================================================================================================
+//	 get_participant - Returns a Participant. Visible to the participant itself, to admins and to the ReadAll roles.
+//=================================================================================================================================
+func (t *Chaincode) get_participant(stub Stub, caller string, participantID string) ([]byte, error) {
+
+	if participantID != caller && !t.reads_all(stub, caller) {
+		if err := t.check_admin(stub, caller, "get_participant"); err != nil { return nil, err }
+	}
+
+	p, err := t.retrieve_participant(stub, participantID)
+	if err != nil { return nil, err }
+
+	return json.Marshal(p)
+}
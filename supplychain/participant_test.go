@@ -0,0 +1,135 @@
+/*
+Copyright IBM Corp 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+
+package supplychain
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func get_participant(t *testing.T, l *Ledger, participantID string) Participant {
+	bytes, err := l.query(TEST_ADMIN, "get_participant", participantID)
+	if err != nil { t.Fatalf("get_participant: %s", err) }
+
+	var p Participant
+	if err := json.Unmarshal(bytes, &p); err != nil { t.Fatalf("not a Participant: %s: %s", err, bytes) }
+	return p
+}
+
+func TestRegisterParticipant(t *testing.T) {
+
+	tests := []struct {
+		name   string
+		caller string
+		input  string
+		want   string
+	}{
+		{"supplier",           TEST_ADMIN, `{"participantID":"s2","organization":"org2","roles":["supplier"],"contact":{"email":"s2@org2"}}`, ""},
+		{"status ignored",     TEST_ADMIN, `{"participantID":"s2","organization":"org2","roles":["supplier"],"status":"Suspended"}`,        ""},
+		{"already registered", TEST_ADMIN, participant_json(TEST_OWNER, ROLE_OWNER),                                                     `"code":"ALREADY_EXISTS"`},
+		{"unknown role",       TEST_ADMIN, participant_json("s2", ROLE_SUPPLIER, "buyer"),                                               `"field":"roles[1]"`},
+		{"duplicate role",     TEST_ADMIN, participant_json("s2", ROLE_SUPPLIER, ROLE_SUPPLIER),                                         `"field":"roles[1]"`},
+		{"no roles",           TEST_ADMIN, participant_json("s2"),                                                                       `"field":"roles"`},
+		{"no organization",    TEST_ADMIN, `{"participantID":"s2","roles":["supplier"]}`,                                                `"field":"organization"`},
+		{"not an admin",       TEST_OWNER, participant_json("s2", ROLE_SUPPLIER),                                                        `"code":"PERMISSION_DENIED"`},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			l := new_ledger(t)
+
+			_, err := l.invoke(tc.caller, "register_participant", tc.input)
+			check_error(t, err, tc.want)
+			if tc.want != "" { return }
+
+			if p := get_participant(t, l, "s2"); p.Organization != "org2" || p.Status != PARTICIPANT_ACTIVE || !p.has_role(ROLE_SUPPLIER) { t.Errorf("participant = %+v", p) }
+		})
+	}
+}
+
+func TestUpdateParticipant(t *testing.T) {
+
+	tests := []struct {
+		name   string
+		id     string
+		fields string
+		want   string
+	}{
+		{"roles",          TEST_OWNER, `{"roles":["owner","operator"]}`, ""},
+		{"contact",        TEST_OWNER, `{"contact":{"phone":"555"}}`,    ""},
+		{"unknown status", TEST_OWNER, `{"status":"Retired"}`,           `"field":"status"`},
+		{"new ID",         TEST_OWNER, `{"participantID":"owner9"}`,     `"field":"participantID"`},
+		{"own admin role", TEST_ADMIN, `{"roles":["auditor"]}`,          `"code":"CONFLICT"`},
+		{"unknown",        "owner9",   `{"roles":["owner"]}`,            `"code":"NOT_FOUND"`},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			l := new_ledger(t)
+
+			_, err := l.invoke(TEST_ADMIN, "update_participant", tc.id, tc.fields)
+			check_error(t, err, tc.want)
+			if tc.want != "" { return }
+
+			if p := get_participant(t, l, tc.id); p.Organization != "org1" || !p.has_role(ROLE_OWNER) { t.Errorf("participant = %+v, want unchanged fields kept", p) }
+		})
+	}
+}
+
+func TestSuspendParticipant(t *testing.T) {
+
+	l := new_ledger(t)
+	l.create(a_supplyItem("A1"))
+
+	_, err := l.invoke(TEST_OWNER, "suspend_participant", TEST_OPERATOR)
+	check_error(t, err, `"code":"PERMISSION_DENIED"`)
+
+	_, err = l.invoke(TEST_ADMIN, "suspend_participant", TEST_ADMIN)
+	check_error(t, err, `"code":"CONFLICT"`)
+
+	_, err = l.invoke(TEST_ADMIN, "suspend_participant", TEST_OPERATOR)
+	check_error(t, err, "")
+
+	_, err = l.invoke(TEST_SUPPLIER, "create_supplyItem", a_supplyItem("A2").json())
+	check_error(t, err, `"field":"operatorID"`)
+
+	_, err = l.invoke(TEST_OWNER, "propose_transfer", "A1", TRANSFER_OWNERSHIP, TEST_OPERATOR)
+	check_error(t, err, `"field":"recipient"`)
+
+	_, err = l.invoke(TEST_ADMIN, "update_participant", TEST_OPERATOR, `{"status":"Active"}`)
+	check_error(t, err, "")
+
+	_, err = l.invoke(TEST_SUPPLIER, "create_supplyItem", a_supplyItem("A2").json())
+	check_error(t, err, "")
+}
+
+func TestGetParticipant(t *testing.T) {
+
+	l := new_ledger(t)
+
+	for _, caller := range []string{TEST_OWNER, TEST_ADMIN, TEST_AUDITOR} {
+		bytes, err := l.query(caller, "get_participant", TEST_OWNER)
+		if err != nil { t.Errorf("%s: %s", caller, err); continue }
+
+		var p Participant
+		if err := json.Unmarshal(bytes, &p); err != nil || p.ParticipantID != TEST_OWNER { t.Errorf("%s: get_participant = %s", caller, bytes) }
+	}
+
+	_, err := l.query(TEST_OPERATOR, "get_participant", TEST_OWNER)
+	check_error(t, err, `"code":"PERMISSION_DENIED"`)
+}
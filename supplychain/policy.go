@@ -0,0 +1,206 @@
+/*
+Copyright IBM Corp 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package supplychain
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/vsagineedu/learn-chaincode/ccerror"
+)
+
+const CONFIG_KEY_TYPE = "config"
+const POLICY_CONFIG = "policy"
+
+//==============================================================================================================================
+//	Policy - Maps each Invoke and Query function to the participant roles allowed to call it. The router checks the
+//			 caller against it before dispatch; the functions themselves then check the caller's relationship to the
+//			 SupplyItem (owner, operator, recipient). Participants holding a ReadAll role pass those checks on reads.
+//			 Views lists the SupplyItem fields each role may read.
+//==============================================================================================================================
+type Policy struct {
+	Functions map[string][]string `json:"functions"`
+	ReadAll   []string            `json:"readAll"`
+	Views     map[string][]string `json:"views"`
+}
+
+var item_roles = []string{ROLE_SUPPLIER, ROLE_OPERATOR, ROLE_OWNER}
+var read_roles = []string{ROLE_SUPPLIER, ROLE_OPERATOR, ROLE_OWNER, ROLE_AUDITOR, ROLE_REGULATOR}
+var admin_roles = []string{ROLE_ADMIN}
+
+//==============================================================================================================================
+//	 default_policy - The policy in force until an admin stores another with update_policy. The roles of each function
+//					  come from the registry.
+//==============================================================================================================================
+func default_policy() Policy {
+	return Policy{
+		Functions: default_functions(),
+		ReadAll:   []string{ROLE_AUDITOR, ROLE_REGULATOR},
+		Views:     default_views(),
+	}
+}
+
+func policy_key() (string, error) {
+	return create_composite_key(CONFIG_KEY_TYPE, []string{POLICY_CONFIG})
+}
+
+func permission_denied(function string) error {
+	return ccerror.New(ccerror.PERMISSION_DENIED, "Permission Denied. " + function)
+}
+
+//==============================================================================================================================
+//	 retrieve_policy - Returns the policy stored on the ledger laid over the default, so functions added since the
+//					   policy was stored keep their default roles.
+//==============================================================================================================================
+func (t *Chaincode) retrieve_policy(stub Stub) (Policy, error) {
+
+	policy := default_policy()
+
+	key, err := policy_key()
+	if err != nil { return policy, err }
+
+	bytes, err := stub.GetState(key)
+	if err != nil { fmt.Printf("RETRIEVE_POLICY: Failed to get policy: %s", err); return policy, errors.New("Error retrieving policy") }
+
+	if bytes == nil { return policy, nil }
+
+	err = json.Unmarshal(bytes, &policy)
+	if err != nil { fmt.Printf("RETRIEVE_POLICY: Corrupt policy record "+string(bytes)+": %s", err); return policy, ccerror.New(ccerror.CORRUPT_RECORD, "Corrupt policy record") }
+
+	return policy, nil
+}
+
+//==============================================================================================================================
+//	 authorize - Returns the uniform permission error unless the caller is an active Participant holding one of the
+//				 roles the policy allows for the function.
+//==============================================================================================================================
+func (t *Chaincode) authorize(stub Stub, caller string, function string) error {
+
+	policy, err := t.retrieve_policy(stub)
+	if err != nil { return err }
+
+	roles, ok := policy.Functions[function]
+	if !ok { return permission_denied(function) }
+
+	p, err := t.check_active_participant(stub, caller)
+	if err != nil { return permission_denied(function) }
+
+	for _, role := range roles {
+		if p.has_role(role) { return nil }
+	}
+
+	return permission_denied(function)
+}
+
+//==============================================================================================================================
+//	 reads_all - Reports whether the caller holds one of the policy's ReadAll roles.
+//==============================================================================================================================
+func (t *Chaincode) reads_all(stub Stub, caller string) bool {
+
+	policy, err := t.retrieve_policy(stub)
+	if err != nil { return false }
+
+	p, err := t.check_active_participant(stub, caller)
+	if err != nil { return false }
+
+	for _, role := range policy.ReadAll {
+		if p.has_role(role) { return true }
+	}
+
+	return false
+}
+
+//=================================================================================================================================
+//	 update_policy - Replaces the roles of the functions listed in the JSON object, the views of the roles listed and,
+//					 if given, the ReadAll roles. Functions and views not listed are kept. The update_policy entry itself cannot be changed so
+//					 that admins are never locked out.
+//=================================================================================================================================
+func (t *Chaincode) update_policy(stub Stub, caller string, args []string) ([]byte, error) {
+
+	//Args
+	//		0
+	//	policy JSON object of {functions: {name: [roles]}, readAll: [roles], views: {role: [fields]}}
+
+	if len(args) != 1 { return nil, ccerror.New(ccerror.INVALID_ARGUMENT, "UPDATE_POLICY: Incorrect number of arguments. Expecting a policy JSON object") }
+
+	var update Policy
+	err := json.Unmarshal([]byte(args[0]), &update)
+	if err != nil { return nil, ccerror.New(ccerror.INVALID_ARGUMENT, "UPDATE_POLICY: Invalid policy object: " + err.Error()) }
+
+	known := default_policy()
+	verr := new_validation_error("update_policy")
+
+	var names []string
+	for name := range update.Functions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		field := "functions." + name
+		if _, ok := known.Functions[name]; !ok { verr.Add(field, "Unknown function"); continue }
+		if name == "update_policy" { verr.Add(field, "Cannot be changed"); continue }
+		validate_roles(verr, field, update.Functions[name])
+	}
+	validate_roles(verr, "readAll", update.ReadAll)
+	validate_views(verr, update.Views)
+
+	if err := verr.Result(); err != nil { return nil, err }
+
+	policy, err := t.retrieve_policy(stub)
+	if err != nil { return nil, err }
+
+	for name, roles := range update.Functions {
+		policy.Functions[name] = roles
+	}
+	if update.ReadAll != nil {
+		policy.ReadAll = update.ReadAll
+	}
+	for role, fields := range update.Views {
+		policy.Views[role] = fields
+	}
+
+	key, err := policy_key()
+	if err != nil { return nil, err }
+
+	bytes, err := json.Marshal(policy)
+	if err != nil { fmt.Printf("UPDATE_POLICY: Error converting policy record: %s", err); return nil, errors.New("Error converting policy record") }
+
+	err = stub.PutState(key, bytes)
+	if err != nil { fmt.Printf("UPDATE_POLICY: Error storing policy record: %s", err); return nil, errors.New("Error storing policy record") }
+
+	return nil, nil
+}
+
+func validate_roles(verr *ccerror.Error, field string, roles []string) {
+	for i, role := range roles {
+		if !contains_string(participant_roles, role) { verr.Add(fmt.Sprintf("%s[%d]", field, i), "Unknown role "+role) }
+	}
+}
+
+//=================================================================================================================================
+//	 get_policy - Returns the policy in force.
+//=================================================================================================================================
+func (t *Chaincode) get_policy(stub Stub) ([]byte, error) {
+
+	policy, err := t.retrieve_policy(stub)
+	if err != nil { return nil, err }
+
+	return json.Marshal(policy)
+}
@@ -0,0 +1,135 @@
+/*
+Copyright IBM Corp 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package supplychain
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
+
+//==============================================================================================================================
+//	Quantity - A decimal amount of material held as a fixed point integer with QUANTITY_DECIMALS places. Using an integer
+//			   keeps sums exact, which matters once lots are split and merged. Serialised as a plain JSON number.
+//==============================================================================================================================
+type Quantity int64
+
+const QUANTITY_DECIMALS = 6
+const QUANTITY_SCALE = 1000000
+
+//==============================================================================================================================
+//	 parse_quantity - Converts a decimal string such as "12" or "0.25" into a Quantity. Exponent notation and more than
+//					  QUANTITY_DECIMALS fractional digits are rejected rather than rounded.
+//==============================================================================================================================
+func parse_quantity(value string) (Quantity, error) {
+
+	value = strings.TrimSpace(value)
+
+	negative := false
+	if strings.HasPrefix(value, "-") {
+		negative = true
+		value = value[1:]
+	}
+
+	whole, fraction := value, ""
+	if i := strings.Index(value, "."); i >= 0 {
+		whole, fraction = value[:i], value[i+1:]
+	}
+
+	if whole == "" || !is_digits(whole) || (fraction != "" && !is_digits(fraction)) || strings.HasSuffix(value, ".") {
+		return 0, errors.New("not a decimal number: " + value)
+	}
+	if len(fraction) > QUANTITY_DECIMALS {
+		return 0, errors.New("more than " + strconv.Itoa(QUANTITY_DECIMALS) + " decimal places: " + value)
+	}
+
+	fraction += strings.Repeat("0", QUANTITY_DECIMALS-len(fraction))
+
+	units, err := strconv.ParseInt(whole+fraction, 10, 64)
+	if err != nil {
+		return 0, errors.New("quantity out of range: " + value)
+	}
+
+	if negative {
+		units = -units
+	}
+
+	return Quantity(units), nil
+}
+
+func is_digits(value string) bool {
+	for _, c := range value {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+//==============================================================================================================================
+//	 String - Formats the Quantity as a decimal with trailing zeros removed.
+//==============================================================================================================================
+func (q Quantity) String() string {
+
+	units := int64(q)
+	sign := ""
+	if units < 0 {
+		sign = "-"
+		units = -units
+	}
+
+	whole := strconv.FormatInt(units/QUANTITY_SCALE, 10)
+	fraction := strconv.FormatInt(units%QUANTITY_SCALE, 10)
+	fraction = strings.Repeat("0", QUANTITY_DECIMALS-len(fraction)) + fraction
+	fraction = strings.TrimRight(fraction, "0")
+
+	if fraction == "" {
+		return sign + whole
+	}
+	return sign + whole + "." + fraction
+}
+
+func (q Quantity) MarshalJSON() ([]byte, error) {
+	return []byte(q.String()), nil
+}
+
+//==============================================================================================================================
+//	 UnmarshalJSON - Accepts either a JSON number or a quoted decimal string, so records written when MaterialQty was a
+//					 string can still be read.
+//==============================================================================================================================
+func (q *Quantity) UnmarshalJSON(data []byte) error {
+
+	value := string(data)
+	if value == "null" {
+		return nil
+	}
+	if strings.HasPrefix(value, "\"") {
+		unquoted, err := strconv.Unquote(value)
+		if err != nil {
+			return err
+		}
+		value = unquoted
+	}
+
+	parsed, err := parse_quantity(value)
+	if err != nil {
+		return err
+	}
+
+	*q = parsed
+	return nil
+}
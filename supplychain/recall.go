@@ -0,0 +1,315 @@
+/*
+Copyright IBM Corp 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package supplychain
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/vsagineedu/learn-chaincode/ccerror"
+)
+
+const RECALL_KEY_TYPE = "recall"
+
+//==============================================================================================================================
+//	RecallCriteria - Selects the SupplyItems of the recalling supplier to recall. Every field set must match.
+//					 CreatedFrom and CreatedTo are RFC3339 times bounding the transaction that created the SupplyItem,
+//					 both inclusive; SupplyItems with no creation history do not match a time window.
+//==============================================================================================================================
+type RecallCriteria struct {
+	MaterialType string `json:"materialType"`
+	CreatedFrom  string `json:"createdFrom"`
+	CreatedTo    string `json:"createdTo"`
+}
+
+//==============================================================================================================================
+//	Recall - A recall issued against a supplier's SupplyItems. MatchedIDs are the SupplyItems that met the criteria;
+//			 AffectedIDs adds every lot split or merged from them and every assembly they went into.
+//==============================================================================================================================
+type Recall struct {
+	RecallID    string         `json:"recallID"`
+	SupplierID  string         `json:"supplierID"`
+	Criteria    RecallCriteria `json:"criteria"`
+	Reason      string         `json:"reason"`
+	IssuedBy    string         `json:"issuedBy"`
+	Timestamp   string         `json:"timestamp"`
+	MatchedIDs  []string       `json:"matchedIDs"`
+	AffectedIDs []string       `json:"affectedIDs"`
+}
+
+//==============================================================================================================================
+//	ExposureEntry  - Where an affected SupplyItem is now and who holds it.
+//	RecallExposure - The response of get_recall_exposure.
+//==============================================================================================================================
+type ExposureEntry struct {
+	SupplyItemID  string           `json:"supplyItemID"`
+	OwnerID       string           `json:"ownerID"`
+	OperatorID    string           `json:"operatorID"`
+	Status        SupplyItemStatus `json:"status"`
+	Longitude     float64          `json:"longitude"`
+	Latitude      float64          `json:"latitude"`
+	MaterialQty   Quantity         `json:"materialQuantity"`
+	UnitOfMeasure UnitOfMeasure    `json:"unitOfMeasure"`
+}
+
+type RecallExposure struct {
+	RecallID string          `json:"recallID"`
+	Reason   string          `json:"reason"`
+	Owners   []string        `json:"owners"`
+	Items    []ExposureEntry `json:"items"`
+}
+
+func recall_key(recallID string) (string, error) {
+	return create_composite_key(RECALL_KEY_TYPE, []string{recallID})
+}
+
+//==============================================================================================================================
+//	 created_time - Returns the time of the transaction that created a SupplyItem, from its first HistoryEntry.
+//==============================================================================================================================
+func created_time(stub Stub, supplyItemID string) (time.Time, bool) {
+
+	key, err := history_key(supplyItemID, 1)
+	if err != nil { return time.Time{}, false }
+
+	bytes, err := stub.GetState(key)
+	if err != nil || bytes == nil { return time.Time{}, false }
+
+	var entry HistoryEntry
+	if err := json.Unmarshal(bytes, &entry); err != nil { return time.Time{}, false }
+
+	created, err := time.Parse(time.RFC3339Nano, entry.Timestamp)
+	if err != nil { return time.Time{}, false }
+
+	return created, true
+}
+
+//==============================================================================================================================
+//	 parse_recall_criteria - Decodes the criteria argument, adding a field error to verr for each invalid field, and
+//							 returns the time window bounds. A zero bound is open.
+//==============================================================================================================================
+func parse_recall_criteria(verr *ccerror.Error, input string) (RecallCriteria, time.Time, time.Time) {
+
+	var criteria RecallCriteria
+	var from, to time.Time
+
+	if err := json.Unmarshal([]byte(input), &criteria); err != nil {
+		verr.Add("criteria", "Argument is not a JSON object: "+err.Error())
+		return criteria, from, to
+	}
+
+	var err error
+	if criteria.CreatedFrom != "" {
+		if from, err = time.Parse(time.RFC3339, criteria.CreatedFrom); err != nil { verr.Add("criteria.createdFrom", "Must be an RFC3339 time") }
+	}
+	if criteria.CreatedTo != "" {
+		if to, err = time.Parse(time.RFC3339, criteria.CreatedTo); err != nil { verr.Add("criteria.createdTo", "Must be an RFC3339 time") }
+	}
+	if !from.IsZero() && !to.IsZero() && to.Before(from) { verr.Add("criteria.createdTo", "Must not be before createdFrom") }
+
+	return criteria, from, to
+}
+
+//=================================================================================================================================
+//	 issue_recall - Marks Recalled every SupplyItem of the supplier matching the criteria, and everything made from
+//					them: lots split or merged from a recalled lot and assemblies it was used in. Recalled SupplyItems
+//					cannot be transferred. Archived SupplyItems are recalled too and stay archived. Items whose status
+//					cannot become Recalled (Destroyed) are listed but left as they are. May be called by the supplier
+//					itself or by a regulator.
+//=================================================================================================================================
+func (t *Chaincode) issue_recall(stub Stub, caller string, args []string) ([]byte, error) {
+
+	//Args
+	//		0				1			2
+	//	supplierID	criteria JSON	reason
+
+	if len(args) != 3 { return nil, ccerror.New(ccerror.INVALID_ARGUMENT, "ISSUE_RECALL: Incorrect number of arguments. Expecting supplierID, a criteria JSON object and reason") }
+
+	supplierID, reason := args[0], args[2]
+
+	verr := new_validation_error("issue_recall")
+	criteria, from, to := parse_recall_criteria(verr, args[1])
+	validate_id(verr, "supplierID", supplierID, true)
+	if strings.TrimSpace(reason) == "" { verr.Add("reason", "Required") }
+	if len(reason) > MAX_TEXT_LENGTH { verr.Add("reason", "Must be at most "+fmt.Sprint(MAX_TEXT_LENGTH)+" characters") }
+	if err := verr.Result(); err != nil { return nil, err }
+
+	if caller != supplierID {
+		p, err := t.check_active_participant(stub, caller)
+		if err != nil || !p.has_role(ROLE_REGULATOR) { return nil, permission_denied("issue_recall") }
+	}
+
+	txTime, err := get_tx_time(stub)
+	if err != nil { return nil, err }
+
+	recall := Recall{
+		RecallID:    stub.GetTxID(),
+		SupplierID:  supplierID,
+		Criteria:    criteria,
+		Reason:      reason,
+		IssuedBy:    caller,
+		Timestamp:   txTime.Format(time.RFC3339Nano),
+		MatchedIDs:  []string{},
+		AffectedIDs: []string{},
+	}
+
+	var candidates []string
+
+	iter, err := range_query_composite_key(stub, INDEX_KEY_TYPE, []string{"supplierID", supplierID})
+	if err != nil { return nil, errors.New("Unable to query the ledger") }
+
+	for iter.HasNext() {
+		key, _, err := iter.Next()
+		if err != nil { iter.Close(); return nil, errors.New("Unable to query the ledger") }
+
+		_, parts, err := split_composite_key(key)
+		if err != nil || len(parts) != 3 { iter.Close(); return nil, ccerror.New(ccerror.CORRUPT_RECORD, "Corrupt index entry " + key) }
+
+		candidates = append(candidates, parts[2])
+	}
+	iter.Close()
+
+	for _, id := range candidates {
+		sItem, err := t.retrieve_SupplyItem(stub, id)
+		if err != nil { return nil, err }
+
+		if criteria.MaterialType != "" && sItem.MaterialType != criteria.MaterialType { continue }
+
+		if !from.IsZero() || !to.IsZero() {
+			created, ok := created_time(stub, id)
+			if !ok || (!from.IsZero() && created.Before(from)) || (!to.IsZero() && created.After(to)) { continue }
+		}
+
+		recall.MatchedIDs = append(recall.MatchedIDs, id)
+	}
+
+	visited := map[string]bool{}
+	queue := append([]string{}, recall.MatchedIDs...)
+	for _, id := range queue {
+		visited[id] = true
+	}
+
+	for len(queue) > 0 {
+		id := queue[0]
+		queue = queue[1:]
+
+		sItem, err := t.retrieve_SupplyItem(stub, id)
+		if err != nil { return nil, err }
+
+		recall.AffectedIDs = append(recall.AffectedIDs, id)
+
+		for _, next := range append(append([]string{}, sItem.ChildIDs...), sItem.UsedInIDs...) {
+			if !visited[next] {
+				visited[next] = true
+				queue = append(queue, next)
+			}
+		}
+
+		if check_transition(sItem, "issue_recall", STATUS_RECALLED) != nil { continue }
+
+		sItem.Status = STATUS_RECALLED
+
+		_, err = t.save_changes(stub, sItem, caller, "issue_recall")
+		if err != nil { fmt.Printf("ISSUE_RECALL: Error saving changes: %s", err); return nil, err }
+	}
+
+	key, err := recall_key(recall.RecallID)
+	if err != nil { return nil, err }
+
+	bytes, err := json.Marshal(recall)
+	if err != nil { fmt.Printf("ISSUE_RECALL: Error converting recall record: %s", err); return nil, errors.New("Error converting recall record") }
+
+	err = stub.PutState(key, bytes)
+	if err != nil { fmt.Printf("ISSUE_RECALL: Error storing recall record: %s", err); return nil, errors.New("Error storing recall record") }
+
+	return bytes, nil
+}
+
+//==============================================================================================================================
+//	 recalled_ids - Returns the set of SupplyItemIDs listed as affected by any recall.
+//==============================================================================================================================
+func recalled_ids(stub Stub) (map[string]bool, error) {
+
+	recalled := map[string]bool{}
+
+	iter, err := range_query_composite_key(stub, RECALL_KEY_TYPE, []string{})
+	if err != nil { return nil, errors.New("Unable to query recalls") }
+	defer iter.Close()
+
+	for iter.HasNext() {
+		_, bytes, err := iter.Next()
+		if err != nil { return nil, errors.New("Unable to query recalls") }
+
+		var recall Recall
+		err = json.Unmarshal(bytes, &recall)
+		if err != nil { return nil, ccerror.New(ccerror.CORRUPT_RECORD, "Corrupt recall record " + string(bytes)) }
+
+		for _, id := range recall.AffectedIDs {
+			recalled[id] = true
+		}
+	}
+
+	return recalled, nil
+}
+
+//=================================================================================================================================
+//	 get_recall_exposure - Lists the current owner, operator, status and location of every SupplyItem affected by a
+//						   recall. Visible to the recalling supplier, the issuer and the ReadAll roles.
+//=================================================================================================================================
+func (t *Chaincode) get_recall_exposure(stub Stub, caller string, recallID string) ([]byte, error) {
+
+	key, err := recall_key(recallID)
+	if err != nil { return nil, err }
+
+	bytes, err := stub.GetState(key)
+	if err != nil { return nil, errors.New("Unable to get recall " + recallID) }
+	if bytes == nil { return nil, ccerror.New(ccerror.NOT_FOUND, "No recall " + recallID) }
+
+	var recall Recall
+	err = json.Unmarshal(bytes, &recall)
+	if err != nil { return nil, ccerror.New(ccerror.CORRUPT_RECORD, "Corrupt recall record " + string(bytes)) }
+
+	if recall.SupplierID != caller && recall.IssuedBy != caller && !t.reads_all(stub, caller) { return nil, permission_denied("get_recall_exposure") }
+
+	exposure := RecallExposure{RecallID: recall.RecallID, Reason: recall.Reason, Owners: []string{}, Items: []ExposureEntry{}}
+
+	for _, id := range recall.AffectedIDs {
+		sItem, err := t.retrieve_SupplyItem(stub, id)
+		if err != nil { return nil, err }
+
+		exposure.Items = append(exposure.Items, ExposureEntry{
+			SupplyItemID:  sItem.SupplyItemID,
+			OwnerID:       sItem.OwnerID,
+			OperatorID:    sItem.OperatorID,
+			Status:        current_status(sItem),
+			Longitude:     sItem.Longitude,
+			Latitude:      sItem.Latitude,
+			MaterialQty:   sItem.MaterialQty,
+			UnitOfMeasure: sItem.UnitOfMeasure,
+		})
+
+		if sItem.OwnerID != "" && !contains_string(exposure.Owners, sItem.OwnerID) {
+			exposure.Owners = append(exposure.Owners, sItem.OwnerID)
+		}
+	}
+	sort.Strings(exposure.Owners)
+
+	return json.Marshal(exposure)
+}
@@ -0,0 +1,118 @@
+/*
+Copyright IBM Corp 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package supplychain
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestIssueRecall(t *testing.T) {
+
+	reversed := `{"createdFrom":"1970-01-02T00:00:00Z","createdTo":"1970-01-01T00:00:00Z"}`
+
+	tests := []struct {
+		name     string
+		caller   string
+		criteria string
+		reason   string
+		want     string
+		matched  string
+		affected string
+	}{
+		{"by material type",     TEST_SUPPLIER, `{"materialType":"steel"}`,               "Cracked", "",                               "A1,A3,S1,S2",        "A1,A3,S1,S2,ASM"},
+		{"by creation time",     TEST_SUPPLIER, `{"createdFrom":"1970-01-02T00:00:00Z"}`, "Cracked", "",                               "A3",                 "A3"},
+		{"by a regulator",       "regulator1",  `{"createdTo":"1970-01-01T00:00:00Z"}`,   "Cracked", "",                               "A1,ASM,B1,D1,S1,S2", "A1,ASM,B1,D1,S1,S2"},
+		{"nothing matches",      TEST_SUPPLIER, `{"materialType":"gold"}`,                "Cracked", "",                               "",                   ""},
+		{"by an owner",          TEST_OWNER,    `{}`,                                     "Cracked", `"code":"PERMISSION_DENIED"`,     "",                   ""},
+		{"without a reason",     TEST_SUPPLIER, `{}`,                                     " ",       `"field":"reason"`,               "",                   ""},
+		{"time window reversed", TEST_SUPPLIER, reversed,                                 "Cracked", `"field":"criteria.createdTo"`,   "",                   ""},
+		{"time not RFC3339",     TEST_SUPPLIER, `{"createdFrom":"yesterday"}`,            "Cracked", `"field":"criteria.createdFrom"`, "",                   ""},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			l := new_ledger(t)
+			l.register("regulator1", ROLE_REGULATOR)
+			l.create(a_supplyItem("A1"))
+			l.create(a_supplyItem("B1").with("materialType", "copper"))
+			l.create(a_supplyItem("D1").with("materialType", "copper"))
+			if _, err := l.invoke(TEST_OWNER, "update_status", "D1", string(STATUS_DESTROYED)); err != nil { t.Fatal(err) }
+			if _, err := l.invoke(TEST_OWNER, "split_supplyItem", "A1", `[{"supplyItemID":"S1","materialQuantity":10},{"supplyItemID":"S2","materialQuantity":2.5}]`); err != nil { t.Fatal(err) }
+			if _, err := l.invoke(TEST_OWNER, "assemble_supplyItem", a_supplyItem("ASM").with("materialType", "frame").json(), `[{"supplyItemID":"S1","materialQuantity":1},{"supplyItemID":"B1","materialQuantity":1}]`); err != nil { t.Fatal(err) }
+			l.now = l.now.Add(24 * time.Hour)
+			l.create(a_supplyItem("A3"))
+
+			bytes, err := l.invoke(tc.caller, "issue_recall", TEST_SUPPLIER, tc.criteria, tc.reason)
+			check_error(t, err, tc.want)
+			if tc.want != "" {
+				if sItem := get_supplyItem(t, l, "A3"); sItem.Status != STATUS_CREATED { t.Errorf("refused recall changed A3: %+v", sItem) }
+				return
+			}
+
+			var recall Recall
+			if err := json.Unmarshal(bytes, &recall); err != nil { t.Fatal(err) }
+
+			if matched := strings.Join(recall.MatchedIDs, ","); matched != tc.matched { t.Errorf("matchedIDs = %s, want %s", matched, tc.matched) }
+			if affected := strings.Join(recall.AffectedIDs, ","); affected != tc.affected { t.Errorf("affectedIDs = %s, want %s", affected, tc.affected) }
+			if recall.IssuedBy != tc.caller || recall.SupplierID != TEST_SUPPLIER || recall.Reason != tc.reason { t.Errorf("recall = %s", bytes) }
+
+			for _, id := range recall.AffectedIDs {
+				want := STATUS_RECALLED
+				if id == "D1" {
+					want = STATUS_DESTROYED
+				}
+				if sItem := get_supplyItem(t, l, id); sItem.Status != want { t.Errorf("%s status = %s, want %s", id, sItem.Status, want) }
+			}
+		})
+	}
+}
+
+func TestGetRecallExposure(t *testing.T) {
+
+	l := new_ledger(t)
+	l.register("owner2", ROLE_OWNER)
+	l.create(a_supplyItem("A1"))
+	l.create(a_supplyItem("A2").owner("owner2").operator(""))
+
+	bytes, err := l.invoke(TEST_SUPPLIER, "issue_recall", TEST_SUPPLIER, `{}`, "Contaminated")
+	if err != nil { t.Fatal(err) }
+
+	var recall Recall
+	if err := json.Unmarshal(bytes, &recall); err != nil { t.Fatal(err) }
+
+	if _, err := l.invoke("owner2", "update_location", "A2", "2.3522", "48.8566"); err != nil { t.Fatal(err) }
+
+	for _, caller := range []string{TEST_SUPPLIER, TEST_AUDITOR} {
+		bytes, err := l.query(caller, "get_recall_exposure", recall.RecallID)
+		if err != nil { t.Fatalf("get_recall_exposure as %s: %s", caller, err) }
+
+		var exposure RecallExposure
+		if err := json.Unmarshal(bytes, &exposure); err != nil { t.Fatal(err) }
+
+		if exposure.Reason != "Contaminated" || strings.Join(exposure.Owners, ",") != TEST_OWNER + ",owner2" || len(exposure.Items) != 2 { t.Fatalf("exposure = %s", bytes) }
+		if a2 := exposure.Items[1]; a2.SupplyItemID != "A2" || a2.Status != STATUS_RECALLED || a2.Latitude != 48.8566 || a2.MaterialQty.String() != "12.5" { t.Errorf("A2 = %+v", a2) }
+	}
+
+	_, err = l.query(TEST_OWNER, "get_recall_exposure", recall.RecallID)
+	check_error(t, err, `"code":"PERMISSION_DENIED"`)
+
+	_, err = l.query(TEST_SUPPLIER, "get_recall_exposure", "NOPE")
+	check_error(t, err, `"code":"NOT_FOUND"`)
+}
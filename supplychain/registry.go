@@ -0,0 +1,253 @@
+/*
+Copyright IBM Corp 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+
+package supplychain
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/vsagineedu/learn-chaincode/ccerror"
+)
+
+const FUNCTION_INVOKE = "invoke"
+const FUNCTION_QUERY = "query"
+
+const ARG_STRING = "string"
+const ARG_ID = "id"
+const ARG_NUMBER = "number"
+const ARG_INTEGER = "integer"
+const ARG_DATE = "date"
+const ARG_BASE64 = "base64"
+const ARG_OBJECT = "object"
+const ARG_ARRAY = "array"
+const ARG_BOOLEAN = "boolean"
+
+//==============================================================================================================================
+//	ArgSpec - One positional argument of a function. Optional arguments may be left out or passed as "", and only
+//			  trailing arguments may be optional. Other arguments may only be "" if they are strings or base64 content.
+//==============================================================================================================================
+type ArgSpec struct {
+	Name     string `json:"name"`
+	Type     string `json:"type"`
+	Optional bool   `json:"optional,omitempty"`
+}
+
+//==============================================================================================================================
+//	FunctionSpec - A function the Invoke or Query router dispatches: its kind, the argument lists it accepts and the
+//				   roles allowed to call it by default. Most functions have one signature; create_supplyItem also
+//				   accepts the original positional arguments.
+//==============================================================================================================================
+type FunctionSpec struct {
+	Name       string      `json:"name"`
+	Kind       string      `json:"kind"`
+	Signatures [][]ArgSpec `json:"signatures"`
+	Roles      []string    `json:"roles"`
+}
+
+func arg(name string, argType string) ArgSpec      { return ArgSpec{Name: name, Type: argType} }
+func optional(name string, argType string) ArgSpec { return ArgSpec{Name: name, Type: argType, Optional: true} }
+func args(specs ...ArgSpec) []ArgSpec              { return specs }
+
+func invoke(name string, roles []string, signatures ...[]ArgSpec) FunctionSpec {
+	return FunctionSpec{Name: name, Kind: FUNCTION_INVOKE, Signatures: signatures, Roles: roles}
+}
+
+func query(name string, roles []string, signatures ...[]ArgSpec) FunctionSpec {
+	return FunctionSpec{Name: name, Kind: FUNCTION_QUERY, Signatures: signatures, Roles: roles}
+}
+
+//==============================================================================================================================
+//	 registry - Every function the routers dispatch. A function missing from the table cannot be called, and a call
+//				whose arguments do not match one of its signatures is refused before the function runs. The roles are
+//				the defaults of the Policy, which admins may change with update_policy.
+//==============================================================================================================================
+var registry = []FunctionSpec{
+
+	invoke("create_supplyItem",        []string{ROLE_SUPPLIER},
+		args(arg("supplyItem", ARG_OBJECT)),
+		args(arg("supplyItemID", ARG_ID), arg("supplierID", ARG_ID), arg("operatorID", ARG_STRING), arg("ownerID", ARG_ID),
+			arg("longitude", ARG_NUMBER), arg("latitude", ARG_NUMBER), arg("description", ARG_STRING), arg("materialType", ARG_STRING),
+			arg("materialQuantity", ARG_NUMBER), arg("unitOfMeasure", ARG_STRING), arg("photo", ARG_STRING))),
+	invoke("update_supplyItem",        []string{ROLE_OWNER, ROLE_OPERATOR},  args(arg("supplyItemID", ARG_ID), arg("fields", ARG_OBJECT))),
+	invoke("propose_transfer",         []string{ROLE_OWNER},                 args(arg("supplyItemID", ARG_ID), arg("kind", ARG_STRING), arg("recipient", ARG_ID))),
+	invoke("accept_transfer",          item_roles,                           args(arg("supplyItemID", ARG_ID), arg("kind", ARG_STRING))),
+	invoke("reject_transfer",          item_roles,                           args(arg("supplyItemID", ARG_ID), arg("kind", ARG_STRING))),
+	invoke("cancel_transfer",          []string{ROLE_OWNER},                 args(arg("supplyItemID", ARG_ID), arg("kind", ARG_STRING))),
+	invoke("update_status",            []string{ROLE_OWNER, ROLE_OPERATOR},  args(arg("supplyItemID", ARG_ID), arg("status", ARG_STRING))),
+	invoke("update_location",          []string{ROLE_OWNER, ROLE_OPERATOR},  args(arg("supplyItemID", ARG_ID), arg("longitude", ARG_NUMBER), arg("latitude", ARG_NUMBER))),
+	invoke("split_supplyItem",         []string{ROLE_OWNER},                 args(arg("supplyItemID", ARG_ID), arg("parts", ARG_ARRAY))),
+	invoke("merge_supplyItems",        []string{ROLE_OWNER},                 args(arg("supplyItemID", ARG_ID), arg("sources", ARG_ARRAY))),
+	invoke("assemble_supplyItem",      []string{ROLE_OWNER},                 args(arg("supplyItem", ARG_OBJECT), arg("components", ARG_ARRAY))),
+	invoke("issue_recall",             []string{ROLE_SUPPLIER, ROLE_REGULATOR}, args(arg("supplierID", ARG_ID), arg("criteria", ARG_OBJECT), arg("reason", ARG_STRING))),
+	invoke("register_participant",     admin_roles,                          args(arg("participant", ARG_OBJECT))),
+	invoke("update_participant",       admin_roles,                          args(arg("participantID", ARG_ID), arg("fields", ARG_OBJECT))),
+	invoke("suspend_participant",      admin_roles,                          args(arg("participantID", ARG_ID))),
+	invoke("update_policy",            admin_roles,                          args(arg("policy", ARG_OBJECT))),
+	invoke("register_unit",            admin_roles,                          args(arg("unit", ARG_OBJECT))),
+	invoke("update_attachment_config", admin_roles,                          args(arg("config", ARG_OBJECT))),
+	invoke("migrate_supplyItem_index", admin_roles,                          args(optional("batchSize", ARG_INTEGER))),
+	invoke("reindex_supplyItems",      admin_roles,                          args(optional("pageSize", ARG_INTEGER), optional("cursor", ARG_STRING))),
+	invoke("migrate_records",          admin_roles,                          args(arg("ownerRule", ARG_STRING), optional("pageSize", ARG_INTEGER), optional("cursor", ARG_STRING))),
+	invoke("archive_supplyItem",       []string{ROLE_OWNER},                 args(arg("supplyItemID", ARG_ID))),
+	invoke("restore_supplyItem",       []string{ROLE_OWNER},                 args(arg("supplyItemID", ARG_ID))),
+	invoke("purge_archived",           admin_roles,                          args(optional("batchSize", ARG_INTEGER))),
+	invoke("update_retention_config",  admin_roles,                          args(arg("config", ARG_OBJECT))),
+
+	query("get_supplyItem",             read_roles,                          args(arg("supplyItemID", ARG_ID))),
+	query("get_supplyItems",            read_roles,                          args(optional("pageSize", ARG_INTEGER), optional("cursor", ARG_STRING), optional("includeArchived", ARG_BOOLEAN))),
+	query("query_supplyItems",          read_roles,                          args(arg("filter", ARG_OBJECT), optional("pageSize", ARG_INTEGER), optional("cursor", ARG_STRING))),
+	query("get_pending_transfers",      item_roles,                          args()),
+	query("get_supplyItem_history",     read_roles,                          args(arg("supplyItemID", ARG_ID))),
+	query("get_allowed_transitions",    read_roles,                          args(arg("supplyItemID", ARG_ID))),
+	query("get_supplyItem_origins",     read_roles,                          args(arg("supplyItemID", ARG_ID))),
+	query("get_supplyItem_descendants", read_roles,                          args(arg("supplyItemID", ARG_ID))),
+	query("trace_components",           read_roles,                          args(arg("supplyItemID", ARG_ID))),
+	query("get_location_trail",         read_roles,                          args(arg("supplyItemID", ARG_ID))),
+	query("find_supplyItems_in_box",    read_roles,                          args(arg("minLongitude", ARG_NUMBER), arg("minLatitude", ARG_NUMBER), arg("maxLongitude", ARG_NUMBER), arg("maxLatitude", ARG_NUMBER), optional("includeArchived", ARG_BOOLEAN), optional("cursor", ARG_STRING))),
+	query("find_supplyItems_near",      read_roles,                          args(arg("longitude", ARG_NUMBER), arg("latitude", ARG_NUMBER), arg("radius", ARG_NUMBER), optional("includeArchived", ARG_BOOLEAN), optional("cursor", ARG_STRING))),
+	query("get_holdings",               read_roles,                          args(optional("filter", ARG_OBJECT))),
+	query("get_supplier_volume",        read_roles,                          args(arg("from", ARG_DATE), arg("to", ARG_DATE), optional("supplierID", ARG_ID))),
+	query("get_recall_exposure",        []string{ROLE_SUPPLIER, ROLE_AUDITOR, ROLE_REGULATOR}, args(arg("recallID", ARG_ID))),
+	query("verify_attachment",          read_roles,                          args(arg("supplyItemID", ARG_ID), arg("name", ARG_STRING), arg("content", ARG_BASE64))),
+	query("get_participant",            participant_roles,                   args(arg("participantID", ARG_ID))),
+	query("get_policy",                 participant_roles,                   args()),
+	query("get_units",                  participant_roles,                   args()),
+	query("get_attachment_config",      participant_roles,                   args()),
+	query("get_retention_config",       participant_roles,                   args()),
+	query("describe",                   participant_roles,                   args()),
+	query("get_schema_versions",        admin_roles,                         args()),
+}
+
+//==============================================================================================================================
+//	 lookup_function - Returns the registered function of the given name and kind.
+//==============================================================================================================================
+func lookup_function(name string, kind string) (FunctionSpec, bool) {
+	for _, spec := range registry {
+		if spec.Name == name && spec.Kind == kind {
+			return spec, true
+		}
+	}
+	return FunctionSpec{}, false
+}
+
+//==============================================================================================================================
+//	 default_functions - The default roles of every registered function, for the Policy.
+//==============================================================================================================================
+func default_functions() map[string][]string {
+	functions := map[string][]string{}
+	for _, spec := range registry {
+		functions[spec.Name] = spec.Roles
+	}
+	return functions
+}
+
+//==============================================================================================================================
+//	 check_args - Returns an error unless args match one of the function's signatures. A wrong number of arguments is
+//				  reported with the signatures expected; values of the wrong type as an INVALID_ARGUMENT error naming each one.
+//==============================================================================================================================
+func (spec FunctionSpec) check_args(args []string) error {
+
+	for _, signature := range spec.Signatures {
+
+		required := 0
+		for i, a := range signature {
+			if !a.Optional { required = i + 1 }
+		}
+		if len(args) < required || len(args) > len(signature) { continue }
+
+		verr := new_validation_error(spec.Name)
+		for i, value := range args {
+			check_arg(verr, signature[i], value)
+		}
+		return verr.Result()
+	}
+
+	var expected []string
+	for _, signature := range spec.Signatures {
+		expected = append(expected, usage(signature))
+	}
+
+	return ccerror.New(ccerror.INVALID_ARGUMENT, strings.ToUpper(spec.Name) + ": Incorrect number of arguments. Expecting " + strings.Join(expected, " or "))
+}
+
+func usage(signature []ArgSpec) string {
+
+	if len(signature) == 0 { return "no arguments" }
+
+	var names []string
+	for _, a := range signature {
+		if a.Optional {
+			names = append(names, a.Name+" (optional)")
+		} else {
+			names = append(names, a.Name)
+		}
+	}
+	return strings.Join(names, ", ")
+}
+
+//==============================================================================================================================
+//	 check_arg - Adds a field error to verr, named after the argument, if value is not of the argument's type.
+//==============================================================================================================================
+func check_arg(verr *ccerror.Error, a ArgSpec, value string) {
+
+	if value == "" {
+		if !a.Optional && a.Type != ARG_STRING && a.Type != ARG_BASE64 { verr.Add(a.Name, "Required") }
+		return
+	}
+
+	switch a.Type {
+	case ARG_ID:
+		if validate_composite_key_attribute(value) != nil { verr.Add(a.Name, "Contains a reserved character") }
+	case ARG_NUMBER:
+		if _, err := strconv.ParseFloat(strings.TrimSpace(value), 64); err != nil { verr.Add(a.Name, "Must be a number") }
+	case ARG_INTEGER:
+		if _, err := strconv.Atoi(strings.TrimSpace(value)); err != nil { verr.Add(a.Name, "Must be an integer") }
+	case ARG_DATE:
+		if _, err := time.Parse(VOLUME_DAY_FORMAT, value); err != nil { verr.Add(a.Name, "Must be a date as YYYY-MM-DD") }
+	case ARG_BOOLEAN:
+		if _, err := strconv.ParseBool(value); err != nil { verr.Add(a.Name, "Must be true or false") }
+	case ARG_BASE64:
+		if _, err := base64.StdEncoding.DecodeString(value); err != nil { verr.Add(a.Name, "Must be base64 encoded") }
+	case ARG_OBJECT:
+		var object map[string]json.RawMessage
+		if err := json.Unmarshal([]byte(value), &object); err != nil || object == nil { verr.Add(a.Name, "Argument is not a JSON object") }
+	case ARG_ARRAY:
+		var array []json.RawMessage
+		if err := json.Unmarshal([]byte(value), &array); err != nil || array == nil { verr.Add(a.Name, "Argument is not a JSON array") }
+	}
+}
+
+//=================================================================================================================================
+//	 describe - Returns the registry as JSON, with the roles of the Policy in force, so clients can discover the API.
+//=================================================================================================================================
+func (t *Chaincode) describe(stub Stub) ([]byte, error) {
+
+	policy, err := t.retrieve_policy(stub)
+	if err != nil { return nil, err }
+
+	functions := make([]FunctionSpec, len(registry))
+	for i, spec := range registry {
+		functions[i] = spec
+		functions[i].Roles = policy.Functions[spec.Name]
+	}
+
+	return json.Marshal(functions)
+}
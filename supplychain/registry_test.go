@@ -0,0 +1,141 @@
+/*
+Copyright IBM Corp 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+
+package supplychain
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/vsagineedu/learn-chaincode/ccerror"
+)
+
+func TestRegistryCheckArgs(t *testing.T) {
+
+	tests := []struct {
+		name     string
+		function string
+		kind     string
+		args     []string
+		want     string
+	}{
+		{"JSON form",                "create_supplyItem",        FUNCTION_INVOKE, []string{`{}`},                                  ""},
+		{"positional form",          "create_supplyItem",        FUNCTION_INVOKE, a_supplyItem("A1").args(),                      ""},
+		{"between the forms",        "create_supplyItem",        FUNCTION_INVOKE, []string{`{}`, `{}`},                            "Expecting supplyItem or supplyItemID, supplierID"},
+		{"no arguments expected",    "get_policy",               FUNCTION_QUERY,  []string{"x"},                                   "Expecting no arguments"},
+		{"optional args left out",   "get_supplyItems",          FUNCTION_QUERY,  []string{},                                      ""},
+		{"optional args empty",      "get_supplyItems",          FUNCTION_QUERY,  []string{"", ""},                                ""},
+		{"too many optional args",   "get_supplyItems",          FUNCTION_QUERY,  []string{"1", "", "", ""},                       "Incorrect number of arguments"},
+		{"missing required arg",     "update_supplyItem",        FUNCTION_INVOKE, []string{"A1"},                                  "Incorrect number of arguments"},
+		{"empty required arg",       "update_supplyItem",        FUNCTION_INVOKE, []string{"", `{}`},                              `"field":"supplyItemID","message":"Required"`},
+		{"reserved character",       "get_supplyItem",           FUNCTION_QUERY,  []string{"A\x001"},                              `"field":"supplyItemID"`},
+		{"not an object",            "update_supplyItem",        FUNCTION_INVOKE, []string{"A1", `[]`},                            `"field":"fields"`},
+		{"not an array",             "split_supplyItem",         FUNCTION_INVOKE, []string{"A1", `{}`},                            `"field":"parts"`},
+		{"not a number",             "find_supplyItems_near",    FUNCTION_QUERY,  []string{"1", "north", "10"},                    `"field":"latitude"`},
+		{"not an integer",           "reindex_supplyItems",      FUNCTION_INVOKE, []string{"1.5"},                                 `"field":"pageSize"`},
+		{"not a date",               "get_supplier_volume",      FUNCTION_QUERY,  []string{"2016-01-01T00:00:00Z", "2016-02-01"},  `"field":"from"`},
+		{"not a boolean",            "get_supplyItems",          FUNCTION_QUERY,  []string{"", "", "yes"},                         `"field":"includeArchived"`},
+		{"not base64",               "verify_attachment",        FUNCTION_QUERY,  []string{"A1", "photo", "%%"},                   `"field":"content"`},
+		{"every bad value reported", "update_location",          FUNCTION_INVOKE, []string{"", "east", "north"},                   `"field":"latitude"`},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			spec, ok := lookup_function(tc.function, tc.kind)
+			if !ok { t.Fatalf("%s is not registered as %s", tc.function, tc.kind) }
+
+			check_error(t, spec.check_args(tc.args), tc.want)
+		})
+	}
+}
+
+//==============================================================================================================================
+//	 TestRegistryRouted - Every registered function must be dispatched by its router: a call whose arguments pass the
+//						  registry must reach the function rather than fall through to the unknown function error. The
+//						  caller holds every role so that authorize lets each call through to the router.
+//==============================================================================================================================
+func TestRegistryRouted(t *testing.T) {
+
+	l := new_ledger(t)
+	l.register("everyone", participant_roles...)
+
+	values := map[string]string{ARG_STRING: "x", ARG_ID: "NOPE", ARG_NUMBER: "1", ARG_INTEGER: "1", ARG_DATE: "2016-01-01",
+		ARG_BASE64: "", ARG_OBJECT: "{}", ARG_ARRAY: "[]", ARG_BOOLEAN: "true"}
+
+	seen := map[string]bool{}
+
+	for _, spec := range registry {
+		if seen[spec.Name] { t.Fatalf("%s is registered twice", spec.Name) }
+		seen[spec.Name] = true
+
+		if len(spec.Signatures) == 0 { t.Fatalf("%s has no signatures", spec.Name) }
+		for _, role := range spec.Roles {
+			if !contains_string(participant_roles, role) { t.Fatalf("%s allows unknown role %s", spec.Name, role) }
+		}
+
+		var args []string
+		for _, a := range spec.Signatures[0] {
+			args = append(args, values[a.Type])
+		}
+		if err := spec.check_args(args); err != nil { t.Fatalf("%s: %s", spec.Name, err) }
+
+		var err error
+		if spec.Kind == FUNCTION_INVOKE {
+			_, err = l.invoke("everyone", spec.Name, args...)
+			if _, qerr := l.query("everyone", spec.Name, args...); qerr == nil || !strings.Contains(qerr.Error(), "unknown function") { t.Fatalf("invoke %s is accepted as a query: %v", spec.Name, qerr) }
+		} else {
+			_, err = l.query("everyone", spec.Name, args...)
+		}
+
+		if err != nil && (strings.Contains(err.Error(), "doesn't exist") || strings.Contains(err.Error(), "unknown function")) { t.Fatalf("%s %s is not routed: %s", spec.Kind, spec.Name, err) }
+		if ccerror.CodeOf(err) == ccerror.PERMISSION_DENIED { t.Fatalf("%s %s was refused before reaching the function: %s", spec.Kind, spec.Name, err) }
+	}
+}
+
+func TestDescribe(t *testing.T) {
+
+	l := new_ledger(t)
+
+	_, err := l.invoke(TEST_ADMIN, "update_policy", `{"functions":{"create_supplyItem":["owner"]}}`)
+	check_error(t, err, "")
+
+	tests := []struct {
+		name   string
+		caller string
+		want   string
+	}{
+		{"participant", TEST_OWNER, ""},
+		{"stranger",    "stranger", `"code":"PERMISSION_DENIED"`},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			bytes, err := l.query(tc.caller, "describe")
+			check_error(t, err, tc.want)
+			if tc.want != "" { return }
+
+			var functions []FunctionSpec
+			if err := json.Unmarshal(bytes, &functions); err != nil { t.Fatal(err) }
+			if len(functions) != len(registry) { t.Fatalf("described %d of %d functions", len(functions), len(registry)) }
+
+			for _, spec := range functions {
+				if spec.Name == "create_supplyItem" && (len(spec.Signatures) != 2 || strings.Join(spec.Roles, ",") != ROLE_OWNER) { t.Fatalf("create_supplyItem described as %+v", spec) }
+			}
+		})
+	}
+}
@@ -0,0 +1,338 @@
+/*
+Copyright IBM Corp 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+
+package supplychain
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/vsagineedu/learn-chaincode/ccerror"
+)
+
+//==============================================================================================================================
+//	Schema versions of SupplyItem records. Records written before SchemaVersion was added carry none; their version is
+//	recognised from their fields by record_schema_version.
+//
+//		1	Written by the bluechain and bluechainlatest chaincodes before they served this package. Every field is a
+//			string and there is no status or revision; only bluechainlatest recorded an owner. Both built the record
+//			from a MaterialQty key their SupplyItem did not read, so the quantity is always empty.
+//		2	Written by this chaincode before SchemaVersion was added.
+//		3	Current. Adds SchemaVersion.
+//==============================================================================================================================
+const SCHEMA_BLUECHAIN = 1
+const SCHEMA_UNVERSIONED = 2
+const CURRENT_SCHEMA_VERSION = 3
+
+//==============================================================================================================================
+//	LegacySupplyItem - A SupplyItem as the bluechain and bluechainlatest chaincodes stored it before they served this
+//					   package.
+//==============================================================================================================================
+type LegacySupplyItem struct {
+	SupplierID    string      `json:"supplierID"`
+	OperatorID    string      `json:"operatorID"`
+	Longitude     string      `json:"longitude"`
+	Latitude      string      `json:"latitude"`
+	Description   string      `json:"description"`
+	MaterialType  string      `json:"materialType"`
+	MaterialQty   string      `json:"materialQuantity"`
+	UnitOfMeasure string      `json:"unitOfMeasure"`
+	Photo         *Attachment `json:"photo"`
+	SupplyItemID  string      `json:"supplyItemID"`
+	OwnerID       string      `json:"ownerID"`				// Empty in bluechain records
+}
+
+//==============================================================================================================================
+//	OwnerRule - Chooses the OwnerID of a record that has none. Rules are named by the ownerRule argument of
+//				migrate_records; owner_rule lists them.
+//==============================================================================================================================
+type OwnerRule func(sItem SupplyItem) string
+
+//==============================================================================================================================
+//	 owner_rule - Returns the OwnerRule named by spec:
+//
+//		supplier					the supplier owns the record
+//		operator					the operator owns the record, or the supplier if it has no operator
+//		participant:<participantID>	the given participant owns every record
+//==============================================================================================================================
+func owner_rule(spec string) (OwnerRule, error) {
+
+	name, param := spec, ""
+	if i := strings.Index(spec, ":"); i >= 0 {
+		name, param = spec[:i], spec[i+1:]
+	}
+
+	switch {
+	case name == "supplier" && param == "":
+		return func(sItem SupplyItem) string { return sItem.SupplierID }, nil
+	case name == "operator" && param == "":
+		return func(sItem SupplyItem) string {
+			if sItem.OperatorID != "" { return sItem.OperatorID }
+			return sItem.SupplierID
+		}, nil
+	case name == "participant" && param != "":
+		return func(sItem SupplyItem) string { return param }, nil
+	}
+
+	verr := new_validation_error("migrate_records")
+	verr.Add("ownerRule", "Must be supplier, operator or participant:<participantID>")
+	return nil, verr
+}
+
+//==============================================================================================================================
+//	 record_schema_version - Returns the schema version of a stored SupplyItem record.
+//==============================================================================================================================
+func record_schema_version(bytes []byte) (int, error) {
+
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(bytes, &raw); err != nil || raw == nil { return 0, ccerror.New(ccerror.CORRUPT_RECORD, "Corrupt supplyItem record " + string(bytes)) }
+
+	if value, ok := raw["schemaVersion"]; ok {
+		var version int
+		if err := json.Unmarshal(value, &version); err != nil || version <= 0 { return 0, ccerror.New(ccerror.CORRUPT_RECORD, "Corrupt schemaVersion in supplyItem record " + string(bytes)) }
+		return version, nil
+	}
+
+	if _, ok := raw["revision"]; ok { return SCHEMA_UNVERSIONED, nil }
+
+	return SCHEMA_BLUECHAIN, nil
+}
+
+//==============================================================================================================================
+//	 decode_supplyItem_record - Decodes a stored SupplyItem record of any schema version into the current SupplyItem.
+//								The fields a version lacks are left empty, and SchemaVersion is left as stored so the
+//								caller can tell whether the record needs migrating.
+//==============================================================================================================================
+func decode_supplyItem_record(bytes []byte) (SupplyItem, error) {
+
+	var sItem SupplyItem
+
+	version, err := record_schema_version(bytes)
+	if err != nil { return sItem, err }
+
+	if version > CURRENT_SCHEMA_VERSION { return sItem, ccerror.New(ccerror.CORRUPT_RECORD, "SupplyItem record has unknown schema version " + strconv.Itoa(version)) }
+
+	if version > SCHEMA_BLUECHAIN {
+		err = json.Unmarshal(bytes, &sItem)
+		if err != nil { return sItem, ccerror.New(ccerror.CORRUPT_RECORD, "Corrupt supplyItem record " + string(bytes)) }
+		sItem.SchemaVersion = version
+		return sItem, nil
+	}
+
+	var legacy LegacySupplyItem
+	err = json.Unmarshal(bytes, &legacy)
+	if err != nil { return sItem, ccerror.New(ccerror.CORRUPT_RECORD, "Corrupt supplyItem record " + string(bytes)) }
+
+	sItem = SupplyItem{
+		SupplyItemID:  legacy.SupplyItemID,
+		SupplierID:    legacy.SupplierID,
+		OperatorID:    legacy.OperatorID,
+		OwnerID:       legacy.OwnerID,
+		Description:   legacy.Description,
+		MaterialType:  legacy.MaterialType,
+		UnitOfMeasure: UnitOfMeasure(legacy.UnitOfMeasure),
+		Photo:         legacy.Photo,
+		Status:        STATUS_CREATED,
+		SchemaVersion: SCHEMA_BLUECHAIN,
+	}
+
+	cerr := ccerror.New(ccerror.CORRUPT_RECORD, "Invalid fields in supplyItem record " + legacy.SupplyItemID)
+
+	sItem.Longitude = parse_legacy_number(cerr, "longitude", legacy.Longitude)
+	sItem.Latitude = parse_legacy_number(cerr, "latitude", legacy.Latitude)
+
+	if qty := strings.TrimSpace(legacy.MaterialQty); qty != "" {			// Never stored in practice; upgrade_supplyItem flags its absence
+		sItem.MaterialQty, err = parse_quantity(qty)
+		if err != nil { cerr.Add("materialQuantity", "Invalid value: "+err.Error()) }
+	}
+
+	return sItem, cerr.Result()
+}
+
+//==============================================================================================================================
+//	 parse_legacy_number - Reads a number stored as a string by bluechain, which left unset fields empty.
+//==============================================================================================================================
+func parse_legacy_number(cerr *ccerror.Error, field string, value string) float64 {
+	if strings.TrimSpace(value) == "" { return 0 }
+	return parse_coordinate(cerr, field, strings.TrimSpace(value))
+}
+
+//==============================================================================================================================
+//	MigrationResult - The response of migrate_records. Records that could not be upgraded are listed in Failed and left
+//					  as they were, so they can be corrected and the migration run again.
+//==============================================================================================================================
+type MigrationResult struct {
+	Migrated   int                `json:"migrated"`
+	Current    int                `json:"current"`
+	Failed     []MigrationFailure `json:"failed"`
+	NextCursor string             `json:"nextCursor"`
+}
+
+type MigrationFailure struct {
+	SupplyItemID string         `json:"supplyItemID"`
+	Error        *ccerror.Error `json:"error"`
+}
+
+//=================================================================================================================================
+//	 migrate_records - Upgrades the SupplyItem records on one page to the current schema version. Records with no owner
+//					   are given one by the named OwnerRule. Each upgraded record is saved as a new revision, so the
+//					   history shows the migration. Returns the cursor to pass to the next call, or "" when done.
+//					   Ledgers still listing their SupplyItems in a SupplyItemIDs_Holder must first be converted with
+//					   migrate_supplyItem_index.
+//=================================================================================================================================
+func (t *Chaincode) migrate_records(stub Stub, caller string, args []string) ([]byte, error) {
+
+	//Args
+	//		0			1			2
+	//	ownerRule	pageSize	cursor		(pageSize and cursor optional)
+
+	rule, err := owner_rule(args[0])
+	if err != nil { return nil, err }
+
+	pageSize, cursor, err := parse_page_args(args[1:])
+	if err != nil { return nil, err }
+
+	holder, err := stub.GetState(LEGACY_HOLDER_KEY)
+	if err != nil { return nil, errors.New("Unable to get supplyItemIDs") }
+	if holder != nil { return nil, ccerror.New(ccerror.CONFLICT, "MIGRATE_RECORDS: SupplyItems are still listed in the SupplyItemIDs_Holder. Run migrate_supplyItem_index first") }
+
+	result := MigrationResult{Failed: []MigrationFailure{}}
+
+	next, err := page_keys(stub, SUPPLYITEM_KEY_TYPE, []string{}, cursor, pageSize, func(key string, bytes []byte) (bool, error) {
+
+		sItem, err := decode_supplyItem_record(bytes)
+		if err != nil {
+			result.Failed = append(result.Failed, migration_failure(key, err))
+			return true, nil
+		}
+
+		if sItem.SchemaVersion == CURRENT_SCHEMA_VERSION {
+			result.Current++
+			return true, nil
+		}
+
+		err = t.upgrade_supplyItem(stub, &sItem, rule)
+		if err == nil {
+			_, err = t.save_changes(stub, sItem, caller, "migrate_records")
+		}
+		if err != nil {
+			if ccerror.CodeOf(err) == ccerror.INTERNAL { return false, err }		// The ledger itself failed; stop
+			result.Failed = append(result.Failed, migration_failure(key, err))
+			return true, nil
+		}
+
+		result.Migrated++
+		return true, nil
+	})
+
+	if err != nil { return nil, err }
+
+	result.NextCursor = next
+
+	return json.Marshal(result)
+}
+
+func migration_failure(key string, err error) MigrationFailure {
+	failure := MigrationFailure{SupplyItemID: key, Error: ccerror.Wrap("migrate_records", err).(*ccerror.Error)}
+	if _, attributes, err := split_composite_key(key); err == nil && len(attributes) > 0 {
+		failure.SupplyItemID = attributes[0]
+	}
+	return failure
+}
+
+//==============================================================================================================================
+//	 upgrade_supplyItem - Fills the fields an older record lacks: the owner, from rule, and the canonical unit code. A
+//						  bluechain record without a quantity is upgraded with QuantityUnrecorded set, so that its
+//						  owner can give the quantity with update_supplyItem. A photo bluechain stored inline must
+//						  fit within the inline limit; larger ones are refused so that the owner can store them off
+//						  the ledger and update the photo.
+//==============================================================================================================================
+func (t *Chaincode) upgrade_supplyItem(stub Stub, sItem *SupplyItem, rule OwnerRule) error {
+
+	verr := new_validation_error("migrate_records")
+
+	if sItem.OwnerID == "" {
+		sItem.OwnerID = rule(*sItem)
+		validate_id(verr, "ownerID", sItem.OwnerID, true)
+	}
+
+	if sItem.Status == "" { sItem.Status = STATUS_CREATED }
+
+	if err := t.resolve_unit(stub, verr, sItem); err != nil { return err }
+	if sItem.UnitOfMeasure == "" { verr.Add("unitOfMeasure", "Required") }
+	if sItem.SchemaVersion == SCHEMA_BLUECHAIN && sItem.MaterialQty == 0 { sItem.QuantityUnrecorded = true }
+	if sItem.MaterialQty < 0 { verr.Add("materialQuantity", "Must not be negative") }
+
+	if err := t.check_inline_size(stub, verr, *sItem); err != nil { return err }
+
+	return verr.Result()
+}
+
+//==============================================================================================================================
+//	SchemaVersionCounts - The response of get_schema_versions. Versions maps each schema version to the number of
+//						  SupplyItem records at it. Unindexed counts the records still listed in a SupplyItemIDs_Holder,
+//						  which are all at version 1, and Corrupt those whose version cannot be read.
+//==============================================================================================================================
+type SchemaVersionCounts struct {
+	Current   int            `json:"current"`
+	Versions  map[string]int `json:"versions"`
+	Unindexed int            `json:"unindexed"`
+	Corrupt   int            `json:"corrupt"`
+}
+
+//=================================================================================================================================
+//	 get_schema_versions - Counts the SupplyItem records at each schema version. Reads every record, so it is meant for
+//						   following a migration rather than for frequent use.
+//=================================================================================================================================
+func (t *Chaincode) get_schema_versions(stub Stub) ([]byte, error) {
+
+	counts := SchemaVersionCounts{Current: CURRENT_SCHEMA_VERSION, Versions: map[string]int{}}
+
+	prefix, err := create_composite_key(SUPPLYITEM_KEY_TYPE, []string{})
+	if err != nil { return nil, err }
+
+	iter, err := stub.RangeQueryState(prefix, prefix+MAX_UNICODE_RUNE)
+	if err != nil { return nil, errors.New("Unable to query the ledger") }
+	defer iter.Close()
+
+	for iter.HasNext() {
+		_, bytes, err := iter.Next()
+		if err != nil { return nil, errors.New("Unable to query the ledger") }
+
+		version, err := record_schema_version(bytes)
+		if err != nil {
+			counts.Corrupt++
+			continue
+		}
+		counts.Versions[strconv.Itoa(version)]++
+	}
+
+	bytes, err := stub.GetState(LEGACY_HOLDER_KEY)
+	if err != nil { return nil, errors.New("Unable to get supplyItemIDs") }
+
+	if bytes != nil {
+		var holder SupplyItemIDs_Holder
+		err = json.Unmarshal(bytes, &holder)
+		if err != nil { fmt.Printf("GET_SCHEMA_VERSIONS: Corrupt SupplyItemIDs_Holder record: %s", err); return nil, ccerror.New(ccerror.CORRUPT_RECORD, "Corrupt SupplyItemIDs_Holder record") }
+		counts.Unindexed = len(holder.SupplyItemIDs)
+	}
+
+	return json.Marshal(counts)
+}
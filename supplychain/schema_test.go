@@ -0,0 +1,247 @@
+/*
+Copyright IBM Corp 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+
+package supplychain
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+//==============================================================================================================================
+//	 bluechain_record - A record as the bluechain save_changes wrote it. Its create_supplyItem set the quantity through
+//						a MaterialQty key, so materialQuantity is always empty.
+//==============================================================================================================================
+func bluechain_record(supplyItemID string, operatorID string, longitude string) []byte {
+	return []byte(`{"supplierID":"` + TEST_SUPPLIER + `","operatorID":"` + operatorID + `","longitude":"` + longitude +
+		`","latitude":"51.5072","description":"Legacy item ` + supplyItemID + `","materialType":"steel","materialQuantity":"",` +
+		`"unitOfMeasure":"kg","photo":"","supplyItemID":"` + supplyItemID + `"}`)
+}
+
+//==============================================================================================================================
+//	 bluechainlatest_record - A record as the bluechainlatest save_changes wrote it: a bluechain record with an owner.
+//==============================================================================================================================
+func bluechainlatest_record(supplyItemID string, operatorID string, ownerID string) []byte {
+	record := bluechain_record(supplyItemID, operatorID, "-0.1276")
+	return append(record[:len(record)-1], []byte(`,"ownerID":"` + ownerID + `"}`)...)
+}
+
+//==============================================================================================================================
+//	 unversioned_record - A record as this chaincode wrote it before SchemaVersion was added.
+//==============================================================================================================================
+func unversioned_record(t *testing.T, supplyItemID string) []byte {
+	var fields map[string]interface{}
+	if err := json.Unmarshal([]byte(a_supplyItem(supplyItemID).with("status", STATUS_CREATED).with("revision", 1).json()), &fields); err != nil { t.Fatal(err) }
+	bytes, _ := json.Marshal(fields)
+	return bytes
+}
+
+func schema_versions(t *testing.T, l *Ledger) SchemaVersionCounts {
+	bytes, err := l.query(TEST_ADMIN, "get_schema_versions")
+	if err != nil { t.Fatalf("get_schema_versions: %s", err) }
+
+	var counts SchemaVersionCounts
+	if err := json.Unmarshal(bytes, &counts); err != nil { t.Fatalf("not SchemaVersionCounts: %s: %s", err, bytes) }
+	return counts
+}
+
+func TestRecordSchemaVersion(t *testing.T) {
+
+	tests := []struct {
+		name   string
+		record string
+		want   int
+	}{
+		{"bluechain record",       string(bluechain_record("A1", "", "-0.1276")),                 SCHEMA_BLUECHAIN},
+		{"bluechainlatest record", string(bluechainlatest_record("A1", TEST_OPERATOR, TEST_OWNER)), SCHEMA_BLUECHAIN},
+		{"unversioned record",     string(unversioned_record(t, "A1")),                            SCHEMA_UNVERSIONED},
+		{"versioned record",       `{"revision":4,"schemaVersion":3}`,                             CURRENT_SCHEMA_VERSION},
+		{"corrupt record",         `{"supplyItemID":`,                                             0},
+		{"corrupt version",        `{"schemaVersion":"3"}`,                                        0},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			version, err := record_schema_version([]byte(tc.record))
+			if tc.want == 0 {
+				check_error(t, err, `"code":"CORRUPT_RECORD"`)
+				return
+			}
+			if err != nil { t.Fatal(err) }
+			if version != tc.want { t.Errorf("version = %d, want %d", version, tc.want) }
+		})
+	}
+}
+
+func TestMigrateRecords(t *testing.T) {
+
+	l := new_ledger(t)
+	l.create(a_supplyItem("A0"))
+	l.put_raw("A1", bluechain_record("A1", TEST_OPERATOR, "-0.1276"))
+	l.put_raw("A2", bluechain_record("A2", "", ""))
+	l.put_raw("A3", bluechain_record("A3", "", "west"))
+	l.put_raw("A4", unversioned_record(t, "A4"))
+	l.put_raw("A5", bluechainlatest_record("A5", TEST_OPERATOR, TEST_OWNER))
+
+	if _, err := l.query(TEST_OWNER, "get_supplyItem", "A5"); err != nil { t.Errorf("owner of a bluechainlatest record: get_supplyItem: %s", err) }
+
+	counts := schema_versions(t, l)
+	if counts.Versions["1"] != 4 || counts.Versions["2"] != 1 || counts.Versions["3"] != 1 { t.Fatalf("versions before = %v", counts.Versions) }
+
+	migrated, current, failed := 0, 0, []MigrationFailure{}
+	cursor := ""
+	for calls := 0; calls == 0 || cursor != ""; calls++ {
+		if calls > 5 { t.Fatal("migrate_records did not finish") }
+
+		bytes, err := l.invoke(TEST_ADMIN, "migrate_records", "operator", "2", cursor)
+		if err != nil { t.Fatalf("migrate_records: %s", err) }
+
+		var result MigrationResult
+		if err := json.Unmarshal(bytes, &result); err != nil { t.Fatalf("not a MigrationResult: %s: %s", err, bytes) }
+		migrated, current, failed = migrated+result.Migrated, current+result.Current, append(failed, result.Failed...)
+		cursor = result.NextCursor
+	}
+
+	if migrated != 4 || current != 1 { t.Errorf("migrated %d and found %d current, want 4 and 1", migrated, current) }
+	if len(failed) != 1 || failed[0].SupplyItemID != "A3" || !failed[0].Error.Has("longitude") { t.Errorf("failed = %+v, want A3 longitude", failed) }
+
+	counts = schema_versions(t, l)
+	if counts.Versions["1"] != 1 || counts.Versions["3"] != 5 { t.Errorf("versions after = %v", counts.Versions) }
+
+	for id, owner := range map[string]string{"A1": TEST_OPERATOR, "A2": TEST_SUPPLIER, "A4": TEST_OWNER, "A5": TEST_OWNER} {
+		bytes, err := l.query(TEST_AUDITOR, "get_supplyItem", id)
+		if err != nil { t.Errorf("get_supplyItem %s: %s", id, err); continue }
+
+		sItem := decode_supplyItem(t, bytes)
+		if sItem.SchemaVersion != CURRENT_SCHEMA_VERSION || sItem.UnitOfMeasure != UOM_KILOGRAM || sItem.Status != STATUS_CREATED || sItem.OwnerID != owner {
+			t.Errorf("%s = %+v, want an upgraded record owned by %s", id, sItem, owner)
+		}
+		if unrecorded := id != "A4"; sItem.QuantityUnrecorded != unrecorded || sItem.MaterialQty != 0 && unrecorded {
+			t.Errorf("%s quantity = %s, unrecorded %t", id, sItem.MaterialQty, sItem.QuantityUnrecorded)
+		}
+	}
+
+	// A4 was put without the index entries this chaincode wrote with it, and keeps its owner, so is not reindexed
+	if ids := strings.Join(supplyItem_ids(t, l, "query_supplyItems", `{"ownerID":"`+TEST_OWNER+`"}`), ","); ids != "A0,A5" { t.Errorf("owner index = %s, want A0 and A5", ids) }
+}
+
+func TestMigratedQuantity(t *testing.T) {
+
+	l := new_ledger(t)
+	l.create(a_supplyItem("A0"))
+	l.put_raw("A1", bluechainlatest_record("A1", TEST_OPERATOR, TEST_OWNER))
+
+	if _, err := l.invoke(TEST_ADMIN, "migrate_records", "supplier"); err != nil { t.Fatalf("migrate_records: %s", err) }
+
+	tests := []struct {
+		name string
+		id   string
+		args string
+		want string
+	}{
+		{"negative",       "A1", `{"materialQuantity":"-1"}`, `"field":"materialQuantity"`},
+		{"not a quantity", "A1", `{"materialQuantity":"a"}`,  `"field":"materialQuantity"`},
+		{"recorded",       "A0", `{"materialQuantity":"10"}`, `"field":"materialQuantity"`},
+		{"given",          "A1", `{"materialQuantity":"10"}`, ""},
+		{"given again",    "A1", `{"materialQuantity":"20"}`, `"field":"materialQuantity"`},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := l.invoke(TEST_OWNER, "update_supplyItem", tc.id, tc.args)
+			check_error(t, err, tc.want)
+		})
+	}
+
+	sItem := get_supplyItem(t, l, "A1")
+	if sItem.QuantityUnrecorded || sItem.MaterialQty.String() != "10" || sItem.NormalizedQty.String() != "10" { t.Errorf("A1 = %+v, want 10 KGM recorded", sItem) }
+}
+
+func TestMigrateRecordsOwnerRule(t *testing.T) {
+
+	tests := []struct {
+		name  string
+		rule  string
+		owner string
+		want  string
+	}{
+		{"fixed participant",   "participant:" + TEST_OWNER, TEST_OWNER,    ""},
+		{"supplier",            "supplier",                  TEST_SUPPLIER, ""},
+		{"unknown rule",        "nobody",                    "",            `"field":"ownerRule"`},
+		{"participant missing", "participant:",              "",            `"field":"ownerRule"`},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			l := new_ledger(t)
+			l.put_raw("A1", bluechain_record("A1", TEST_OPERATOR, "-0.1276"))
+
+			_, err := l.invoke(TEST_ADMIN, "migrate_records", tc.rule)
+			check_error(t, err, tc.want)
+			if tc.want != "" { return }
+
+			bytes, err := l.query(TEST_AUDITOR, "get_supplyItem", "A1")
+			if err != nil { t.Fatal(err) }
+			if sItem := decode_supplyItem(t, bytes); sItem.OwnerID != tc.owner { t.Errorf("owner = %q, want %q", sItem.OwnerID, tc.owner) }
+		})
+	}
+}
+
+func TestMigrateRecordsRefused(t *testing.T) {
+
+	l := new_ledger(t)
+
+	_, err := l.invoke(TEST_OWNER, "migrate_records", "supplier")
+	check_error(t, err, `"code":"PERMISSION_DENIED"`)
+
+	l.state[LEGACY_HOLDER_KEY] = []byte(`{"supplyItemIDs":["A1"]}`)
+
+	_, err = l.invoke(TEST_ADMIN, "migrate_records", "supplier")
+	check_error(t, err, `"code":"CONFLICT"`)
+
+	if counts := schema_versions(t, l); counts.Unindexed != 1 { t.Errorf("unindexed = %d, want 1", counts.Unindexed) }
+}
+
+func TestMigrateRecordsInlinePhoto(t *testing.T) {
+
+	l := new_ledger(t)
+
+	for id, size := range map[string]int{"SMALL": 100, "LARGE": DEFAULT_MAX_INLINE_BYTES + 1} {
+		var fields map[string]interface{}
+		if err := json.Unmarshal(bluechain_record(id, "", "-0.1276"), &fields); err != nil { t.Fatal(err) }
+		fields["photo"] = "data:image/jpeg;base64," + base64.StdEncoding.EncodeToString(make([]byte, size))
+		bytes, _ := json.Marshal(fields)
+		l.put_raw(id, bytes)
+	}
+
+	bytes, err := l.invoke(TEST_ADMIN, "migrate_records", "supplier")
+	if err != nil { t.Fatalf("migrate_records: %s", err) }
+
+	var result MigrationResult
+	if err := json.Unmarshal(bytes, &result); err != nil { t.Fatalf("not a MigrationResult: %s: %s", err, bytes) }
+	if result.Migrated != 1 || len(result.Failed) != 1 || result.Failed[0].SupplyItemID != "LARGE" || !result.Failed[0].Error.Has("photo.data") {
+		t.Fatalf("result %s", bytes)
+	}
+
+	bytes, err = l.query(TEST_SUPPLIER, "get_supplyItem", "SMALL")
+	if err != nil { t.Fatal(err) }
+	if photo := decode_supplyItem(t, bytes).Photo; photo == nil || photo.URI != "" || photo.Size != 100 || photo.MediaType != "image/jpeg" || photo.SHA256 != sha256_hex(make([]byte, 100)) {
+		t.Errorf("migrated photo %+v", photo)
+	}
+}
@@ -0,0 +1,57 @@
+/*
+Copyright IBM Corp 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+
+//==============================================================================================================================
+//	Package shimstub adapts the Fabric shim to supplychain.Stub, so a main package can serve the supplychain Chaincode.
+//==============================================================================================================================
+package shimstub
+
+import (
+	"time"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/vsagineedu/learn-chaincode/supplychain"
+)
+
+type stub struct {
+	shim.ChaincodeStubInterface
+}
+
+//==============================================================================================================================
+//	 New - Returns the supplychain.Stub of the transaction served by a shim stub.
+//==============================================================================================================================
+func New(s shim.ChaincodeStubInterface) supplychain.Stub {
+	return stub{s}
+}
+
+func (s stub) RangeQueryState(startKey string, endKey string) (supplychain.StateIterator, error) {
+	return s.ChaincodeStubInterface.RangeQueryState(startKey, endKey)
+}
+
+//==============================================================================================================================
+//	 GetTxTime - Returns the transaction timestamp. A shim stub that does not supply one (such as the MockStub) gives the
+//				 Unix epoch.
+//==============================================================================================================================
+func (s stub) GetTxTime() (time.Time, error) {
+
+	ts, err := s.GetTxTimestamp()
+	if err != nil { return time.Time{}, err }
+
+	if ts == nil { return time.Unix(0, 0).UTC(), nil }
+
+	return time.Unix(ts.Seconds, int64(ts.Nanos)).UTC(), nil
+}
@@ -0,0 +1,144 @@
+/*
+Copyright IBM Corp 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+
+//==============================================================================================================================
+//	Package shimtest runs a main package's chaincode on a shim MockStub, so its tests can check that calls reach the
+//	supplychain package through the shim.
+//==============================================================================================================================
+package shimtest
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/vsagineedu/learn-chaincode/supplychain"
+)
+
+const STEP_INVOKE = "invoke"
+const STEP_QUERY = "query"
+const STEP_CORRUPT = "corrupt"
+
+//==============================================================================================================================
+//	CallerStub - A MockStub whose transaction certificate carries a username attribute.
+//==============================================================================================================================
+type CallerStub struct {
+	*shim.MockStub
+	User string
+}
+
+func (s *CallerStub) ReadCertAttribute(name string) ([]byte, error) {
+	if name == supplychain.USERNAME_ATTRIBUTE { return []byte(s.User), nil }
+	return nil, nil
+}
+
+//==============================================================================================================================
+//	Ledger - A chaincode and the MockStub holding its state. Admin is the participant Init registered.
+//==============================================================================================================================
+type Ledger struct {
+	Chaincode shim.Chaincode
+	Stub      *shim.MockStub
+	Admin     string
+}
+
+//==============================================================================================================================
+//	 NewLedger - Initialises cc on a new MockStub with admin as its first ROLE_ADMIN participant.
+//==============================================================================================================================
+func NewLedger(name string, cc shim.Chaincode, admin string) (*Ledger, error) {
+
+	l := &Ledger{Chaincode: cc, Stub: shim.NewMockStub(name, cc), Admin: admin}
+
+	participant, err := json.Marshal(supplychain.Participant{ParticipantID: admin, Organization: "org1", Roles: []string{supplychain.ROLE_ADMIN}})
+	if err != nil { return nil, err }
+
+	_, err = l.Stub.MockInit("init", "init", []string{string(participant)})
+	if err != nil { return nil, err }
+
+	return l, nil
+}
+
+func (l *Ledger) Invoke(user string, function string, args ...string) ([]byte, error) {
+	l.Stub.MockTransactionStart(function)
+	defer l.Stub.MockTransactionEnd(function)
+	return l.Chaincode.Invoke(&CallerStub{l.Stub, user}, function, args)
+}
+
+func (l *Ledger) Query(user string, function string, args ...string) ([]byte, error) {
+	return l.Chaincode.Query(&CallerStub{l.Stub, user}, function, args)
+}
+
+//==============================================================================================================================
+//	 Register - Registers each participant as the admin.
+//==============================================================================================================================
+func (l *Ledger) Register(participants ...supplychain.Participant) error {
+
+	for _, p := range participants {
+		participant, err := json.Marshal(p)
+		if err != nil { return err }
+
+		_, err = l.Invoke(l.Admin, "register_participant", string(participant))
+		if err != nil { return fmt.Errorf("register_participant %s: %s", p.ParticipantID, err) }
+	}
+	return nil
+}
+
+//==============================================================================================================================
+//	 PutRecord - Stores bytes as the record of a SupplyItem without calling the chaincode.
+//==============================================================================================================================
+func (l *Ledger) PutRecord(supplyItemID string, bytes []byte) error {
+
+	key := supplychain.COMPOSITE_KEY_NAMESPACE + supplychain.SUPPLYITEM_KEY_TYPE + supplychain.COMPOSITE_KEY_SEPARATOR + supplyItemID + supplychain.COMPOSITE_KEY_SEPARATOR
+
+	l.Stub.MockTransactionStart("put_record")
+	defer l.Stub.MockTransactionEnd("put_record")
+	return l.Stub.PutState(key, bytes)
+}
+
+//==============================================================================================================================
+//	 Step - Runs one step of a test table: a call to invoke or query as user, or for STEP_CORRUPT, the record args[1]
+//			stored for SupplyItem args[0].
+//==============================================================================================================================
+func (l *Ledger) Step(kind string, user string, function string, args ...string) ([]byte, error) {
+
+	switch kind {
+	case STEP_INVOKE:
+		return l.Invoke(user, function, args...)
+	case STEP_QUERY:
+		return l.Query(user, function, args...)
+	case STEP_CORRUPT:
+		if len(args) != 2 { return nil, errors.New("A corrupt step takes a supplyItemID and a record") }
+		return nil, l.PutRecord(args[0], []byte(args[1]))
+	}
+	return nil, errors.New("Unknown step kind " + kind)
+}
+
+//==============================================================================================================================
+//	 Check - Returns an error describing how the result of a step differs from what was expected: failure with the error
+//			 code given, or if code is "", success with a result containing want.
+//==============================================================================================================================
+func Check(bytes []byte, err error, code string, want string) error {
+
+	if code != "" {
+		if err == nil || !strings.Contains(err.Error(), `"code":"`+code+`"`) { return fmt.Errorf("error = %v, want %s", err, code) }
+		return nil
+	}
+	if err != nil { return err }
+	if !strings.Contains(string(bytes), want) { return fmt.Errorf("result = %s, want %s", bytes, want) }
+	return nil
+}
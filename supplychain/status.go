@@ -0,0 +1,210 @@
+/*
+Copyright IBM Corp 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package supplychain
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/vsagineedu/learn-chaincode/ccerror"
+)
+
+//==============================================================================================================================
+//	SupplyItemStatus - Where a SupplyItem is in its lifecycle.
+//==============================================================================================================================
+type SupplyItemStatus string
+
+const (
+	STATUS_CREATED    SupplyItemStatus = "Created"
+	STATUS_IN_TRANSIT SupplyItemStatus = "InTransit"
+	STATUS_RECEIVED   SupplyItemStatus = "Received"
+	STATUS_IN_STORAGE SupplyItemStatus = "InStorage"
+	STATUS_CONSUMED   SupplyItemStatus = "Consumed"
+	STATUS_RECALLED   SupplyItemStatus = "Recalled"
+	STATUS_DESTROYED  SupplyItemStatus = "Destroyed"
+)
+
+var supplyItem_statuses = []SupplyItemStatus{STATUS_CREATED, STATUS_IN_TRANSIT, STATUS_RECEIVED, STATUS_IN_STORAGE,
+	STATUS_CONSUMED, STATUS_RECALLED, STATUS_DESTROYED}
+
+//==============================================================================================================================
+//	status_transitions - The status changes that update_status may make from each status. Destroyed is final.
+//==============================================================================================================================
+var status_transitions = map[SupplyItemStatus][]SupplyItemStatus{
+	STATUS_CREATED:    {STATUS_IN_TRANSIT, STATUS_IN_STORAGE, STATUS_CONSUMED, STATUS_RECALLED, STATUS_DESTROYED},
+	STATUS_IN_TRANSIT: {STATUS_RECEIVED, STATUS_RECALLED, STATUS_DESTROYED},
+	STATUS_RECEIVED:   {STATUS_IN_TRANSIT, STATUS_IN_STORAGE, STATUS_CONSUMED, STATUS_RECALLED, STATUS_DESTROYED},
+	STATUS_IN_STORAGE: {STATUS_IN_TRANSIT, STATUS_CONSUMED, STATUS_RECALLED, STATUS_DESTROYED},
+	STATUS_CONSUMED:   {STATUS_RECALLED},
+	STATUS_RECALLED:   {STATUS_DESTROYED},
+	STATUS_DESTROYED:  {},
+}
+
+//==============================================================================================================================
+//	terminal_statuses - Statuses a SupplyItem is not used from again. Aggregates leave them out unless asked.
+//==============================================================================================================================
+var terminal_statuses = []SupplyItemStatus{STATUS_RECALLED, STATUS_DESTROYED}
+
+//==============================================================================================================================
+//	action_statuses - The statuses in which each invoke function that leaves the status unchanged may act on a SupplyItem.
+//==============================================================================================================================
+var action_statuses = map[string][]SupplyItemStatus{
+	"update_supplyItem":   {STATUS_CREATED, STATUS_IN_TRANSIT, STATUS_RECEIVED, STATUS_IN_STORAGE, STATUS_RECALLED},
+	"update_location":     {STATUS_CREATED, STATUS_IN_TRANSIT, STATUS_RECEIVED, STATUS_IN_STORAGE, STATUS_RECALLED},
+	"propose_transfer":    {STATUS_CREATED, STATUS_IN_TRANSIT, STATUS_RECEIVED, STATUS_IN_STORAGE},
+	"accept_transfer":     {STATUS_CREATED, STATUS_IN_TRANSIT, STATUS_RECEIVED, STATUS_IN_STORAGE},
+	"reject_transfer":     {STATUS_CREATED, STATUS_IN_TRANSIT, STATUS_RECEIVED, STATUS_IN_STORAGE, STATUS_RECALLED},
+	"cancel_transfer":     {STATUS_CREATED, STATUS_IN_TRANSIT, STATUS_RECEIVED, STATUS_IN_STORAGE, STATUS_RECALLED},
+	"assemble_supplyItem": {STATUS_CREATED, STATUS_RECEIVED, STATUS_IN_STORAGE},
+}
+
+//==============================================================================================================================
+//	 current_status - Records written before Status existed have no status and are treated as Created.
+//==============================================================================================================================
+func current_status(sItem SupplyItem) SupplyItemStatus {
+	if sItem.Status == "" {
+		return STATUS_CREATED
+	}
+	return sItem.Status
+}
+
+//==============================================================================================================================
+//	 check_transition - Returns an error unless action may move sItem from its current status to requested. Actions that
+//						do not change the status pass the current status as requested. No action but issue_recall may change
+//						an archived SupplyItem, so that a recall also reaches stock that has been archived.
+//==============================================================================================================================
+func check_transition(sItem SupplyItem, action string, requested SupplyItemStatus) error {
+
+	if is_archived(sItem) && action != "issue_recall" { return ccerror.New(ccerror.CONFLICT, "SupplyItem " + sItem.SupplyItemID + " is archived. It must be restored before " + action) }
+
+	current := current_status(sItem)
+
+	if requested == current && action != "update_status" {
+		if contains_status(action_statuses[action], current) { return nil }
+	} else if contains_status(status_transitions[current], requested) {
+		return nil
+	}
+
+	return ccerror.New(ccerror.CONFLICT, "Illegal transition: " + action + " cannot move SupplyItem " + sItem.SupplyItemID + " from " + string(current) + " to " + string(requested))
+}
+
+func contains_status(list []SupplyItemStatus, status SupplyItemStatus) bool {
+	for _, s := range list {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
+
+func is_supplyItem_status(status SupplyItemStatus) bool {
+	return contains_status(supplyItem_statuses, status)
+}
+
+//=================================================================================================================================
+//	 update_status - Moves a SupplyItem to a new status following status_transitions. May be called by the owner or the
+//					 operator.
+//=================================================================================================================================
+func (t *Chaincode) update_status(stub Stub, caller string, args []string) ([]byte, error) {
+
+	//Args
+	//		0			1
+	//	supplyItemID	status
+
+	if len(args) != 2 { return nil, ccerror.New(ccerror.INVALID_ARGUMENT, "UPDATE_STATUS: Incorrect number of arguments. Expecting supplyItemID and status") }
+
+	requested := SupplyItemStatus(args[1])
+
+	if !is_supplyItem_status(requested) { return nil, ccerror.New(ccerror.INVALID_ARGUMENT, "UPDATE_STATUS: Unknown status " + args[1]) }
+
+	sItem, err := t.retrieve_SupplyItem(stub, args[0])
+	if err != nil { fmt.Printf("UPDATE_STATUS: Error retrieving supplyItem: %s", err); return nil, err }
+
+	if sItem.OwnerID != caller && sItem.OperatorID != caller { return nil, permission_denied("update_status") }
+
+	err = check_transition(sItem, "update_status", requested)
+	if err != nil { return nil, err }
+
+	sItem.Status = requested
+
+	_, err = t.save_changes(stub, sItem, caller, "update_status")
+	if err != nil { fmt.Printf("UPDATE_STATUS: Error saving changes: %s", err); return nil, err }
+
+	return nil, nil
+}
+
+//==============================================================================================================================
+//	AllowedTransitions - The response of get_allowed_transitions.
+//==============================================================================================================================
+type AllowedTransitions struct {
+	SupplyItemID string             `json:"supplyItemID"`
+	Status       SupplyItemStatus   `json:"status"`
+	Transitions  []SupplyItemStatus `json:"transitions"`
+	Actions      []string           `json:"actions"`
+}
+
+//=================================================================================================================================
+//	 get_allowed_transitions - Lists the statuses a SupplyItem can move to next and the invoke functions the caller may
+//							   call on it in its current status, so clients can disable actions that would be rejected.
+//							   Readers that neither hold nor are offered the SupplyItem get no actions. An archived
+//							   SupplyItem has no transitions and only restore_supplyItem, for its owner.
+//=================================================================================================================================
+func (t *Chaincode) get_allowed_transitions(stub Stub, caller string, supplyItemID string) ([]byte, error) {
+
+	sItem, err := t.retrieve_SupplyItem(stub, supplyItemID)
+	if err != nil { return nil, err }
+
+	if sItem.OwnerID != caller && sItem.OperatorID != caller && !t.reads_all(stub, caller) { return nil, permission_denied("get_allowed_transitions") }
+
+	current := current_status(sItem)
+
+	result := AllowedTransitions{
+		SupplyItemID: sItem.SupplyItemID,
+		Status:       current,
+		Transitions:  append([]SupplyItemStatus{}, status_transitions[current]...),
+		Actions:      []string{},
+	}
+
+	if is_archived(sItem) {
+		result.Transitions = []SupplyItemStatus{}
+		if sItem.OwnerID == caller { result.Actions = append(result.Actions, "restore_supplyItem") }
+		return json.Marshal(result)
+	}
+
+	recipient, err := t.is_transfer_recipient(stub, sItem.SupplyItemID, caller)
+	if err != nil { return nil, err }
+
+	holder := sItem.OwnerID == caller || sItem.OperatorID == caller
+	callers := map[string]bool{
+		"update_supplyItem":   holder,
+		"update_location":     holder,
+		"propose_transfer":    sItem.OwnerID == caller,
+		"accept_transfer":     recipient,
+		"assemble_supplyItem": sItem.OwnerID == caller,
+	}
+
+	for _, action := range []string{"update_supplyItem", "update_location", "propose_transfer", "accept_transfer", "assemble_supplyItem"} {
+		if callers[action] && check_transition(sItem, action, current) == nil {
+			result.Actions = append(result.Actions, action)
+		}
+	}
+	if holder && len(result.Transitions) > 0 {
+		result.Actions = append(result.Actions, "update_status")
+	}
+
+	return json.Marshal(result)
+}
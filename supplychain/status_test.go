@@ -0,0 +1,115 @@
+/*
+Copyright IBM Corp 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package supplychain
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestUpdateStatus(t *testing.T) {
+
+	tests := []struct {
+		name     string
+		caller   string
+		before   []SupplyItemStatus
+		archived bool
+		status   string
+		want     string
+	}{
+		{"owner ships",                TEST_OWNER,    nil,                                                   false, "InTransit", ""},
+		{"operator receives",          TEST_OPERATOR, []SupplyItemStatus{STATUS_IN_TRANSIT},                 false, "Received",  ""},
+		{"recalled is destroyed",      TEST_OWNER,    []SupplyItemStatus{STATUS_RECALLED},                   false, "Destroyed", ""},
+		{"in transit to storage",      TEST_OWNER,    []SupplyItemStatus{STATUS_IN_TRANSIT},                 false, "InStorage", "Illegal transition"},
+		{"destroyed is final",         TEST_OWNER,    []SupplyItemStatus{STATUS_RECALLED, STATUS_DESTROYED}, false, "Created",   "Illegal transition"},
+		{"same status",                TEST_OWNER,    nil,                                                   false, "Created",   "Illegal transition"},
+		{"unknown status",             TEST_OWNER,    nil,                                                   false, "Lost",      `"code":"INVALID_ARGUMENT"`},
+		{"neither owner nor operator", TEST_AUDITOR,  nil,                                                   false, "InTransit", `"code":"PERMISSION_DENIED"`},
+		{"archived",                   TEST_OWNER,    nil,                                                   true,  "InTransit", "is archived"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			l := new_ledger(t)
+			l.create(a_supplyItem("A1"))
+			for _, status := range tc.before {
+				if _, err := l.invoke(TEST_OWNER, "update_status", "A1", string(status)); err != nil { t.Fatal(err) }
+			}
+			if tc.archived {
+				if _, err := l.invoke(TEST_OWNER, "archive_supplyItem", "A1"); err != nil { t.Fatal(err) }
+			}
+
+			_, err := l.invoke(tc.caller, "update_status", "A1", tc.status)
+			check_error(t, err, tc.want)
+			if tc.want != "" { return }
+
+			if sItem := get_supplyItem(t, l, "A1"); string(sItem.Status) != tc.status { t.Errorf("status = %s, want %s", sItem.Status, tc.status) }
+
+			entries := history_entries(t, l, "A1")
+			last := entries[len(entries)-1]
+			if last.Action != "update_status" || last.Actor != tc.caller || len(last.Changes) != 1 || last.Changes[0].Field != "status" { t.Errorf("last history entry = %+v", last) }
+		})
+	}
+}
+
+func TestGetAllowedTransitions(t *testing.T) {
+
+	l := new_ledger(t)
+	l.register("owner2", ROLE_OWNER)
+	l.create(a_supplyItem("A1"))
+	l.create(a_supplyItem("A2"))
+	l.create(a_supplyItem("A3"))
+	if _, err := l.invoke(TEST_OWNER, "update_status", "A1", string(STATUS_RECALLED)); err != nil { t.Fatal(err) }
+	if _, err := l.invoke(TEST_OWNER, "propose_transfer", "A2", TRANSFER_OWNERSHIP, TEST_OPERATOR); err != nil { t.Fatal(err) }
+	if _, err := l.invoke(TEST_OWNER, "archive_supplyItem", "A3"); err != nil { t.Fatal(err) }
+
+	tests := []struct {
+		name        string
+		caller      string
+		id          string
+		transitions string
+		actions     string
+		want        string
+	}{
+		{"operator of a recalled item",  TEST_OPERATOR, "A1", "Destroyed",                                       "update_supplyItem,update_location,update_status",                                      ""},
+		{"owner",                        TEST_OWNER,    "A2", "InTransit,InStorage,Consumed,Recalled,Destroyed", "update_supplyItem,update_location,propose_transfer,assemble_supplyItem,update_status", ""},
+		{"operator offered ownership",   TEST_OPERATOR, "A2", "InTransit,InStorage,Consumed,Recalled,Destroyed", "update_supplyItem,update_location,accept_transfer,update_status",                      ""},
+		{"auditor",                      TEST_AUDITOR,  "A1", "Destroyed",                                       "",                                                                                     ""},
+		{"owner of an archived item",    TEST_OWNER,    "A3", "",                                                "restore_supplyItem",                                                                   ""},
+		{"operator of an archived item", TEST_OPERATOR, "A3", "",                                                "",                                                                                     ""},
+		{"neither holder nor reader",    "owner2",      "A1", "",                                                "",                                                                                     `"code":"PERMISSION_DENIED"`},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			bytes, err := l.query(tc.caller, "get_allowed_transitions", tc.id)
+			check_error(t, err, tc.want)
+			if tc.want != "" { return }
+
+			var allowed AllowedTransitions
+			if err := json.Unmarshal(bytes, &allowed); err != nil { t.Fatal(err) }
+
+			transitions := []string{}
+			for _, status := range allowed.Transitions {
+				transitions = append(transitions, string(status))
+			}
+			if got := strings.Join(transitions, ","); got != tc.transitions { t.Errorf("transitions = %s, want %s", got, tc.transitions) }
+			if got := strings.Join(allowed.Actions, ","); got != tc.actions { t.Errorf("actions = %s, want %s", got, tc.actions) }
+		})
+	}
+}
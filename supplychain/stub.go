@@ -0,0 +1,52 @@
+/*
+Copyright IBM Corp 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+
+package supplychain
+
+import (
+	"time"
+)
+
+//==============================================================================================================================
+//	StateStore - The key-value ledger state the chaincode reads and writes. RangeQueryState returns the keys from
+//				 startKey to endKey inclusive, in key order.
+//==============================================================================================================================
+type StateStore interface {
+	GetState(key string) ([]byte, error)
+	PutState(key string, value []byte) error
+	DelState(key string) error
+	RangeQueryState(startKey string, endKey string) (StateIterator, error)
+}
+
+type StateIterator interface {
+	HasNext() bool
+	Next() (string, []byte, error)
+	Close() error
+}
+
+//==============================================================================================================================
+//	Stub - The StateStore of one transaction, with what the chaincode needs to know about the transaction itself: its ID
+//		   and timestamp, the caller's certificate attributes and metadata, and where to send its event.
+//==============================================================================================================================
+type Stub interface {
+	StateStore
+	GetTxID() string
+	GetTxTime() (time.Time, error)
+	ReadCertAttribute(name string) ([]byte, error)
+	GetCallerMetadata() ([]byte, error)
+	SetEvent(name string, payload []byte) error
+}
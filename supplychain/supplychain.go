@@ -0,0 +1,556 @@
+/*
+Copyright IBM Corp 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+//==============================================================================================================================
+//	Package supplychain is the SupplyItem chaincode: its domain model, validation and business rules, and the router
+//	that dispatches Init, Invoke and Query to them. It reads and writes the ledger only through the Stub interface, so
+//	it can be run and tested without a peer; a main package deploys it by adapting shim.ChaincodeStubInterface to Stub.
+//==============================================================================================================================
+package supplychain
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/vsagineedu/learn-chaincode/ccerror"
+	"encoding/json"
+)
+
+
+// Chaincode - The SupplyItem chaincode. A deployment should use one Chaincode for every transaction.
+type Chaincode struct {
+	eventsLock sync.Mutex
+	events     map[string][]SupplyItemEvent		// Events of each transaction in progress, by txID
+}
+
+////==============================================================================================================================
+//	SupplyItem - Defines the structure for a SupplyItem object. JSON on right tells it what JSON fields to map to
+//			  that element when reading a JSON object into the struct e.g. JSON make -> Struct Make.
+//=============================================================================================================================
+type SupplyItem struct {
+	SupplierID    string        `json:"supplierID"`
+	OperatorID    string        `json:"operatorID"`
+	Longitude     float64       `json:"longitude"`
+	Latitude      float64       `json:"latitude"`
+	Description   string        `json:"description"`
+	MaterialType  string        `json:"materialType"`
+	MaterialQty   Quantity      `json:"materialQuantity"`
+	QuantityUnrecorded bool     `json:"quantityUnrecorded,omitempty"`		// Migrated from bluechain, which stored no quantity, and not yet given one
+	UnitOfMeasure UnitOfMeasure `json:"unitOfMeasure"`
+	NormalizedQty Quantity      `json:"normalizedQuantity"`
+	BaseUnit      UnitOfMeasure `json:"baseUnit"`
+	Photo         *Attachment   `json:"photo,omitempty"`
+	Attachments   []Attachment  `json:"attachments,omitempty"`
+	SupplyItemID  string        `json:"supplyItemID"`
+	OwnerID       string           `json:"ownerID"`
+	Status        SupplyItemStatus `json:"status"`
+	ParentIDs     []string         `json:"parentIDs,omitempty"`
+	ChildIDs      []string         `json:"childIDs,omitempty"`
+	Components    []Component      `json:"components,omitempty"`
+	UsedInIDs     []string         `json:"usedInIDs,omitempty"`
+	ArchivedAt    string           `json:"archivedAt,omitempty"`
+	Revision      int              `json:"revision"`
+	SchemaVersion int              `json:"schemaVersion"`
+}
+
+//==============================================================================================================================
+//	UnitOfMeasure - The units a MaterialQty may be expressed in. Values are UN/CEFACT Recommendation 20 codes of units
+//					in the unit registry; these are the default units.
+//==============================================================================================================================
+type UnitOfMeasure string
+
+const (
+	UOM_KILOGRAM    UnitOfMeasure = "KGM"
+	UOM_GRAM        UnitOfMeasure = "GRM"
+	UOM_TONNE       UnitOfMeasure = "TNE"
+	UOM_LITRE       UnitOfMeasure = "LTR"
+	UOM_CUBIC_METRE UnitOfMeasure = "MTQ"
+	UOM_METRE       UnitOfMeasure = "MTR"
+	UOM_PIECE       UnitOfMeasure = "H87"
+)
+
+//==============================================================================================================================
+//	SupplyItems Holder - Defines the structure that held all the SupplyItemIDs for SupplyItems that had been created.
+//				Ledgers written by earlier versions of this chaincode still hold one under "supplyItemIDs" until
+//				migrate_supplyItem_index is run. Each SupplyItem is now stored under its own SUPPLYITEM_KEY_TYPE key.
+//==============================================================================================================================
+
+type SupplyItemIDs_Holder struct {
+	SupplyItemIDs 	[]string `json:"supplyitemids"`
+}
+
+const SUPPLYITEM_KEY_TYPE = "supplyItem"
+
+func supplyItem_key(supplyItemID string) (string, error) {
+	return create_composite_key(SUPPLYITEM_KEY_TYPE, []string{supplyItemID})
+}
+
+//==============================================================================================================================
+//	Init Function - Called when the user deploys the chaincode
+//==============================================================================================================================
+func (t *Chaincode) Init(stub Stub, function string, args []string) (result []byte, err error) {
+
+	defer func() { err = ccerror.Wrap("init", err) }()
+
+	//Args
+	//				0
+	//			first admin participant JSON object
+
+  fmt.Println("invoke is running " + function)
+
+	if len(args) != 1 { return nil, ccerror.New(ccerror.INVALID_ARGUMENT, "INIT: Incorrect number of arguments. Expecting the first admin participant as a JSON object") }
+
+	err = t.seed_admin(stub, args[0])
+	if err != nil { fmt.Printf("INIT: Error seeding admin: %s", err); return nil, err }
+
+	return nil, nil
+}
+
+////=================================================================================================================================
+//	 check_unique_supplyItem - The history of a purged SupplyItem is kept, so its ID cannot be used again either.
+//=================================================================================================================================
+func (t *Chaincode) check_unique_supplyItem(stub Stub, supplyItemID string) ([]byte, error) {
+	key, err := supplyItem_key(supplyItemID)
+	if err != nil {
+		return []byte("false"), err
+	}
+	record, err := stub.GetState(key)
+	if err != nil {
+		return []byte("false"), errors.New("Unable to read supplyItem " + supplyItemID)
+	}
+	if record != nil {
+		return []byte("false"), ccerror.New(ccerror.ALREADY_EXISTS, "SupplyItem " + supplyItemID + " already exists")
+	}
+	key, err = history_key(supplyItemID, 1)
+	if err != nil {
+		return []byte("false"), err
+	}
+	record, err = stub.GetState(key)
+	if err != nil {
+		return []byte("false"), errors.New("Unable to read supplyItem history " + supplyItemID)
+	}
+	if record != nil {
+		return []byte("false"), ccerror.New(ccerror.ALREADY_EXISTS, "SupplyItem " + supplyItemID + " was purged and its ID cannot be used again")
+	}
+	return []byte("true"), nil
+}
+
+//==============================================================================================================================
+//	 retrieve_supplyItemID - Gets the state of the data at supplyItemID in the ledger then converts it from the stored
+//					JSON into the SupplyItem struct for use in the contract. Returns the SupplYItem struct.
+//					Returns empty SupplyItem if it errors.
+//==============================================================================================================================
+func (t *Chaincode) retrieve_SupplyItem(stub Stub, supplyItemID string) (SupplyItem, error) {
+
+	var sItem SupplyItem
+
+	key, err := supplyItem_key(supplyItemID)
+
+	if err != nil { return sItem, err }
+
+	bytes, err := stub.GetState(key);
+
+	if err != nil {	fmt.Printf("RETRIEVE_SupplyItem: Failed to invoke supplyitem_id: %s", err); return sItem, errors.New("RETRIEVE_SupplyItem: Error retrieving supplyitem with supplyItemID = " + supplyItemID) }
+
+	if bytes == nil { return sItem, ccerror.New(ccerror.NOT_FOUND, "RETRIEVE_SupplyItem: No supplyitem with supplyItemID = " + supplyItemID) }
+
+	sItem, err = decode_supplyItem_record(bytes)
+
+    if err != nil {	fmt.Printf("RETRIEVE_SupplyItem: Corrupt supplyItem record "+string(bytes)+": %s", err); return sItem, err	}
+
+	return sItem, nil
+}
+
+//==============================================================================================================================
+// save_changes - Writes to the ledger the SupplyItem struct passed in a JSON format. Uses the Stub's
+//				  method 'PutState'. The revision is incremented and a HistoryEntry recording who made the change,
+//				  the action and the fields changed is appended, so earlier states of the SupplyItem are never lost.
+//				  The coordinates are checked, the quantity is normalized, the secondary indexes, running totals and
+//				  location trail are updated to match and a SupplyItemEvent is emitted.
+//==============================================================================================================================
+func (t *Chaincode) save_changes(stub Stub, sItem SupplyItem, actor string, action string) (bool, error) {
+
+	var before *SupplyItem
+
+	key, err := supplyItem_key(sItem.SupplyItemID)
+
+	if err != nil { return false, err }
+
+	existing, err := stub.GetState(key)
+
+	if err != nil { fmt.Printf("SAVE_CHANGES: Error reading supplyitem record: %s", err); return false, errors.New("Error reading supplyitem record") }
+
+	if existing != nil {
+		current, err := decode_supplyItem_record(existing)
+		if err != nil { fmt.Printf("SAVE_CHANGES: Corrupt supplyitem record: %s", err); return false, err }
+		if current.Revision != sItem.Revision { return false, ccerror.New(ccerror.CONFLICT, "SupplyItem " + sItem.SupplyItemID + " was changed by another update") }
+		before = &current
+	}
+
+	if before != nil && before.SchemaVersion == SCHEMA_BLUECHAIN {
+		before = nil			// bluechain kept no indexes, counts or history, so the record is written as if new
+	}
+
+	if before == nil {
+		err = add_supplyItem_count(stub, sItem.SupplyItemID, 1)
+		if err != nil { fmt.Printf("SAVE_CHANGES: Error counting supplyitem record: %s", err); return false, errors.New("Error counting supplyitem record") }
+	}
+
+	verr := new_validation_error(action)
+	validate_coordinates(verr, sItem.Longitude, sItem.Latitude)
+	if err := verr.Result(); err != nil { return false, err }
+
+	if sItem.Photo.is_empty() {
+		sItem.Photo = nil			// Records written when Photo was a string may hold ""
+	}
+
+	err = t.normalize_supplyItem(stub, &sItem)
+	if err != nil { fmt.Printf("SAVE_CHANGES: Error normalizing quantity: %s", err); return false, err }
+
+	sItem.Revision++
+	sItem.SchemaVersion = CURRENT_SCHEMA_VERSION
+
+	bytes, err := json.Marshal(sItem)
+
+	if err != nil { fmt.Printf("SAVE_CHANGES: Error converting supplyitem record: %s", err); return false, errors.New("Error converting supply item record") }
+
+	err = stub.PutState(key, bytes)
+
+	if err != nil { fmt.Printf("SAVE_CHANGES: Error storing supplyitem record: %s", err); return false, errors.New("Error storing supplyitem record") }
+
+	err = update_indexes(stub, before, sItem)
+
+	if err != nil { fmt.Printf("SAVE_CHANGES: Error updating indexes: %s", err); return false, err }
+
+	changes, err := t.append_history(stub, before, sItem, actor, action)
+
+	if err != nil { fmt.Printf("SAVE_CHANGES: Error recording history: %s", err); return false, err }
+
+	if before == nil && action == "create_supplyItem" {
+		txTime, err := get_tx_time(stub)
+		if err != nil { return false, err }
+
+		err = t.add_supply_volume(stub, sItem, txTime)
+		if err != nil { fmt.Printf("SAVE_CHANGES: Error recording supply volume: %s", err); return false, err }
+	}
+
+	err = append_location(stub, before, sItem, actor)
+
+	if err != nil { fmt.Printf("SAVE_CHANGES: Error recording location: %s", err); return false, err }
+
+	err = t.emit_event(stub, SupplyItemEvent{SupplyItemID: sItem.SupplyItemID, Action: action, Actor: actor, Revision: sItem.Revision, ChangedFields: changed_field_names(changes)})
+
+	if err != nil { return false, err }
+
+	return true, nil
+}
+
+//==============================================================================================================================
+//	 Router Functions
+//==============================================================================================================================
+//	Invoke - Called on chaincode invoke. Takes a function name passed and calls that function. The caller is read from
+//		  the transaction certificate and passed to the called function with the arguments. Any error is returned as
+//		  a ccerror.Error of the function.
+//==============================================================================================================================
+func (t *Chaincode) Invoke(stub Stub, function string, args []string) (result []byte, err error) {
+
+	defer func() { err = ccerror.Wrap(function, err) }()
+
+	caller, err := t.get_caller(stub)
+	if err != nil { return nil, err }
+
+	defer t.clear_events(stub.GetTxID())
+
+	spec, ok := lookup_function(function, FUNCTION_INVOKE)
+	if !ok { return nil, ccerror.New(ccerror.INVALID_ARGUMENT, "Function of the name "+ function +" doesn't exist.") }
+
+	err = t.authorize(stub, caller, function)
+	if err != nil { return nil, err }
+
+	err = spec.check_args(args)
+	if err != nil { return nil, err }
+
+	if function == "create_supplyItem" {
+        return t.create_supplyItem(stub, caller, args)
+	} else if function == "update_supplyItem" {
+		return t.update_supplyItem(stub, caller, args)
+	} else if function == "propose_transfer" {
+		return t.propose_transfer(stub, caller, args)
+	} else if function == "accept_transfer" {
+		return t.accept_transfer(stub, caller, args)
+	} else if function == "reject_transfer" {
+		return t.reject_transfer(stub, caller, args)
+	} else if function == "cancel_transfer" {
+		return t.cancel_transfer(stub, caller, args)
+	} else if function == "update_status" {
+		return t.update_status(stub, caller, args)
+	} else if function == "split_supplyItem" {
+		return t.split_supplyItem(stub, caller, args)
+	} else if function == "merge_supplyItems" {
+		return t.merge_supplyItems(stub, caller, args)
+	} else if function == "assemble_supplyItem" {
+		return t.assemble_supplyItem(stub, caller, args)
+	} else if function == "update_location" {
+		return t.update_location(stub, caller, args)
+	} else if function == "issue_recall" {
+		return t.issue_recall(stub, caller, args)
+	} else if function == "register_participant" {
+		return t.register_participant(stub, caller, args)
+	} else if function == "update_participant" {
+		return t.update_participant(stub, caller, args)
+	} else if function == "suspend_participant" {
+		return t.suspend_participant(stub, caller, args)
+	} else if function == "update_policy" {
+		return t.update_policy(stub, caller, args)
+	} else if function == "update_attachment_config" {
+		return t.update_attachment_config(stub, caller, args)
+	} else if function == "register_unit" {
+		return t.register_unit(stub, caller, args)
+	} else if function == "migrate_supplyItem_index" {
+		return t.migrate_supplyItem_index(stub, args)
+	} else if function == "reindex_supplyItems" {
+		return t.reindex_supplyItems(stub, args)
+	} else if function == "migrate_records" {
+		return t.migrate_records(stub, caller, args)
+	} else if function == "archive_supplyItem" {
+		return t.archive_supplyItem(stub, caller, args)
+	} else if function == "restore_supplyItem" {
+		return t.restore_supplyItem(stub, caller, args)
+	} else if function == "purge_archived" {
+		return t.purge_archived(stub, caller, args)
+	} else if function == "update_retention_config" {
+		return t.update_retention_config(stub, caller, args)
+	}
+		return nil, ccerror.New(ccerror.INVALID_ARGUMENT, "Function of the name "+ function +" doesn't exist.")
+
+	}
+
+//=================================================================================================================================
+//	 Create Function
+//=================================================================================================================================
+//	 Create SupplyItem - Builds and validates the SupplyItem from either a single JSON object or the positional
+//						 arguments, then saves it to the ledger. Invalid input returns an INVALID_ARGUMENT error listing every field.
+//=================================================================================================================================
+func (t *Chaincode) create_supplyItem(stub Stub, caller string, args []string) ([]byte, error) {
+
+	//Args
+	//		0
+	//	supplyItem JSON object, or the 11 positional values described in parse_supplyItem_args
+
+	var sItem SupplyItem
+	var err error
+
+	if len(args) == 1 {
+		sItem, err = parse_supplyItem_json("create_supplyItem", args[0])
+	} else if len(args) == 11 {
+		sItem, err = parse_supplyItem_args("create_supplyItem", args)
+	} else {
+		return nil, ccerror.New(ccerror.INVALID_ARGUMENT, "CREATE_SUPPLYITEM: Incorrect number of arguments. Expecting a JSON object or 11 values")
+	}
+
+	if err != nil { fmt.Printf("CREATE_SUPPLYITEM: Invalid supplyItem: %s", err); return nil, err }
+
+	verr := new_validation_error("create_supplyItem")
+	t.check_participants(stub, verr, sItem, []string{"supplierID", "operatorID", "ownerID"})
+	if err := t.resolve_unit(stub, verr, &sItem); err != nil { return nil, err }
+	if err := t.check_inline_size(stub, verr, sItem); err != nil { return nil, err }
+	if err := verr.Result(); err != nil { return nil, err }
+
+	_, err = t.check_unique_supplyItem(stub, sItem.SupplyItemID)		// If not an error then a record exists so cant create a new supplyitem with this SupplyItemID as it must be unique
+
+																		if err != nil { return nil, err }
+
+	sItem.Status = STATUS_CREATED
+
+	_, err  = t.save_changes(stub, sItem, caller, "create_supplyItem")
+
+																		if err != nil { fmt.Printf("CREATE_SUPPLYITEM: Error saving changes: %s", err); return nil, err }
+
+	return nil, nil
+
+}
+
+//=================================================================================================================================
+//	 update_supplyItem - Changes the descriptive fields of a SupplyItem (location, description, photo and attachments).
+//						 May be called by the owner or the operator. Ownership and operation are handed over with
+//						 propose_transfer. The quantity of a SupplyItem migrated with QuantityUnrecorded may be given
+//						 once.
+//=================================================================================================================================
+func (t *Chaincode) update_supplyItem(stub Stub, caller string, args []string) ([]byte, error) {
+
+	//Args
+	//		0				1
+	//	supplyItemID	JSON object of updatable_fields
+
+	if len(args) != 2 { return nil, ccerror.New(ccerror.INVALID_ARGUMENT, "UPDATE_SUPPLYITEM: Incorrect number of arguments. Expecting supplyItemID and a JSON object") }
+
+	sItem, err := t.retrieve_SupplyItem(stub, args[0])
+	if err != nil { fmt.Printf("UPDATE_SUPPLYITEM: Error retrieving supplyItemID: %s", err); return nil, err }
+
+	if sItem.OwnerID != caller && sItem.OperatorID != caller { return nil, permission_denied("update_supplyItem") }
+
+	err = check_transition(sItem, "update_supplyItem", current_status(sItem))
+	if err != nil { return nil, err }
+
+	allowed := updatable_fields
+	if sItem.QuantityUnrecorded {
+		allowed = append([]string{"materialQuantity"}, updatable_fields...)
+	}
+
+	sItem, err = decode_supplyItem_fields("update_supplyItem", sItem, args[1], allowed)
+	if err != nil { return nil, err }
+
+	if sItem.QuantityUnrecorded && sItem.MaterialQty > 0 {
+		sItem.QuantityUnrecorded = false
+		err = t.normalize_supplyItem(stub, &sItem)
+		if err != nil { return nil, err }
+	}
+
+	verr := new_validation_error("update_supplyItem")
+	if err := t.check_inline_size(stub, verr, sItem); err != nil { return nil, err }
+	if err := verr.Result(); err != nil { return nil, err }
+
+	_, err = t.save_changes(stub, sItem, caller, "update_supplyItem")
+	if err != nil { fmt.Printf("UPDATE_SUPPLYITEM: Error saving changes: %s", err); return nil, err }
+	return nil, nil
+}
+
+
+//=================================================================================================================================
+//	 Read Functions
+//=================================================================================================================================
+//	 get_supplyItems - Returns one page of the SupplyItems the caller can see, in SupplyItemID order. Pass the
+//					   NextCursor of a page to get the one after it. Archived SupplyItems are left out unless
+//					   includeArchived is set.
+//=================================================================================================================================
+
+func (t *Chaincode) get_supplyItems(stub Stub, caller string, pageSize int, cursor string, includeArchived bool) ([]byte, error) {
+
+	page := Page{Items: []json.RawMessage{}}
+
+	viewer, err := t.new_viewer(stub, caller)
+	if err != nil { return nil, err }
+
+	next, err := page_keys(stub, SUPPLYITEM_KEY_TYPE, []string{}, cursor, pageSize, func(key string, bytes []byte) (bool, error) {
+
+		sItem, err := decode_supplyItem_record(bytes)
+
+		if err != nil {return false, err}
+
+		if is_archived(sItem) && !includeArchived { return false, nil }
+
+		temp, err := view_supplyItem(viewer, sItem, "get_supplyItems")
+
+		if err != nil { return false, nil }
+
+		page.Items = append(page.Items, temp)
+		return true, nil
+	})
+
+	if err != nil { return nil, err }
+
+	page.NextCursor = next
+
+	total, err := get_supplyItem_count(stub)
+
+	if err != nil { return nil, err }
+
+	page.ApproxTotal = &total
+
+	return json.Marshal(page)
+}
+
+
+//=================================================================================================================================
+//	Query - Called on chaincode query. Takes a function name passed and calls that function. The caller is read from
+//  		the transaction certificate; the remaining arguments are passed on to the called function. Any error is
+//			returned as a ccerror.Error of the function.
+//=================================================================================================================================
+func (t *Chaincode) Query(stub Stub, function string, args []string) (result []byte, err error) {
+
+	defer func() { err = ccerror.Wrap(function, err) }()
+
+	caller, err := t.get_caller(stub)
+	if err != nil { return nil, err }
+
+	spec, ok := lookup_function(function, FUNCTION_QUERY)
+	if !ok { return nil, ccerror.New(ccerror.INVALID_ARGUMENT, "Received unknown function invocation " + function) }
+
+	err = t.authorize(stub, caller, function)
+	if err != nil { return nil, err }
+
+	err = spec.check_args(args)
+	if err != nil { return nil, err }
+
+	if function == "get_supplyItems" {
+		pageSize, cursor, err := parse_page_args(args)
+		if err != nil { return nil, err }
+		return t.get_supplyItems(stub, caller, pageSize, cursor, parse_flag(args, 2))
+	} else if function == "get_supplyItem" {
+		return t.get_supplyItem(stub, caller, args[0])
+	} else if function == "get_pending_transfers" {
+		return t.get_pending_transfers(stub, caller)
+	} else if function == "get_supplyItem_history" {
+		return t.get_supplyItem_history(stub, caller, args[0])
+	} else if function == "get_allowed_transitions" {
+		return t.get_allowed_transitions(stub, caller, args[0])
+	} else if function == "get_supplyItem_origins" {
+		return t.get_supplyItem_origins(stub, caller, args[0])
+	} else if function == "get_supplyItem_descendants" {
+		return t.get_supplyItem_descendants(stub, caller, args[0])
+	} else if function == "trace_components" {
+		return t.trace_components(stub, caller, args[0])
+	} else if function == "get_location_trail" {
+		return t.get_location_trail(stub, caller, args[0])
+	} else if function == "find_supplyItems_in_box" {
+		return t.find_supplyItems_in_box(stub, caller, args)
+	} else if function == "find_supplyItems_near" {
+		return t.find_supplyItems_near(stub, caller, args)
+	} else if function == "get_recall_exposure" {
+		return t.get_recall_exposure(stub, caller, args[0])
+	} else if function == "get_policy" {
+		return t.get_policy(stub)
+	} else if function == "get_holdings" {
+		return t.get_holdings(stub, caller, args)
+	} else if function == "get_supplier_volume" {
+		return t.get_supplier_volume(stub, caller, args)
+	} else if function == "verify_attachment" {
+		return t.verify_attachment(stub, caller, args)
+	} else if function == "get_attachment_config" {
+		return t.get_attachment_config(stub)
+	} else if function == "get_retention_config" {
+		return t.get_retention_config(stub)
+	} else if function == "get_units" {
+		return t.get_units(stub)
+	} else if function == "get_participant" {
+		return t.get_participant(stub, caller, args[0])
+	} else if function == "describe" {
+		return t.describe(stub)
+	} else if function == "get_schema_versions" {
+		return t.get_schema_versions(stub)
+	} else if function == "query_supplyItems" {
+		var filter SupplyItemFilter
+		err = json.Unmarshal([]byte(args[0]), &filter)
+		if err != nil { return nil, ccerror.New(ccerror.INVALID_ARGUMENT, "QUERY: Invalid filter object: " + err.Error()) }
+		pageSize, cursor, err := parse_page_args(args[1:])
+		if err != nil { return nil, err }
+		return t.query_supplyItems(stub, caller, filter, pageSize, cursor)
+	}
+
+	return nil, ccerror.New(ccerror.INVALID_ARGUMENT, "Received unknown function invocation " + function)
+
+}
@@ -0,0 +1,275 @@
+/*
+Copyright IBM Corp 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+
+package supplychain
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/vsagineedu/learn-chaincode/ccerror"
+)
+
+//==============================================================================================================================
+//	check_error - Fails the test unless err is nil when want is "", or err contains want otherwise.
+//==============================================================================================================================
+func check_error(t *testing.T, err error, want string) {
+	if want == "" && err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if want != "" && err == nil {
+		t.Fatalf("expected an error containing %q", want)
+	}
+	if want != "" && !strings.Contains(err.Error(), want) {
+		t.Fatalf("expected an error containing %q, got: %s", want, err)
+	}
+}
+
+func TestInit(t *testing.T) {
+
+	tests := []struct {
+		name string
+		args []string
+		want string
+	}{
+		{"first admin",           []string{participant_json("root", ROLE_ADMIN)},            ""},
+		{"admin role is implied", []string{participant_json("root", ROLE_OWNER)},            ""},
+		{"missing args",          []string{},                                                 "Incorrect number of arguments"},
+		{"too many args",         []string{participant_json("root"), participant_json("x")},  "Incorrect number of arguments"},
+		{"not JSON",              []string{"root"},                                           "Invalid participant object"},
+		{"invalid participant",   []string{participant_json("bad id", ROLE_ADMIN)},          "participantID"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			l := empty_ledger(t)
+
+			_, err := l.init(tc.args...)
+			check_error(t, err, tc.want)
+			if tc.want != "" { return }
+
+			bytes, err := l.query("root", "get_participant", "root")
+			check_error(t, err, "")
+
+			var p Participant
+			if err := json.Unmarshal(bytes, &p); err != nil { t.Fatal(err) }
+			if !p.has_role(ROLE_ADMIN) || p.Status != PARTICIPANT_ACTIVE { t.Fatalf("seeded admin is %+v", p) }
+		})
+	}
+}
+
+func TestCreateSupplyItem(t *testing.T) {
+
+	tests := []struct {
+		name   string
+		caller string
+		args   []string
+		want   string
+	}{
+		{"JSON object",              TEST_SUPPLIER, []string{a_supplyItem("A1").json()},                               ""},
+		{"positional args",          TEST_SUPPLIER, a_supplyItem("A1").args(),                                         ""},
+		{"unit alias",               TEST_SUPPLIER, []string{a_supplyItem("A1").unit("kg").json()},                    ""},
+		{"no operator",              TEST_SUPPLIER, []string{a_supplyItem("A1").without("operatorID").json()},         ""},
+		{"duplicate ID",             TEST_SUPPLIER, []string{a_supplyItem("EXISTING").json()},                         `"code":"ALREADY_EXISTS"`},
+		{"missing args",             TEST_SUPPLIER, []string{},                                                        "Incorrect number of arguments"},
+		{"too few positional args",  TEST_SUPPLIER, a_supplyItem("A1").args()[:10],                                    "Incorrect number of arguments"},
+		{"not JSON",                 TEST_SUPPLIER, []string{"A1"},                                                    "not a JSON object"},
+		{"missing materialType",     TEST_SUPPLIER, []string{a_supplyItem("A1").without("materialType").json()},       `"field":"materialType"`},
+		{"zero quantity",            TEST_SUPPLIER, []string{a_supplyItem("A1").quantity(0).json()},                   `"field":"materialQuantity"`},
+		{"unknown unit",             TEST_SUPPLIER, []string{a_supplyItem("A1").unit("XYZ").json()},                   `"field":"unitOfMeasure"`},
+		{"invalid ID",               TEST_SUPPLIER, []string{a_supplyItem("A 1").json()},                              `"field":"supplyItemID"`},
+		{"unknown field",            TEST_SUPPLIER, []string{a_supplyItem("A1").with("colour", "red").json()},         `"field":"colour"`},
+		{"latitude out of range",    TEST_SUPPLIER, []string{a_supplyItem("A1").with("latitude", 91).json()},          `"field":"latitude"`},
+		{"unregistered owner",       TEST_SUPPLIER, []string{a_supplyItem("A1").owner("nobody").json()},               `"field":"ownerID"`},
+		{"owner may not create",     TEST_OWNER,    []string{a_supplyItem("A1").json()},                               `"code":"PERMISSION_DENIED"`},
+		{"auditor may not create",   TEST_AUDITOR,  []string{a_supplyItem("A1").json()},                               `"code":"PERMISSION_DENIED"`},
+		{"unregistered caller",      "stranger",    []string{a_supplyItem("A1").json()},                               `"code":"PERMISSION_DENIED"`},
+		{"no username attribute",    "",            []string{a_supplyItem("A1").json()},                               `"code":"PERMISSION_DENIED"`},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			l := new_ledger(t)
+			l.create(a_supplyItem("EXISTING"))
+
+			_, err := l.invoke(tc.caller, "create_supplyItem", tc.args...)
+			check_error(t, err, tc.want)
+
+			bytes, qerr := l.query(TEST_AUDITOR, "get_supplyItem", "A1")
+			if tc.want != "" {
+				if qerr == nil { t.Fatalf("a refused create_supplyItem stored %s", bytes) }
+				return
+			}
+			check_error(t, qerr, "")
+
+			sItem := decode_supplyItem(t, bytes)
+			if sItem.SupplyItemID != "A1" || sItem.Status != STATUS_CREATED || sItem.Revision != 1 {
+				t.Fatalf("created %+v", sItem)
+			}
+			if sItem.UnitOfMeasure != "KGM" || sItem.SupplierID != TEST_SUPPLIER || sItem.OwnerID != TEST_OWNER {
+				t.Fatalf("created %+v", sItem)
+			}
+		})
+	}
+}
+
+func TestInitKeepsRegisteredParticipant(t *testing.T) {
+
+	l := new_ledger(t)
+	if _, err := l.invoke(TEST_ADMIN, "update_participant", TEST_OWNER, `{"status":"` + PARTICIPANT_SUSPENDED + `"}`); err != nil { t.Fatal(err) }
+
+	_, err := l.init(participant_json(TEST_OWNER, ROLE_ADMIN))
+	check_error(t, err, "")
+
+	bytes, err := l.query(TEST_ADMIN, "get_participant", TEST_OWNER)
+	check_error(t, err, "")
+
+	var p Participant
+	if err := json.Unmarshal(bytes, &p); err != nil { t.Fatal(err) }
+	if p.has_role(ROLE_ADMIN) || p.Status != PARTICIPANT_SUSPENDED { t.Fatalf("init overwrote %+v", p) }
+}
+
+//==============================================================================================================================
+//	 UnreadableStub - A TestStub whose reads all fail, standing in for a peer that cannot reach its ledger.
+//==============================================================================================================================
+type UnreadableStub struct {
+	*TestStub
+}
+
+func (s UnreadableStub) GetState(key string) ([]byte, error) {
+	return nil, errors.New("ledger unavailable")
+}
+
+func TestCheckUniqueSupplyItem(t *testing.T) {
+
+	l := new_ledger(t)
+	l.create(a_supplyItem("EXISTING"))
+	stub := &TestStub{state: l.state, events: map[string][]byte{}}
+
+	_, err := l.cc.check_unique_supplyItem(stub, "A1")
+	check_error(t, err, "")
+
+	_, err = l.cc.check_unique_supplyItem(stub, "EXISTING")
+	check_error(t, err, `"code":"ALREADY_EXISTS"`)
+
+	_, err = l.cc.check_unique_supplyItem(UnreadableStub{stub}, "A1")
+	if err == nil || ccerror.CodeOf(err) == ccerror.ALREADY_EXISTS {
+		t.Fatalf("a failed ledger read was reported as %v", err)
+	}
+}
+
+func TestUpdateSupplyItem(t *testing.T) {
+
+	tests := []struct {
+		name   string
+		caller string
+		args   []string
+		want   string
+	}{
+		{"owner",                   TEST_OWNER,    []string{"U1", `{"description":"updated"}`},              ""},
+		{"operator",                TEST_OPERATOR, []string{"U1", `{"description":"updated"}`},              ""},
+		{"supplier is not a party", TEST_SUPPLIER, []string{"U1", `{"description":"updated"}`},              `"code":"PERMISSION_DENIED"`},
+		{"auditor may not update",  TEST_AUDITOR,  []string{"U1", `{"description":"updated"}`},              `"code":"PERMISSION_DENIED"`},
+		{"unregistered caller",     "stranger",    []string{"U1", `{"description":"updated"}`},              `"code":"PERMISSION_DENIED"`},
+		{"missing item",            TEST_OWNER,    []string{"NOPE", `{"description":"updated"}`},            `"code":"NOT_FOUND"`},
+		{"corrupt record",          TEST_OWNER,    []string{"CORRUPT", `{"description":"updated"}`},         `"code":"CORRUPT_RECORD"`},
+		{"missing args",            TEST_OWNER,    []string{"U1"},                                           "Incorrect number of arguments"},
+		{"no args",                 TEST_OWNER,    []string{},                                               "Incorrect number of arguments"},
+		{"not JSON",                TEST_OWNER,    []string{"U1", "updated"},                                "not a JSON object"},
+		{"custody is read-only",    TEST_OWNER,    []string{"U1", `{"ownerID":"` + TEST_OPERATOR + `"}`},   `"field":"ownerID"`},
+		{"invalid longitude",       TEST_OWNER,    []string{"U1", `{"longitude":"east"}`},                   `"field":"longitude"`},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			l := new_ledger(t)
+			l.create(a_supplyItem("U1"))
+			l.put_raw("CORRUPT", []byte("{not json"))
+
+			_, err := l.invoke(tc.caller, "update_supplyItem", tc.args...)
+			check_error(t, err, tc.want)
+
+			bytes, err := l.query(TEST_AUDITOR, "get_supplyItem", "U1")
+			check_error(t, err, "")
+
+			sItem := decode_supplyItem(t, bytes)
+			if tc.want == "" && (sItem.Description != "updated" || sItem.Revision != 2) { t.Fatalf("update not saved: %+v", sItem) }
+			if tc.want != "" && (sItem.Description != "Test item U1" || sItem.Revision != 1) { t.Fatalf("refused update saved: %+v", sItem) }
+		})
+	}
+}
+
+func TestGetSupplyItems(t *testing.T) {
+
+	tests := []struct {
+		name   string
+		caller string
+		args   []string
+		corrupt bool
+		want   []string
+		cursor bool
+		err    string
+	}{
+		{"auditor reads all",         TEST_AUDITOR,  nil,                  false, []string{"G1", "G2", "G3"}, false, ""},
+		{"owner sees own items",      TEST_OWNER,    nil,                  false, []string{"G1", "G3"},       false, ""},
+		{"supplier sees supplied",    TEST_SUPPLIER, nil,                  false, []string{"G1", "G2", "G3"}, false, ""},
+		{"first page",                TEST_AUDITOR,  []string{"2"},        false, []string{"G1", "G2"},       true,  ""},
+		{"participant with no items", "other",       nil,                  false, []string{},                 false, ""},
+		{"bad page size",             TEST_AUDITOR,  []string{"many"},     false, nil,                        false, `"field":"pageSize"`},
+		{"page size out of range",    TEST_AUDITOR,  []string{"0"},        false, nil,                        false, "Page size"},
+		{"bad cursor",                TEST_AUDITOR,  []string{"2", "!!"},  false, nil,                        false, "Invalid cursor"},
+		{"too many args",             TEST_AUDITOR,  []string{"2", "", "", ""}, false, nil,                   false, "Incorrect number of arguments"},
+		{"unregistered caller",       "stranger",    nil,                  false, nil,                        false, `"code":"PERMISSION_DENIED"`},
+		{"corrupt record",            TEST_AUDITOR,  nil,                  true,  nil,                        false, `"code":"CORRUPT_RECORD"`},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			l := new_ledger(t)
+			l.register("other", ROLE_OWNER)
+			l.register("owner2", ROLE_OWNER)
+			l.create(a_supplyItem("G1"))
+			l.create(a_supplyItem("G2").owner("owner2").operator(""))
+			l.create(a_supplyItem("G3"))
+			if tc.corrupt { l.put_raw("G0", []byte("{not json")) }
+
+			bytes, err := l.query(tc.caller, "get_supplyItems", tc.args...)
+			check_error(t, err, tc.err)
+			if tc.err != "" { return }
+
+			page, items := decode_page(t, bytes)
+
+			var ids []string
+			for _, sItem := range items {
+				ids = append(ids, sItem.SupplyItemID)
+			}
+			if strings.Join(ids, ",") != strings.Join(tc.want, ",") { t.Fatalf("got %v, want %v", ids, tc.want) }
+			if (page.NextCursor != "") != tc.cursor { t.Fatalf("nextCursor %q", page.NextCursor) }
+			if page.ApproxTotal == nil || *page.ApproxTotal != 3 { t.Fatalf("approxTotal %v", page.ApproxTotal) }
+
+			if !tc.cursor { return }
+
+			bytes, err = l.query(tc.caller, "get_supplyItems", "2", page.NextCursor)
+			check_error(t, err, "")
+
+			page, items = decode_page(t, bytes)
+			if len(items) != 1 || items[0].SupplyItemID != "G3" || page.NextCursor != "" { t.Fatalf("second page %s", bytes) }
+		})
+	}
+}
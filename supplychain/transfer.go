@@ -0,0 +1,320 @@
+/*
+Copyright IBM Corp 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package supplychain
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/vsagineedu/learn-chaincode/ccerror"
+)
+
+//==============================================================================================================================
+//	Transfer kinds - Ownership and operation of a SupplyItem are handed over independently of each other.
+//==============================================================================================================================
+const TRANSFER_OWNERSHIP = "ownership"
+const TRANSFER_OPERATION = "operation"
+
+const TRANSFER_KEY_TYPE = "transfer"
+
+//==============================================================================================================================
+//	Transfer - A proposed handover of a SupplyItem that is waiting for the recipient to accept or reject it. At most one
+//			   Transfer of each kind can be pending for a SupplyItem.
+//==============================================================================================================================
+type Transfer struct {
+	SupplyItemID string `json:"supplyItemID"`
+	Kind         string `json:"kind"`
+	From         string `json:"from"`
+	To           string `json:"to"`
+	ProposedBy   string `json:"proposedBy"`
+	TxID         string `json:"txID"`
+}
+
+func transfer_key(supplyItemID string, kind string) (string, error) {
+	return create_composite_key(TRANSFER_KEY_TYPE, []string{supplyItemID, kind})
+}
+
+//==============================================================================================================================
+//	 retrieve_transfer - Gets the pending Transfer of the given kind for a SupplyItem. Returns an error if there is none.
+//==============================================================================================================================
+func (t *Chaincode) retrieve_transfer(stub Stub, supplyItemID string, kind string) (Transfer, error) {
+
+	var transfer Transfer
+
+	key, err := transfer_key(supplyItemID, kind)
+	if err != nil { return transfer, err }
+
+	bytes, err := stub.GetState(key)
+	if err != nil { fmt.Printf("RETRIEVE_TRANSFER: Failed to get transfer: %s", err); return transfer, errors.New("RETRIEVE_TRANSFER: Error retrieving transfer for supplyItemID = " + supplyItemID) }
+
+	if bytes == nil { return transfer, ccerror.New(ccerror.NOT_FOUND, "No pending " + kind + " transfer for supplyItemID = " + supplyItemID) }
+
+	err = json.Unmarshal(bytes, &transfer)
+	if err != nil { fmt.Printf("RETRIEVE_TRANSFER: Corrupt transfer record "+string(bytes)+": %s", err); return transfer, ccerror.New(ccerror.CORRUPT_RECORD, "RETRIEVE_TRANSFER: Corrupt transfer record" + string(bytes)) }
+
+	return transfer, nil
+}
+
+func (t *Chaincode) save_transfer(stub Stub, transfer Transfer) error {
+
+	key, err := transfer_key(transfer.SupplyItemID, transfer.Kind)
+	if err != nil { return err }
+
+	bytes, err := json.Marshal(transfer)
+	if err != nil { fmt.Printf("SAVE_TRANSFER: Error converting transfer record: %s", err); return errors.New("Error converting transfer record") }
+
+	err = stub.PutState(key, bytes)
+	if err != nil { fmt.Printf("SAVE_TRANSFER: Error storing transfer record: %s", err); return errors.New("Error storing transfer record") }
+
+	return nil
+}
+
+func (t *Chaincode) delete_transfer(stub Stub, transfer Transfer) error {
+
+	key, err := transfer_key(transfer.SupplyItemID, transfer.Kind)
+	if err != nil { return err }
+
+	err = stub.DelState(key)
+	if err != nil { fmt.Printf("DELETE_TRANSFER: Error deleting transfer record: %s", err); return errors.New("Error deleting transfer record") }
+
+	return nil
+}
+
+//==============================================================================================================================
+//	 is_transfer_recipient - Reports whether a Transfer of either kind is pending to caller for a SupplyItem.
+//==============================================================================================================================
+func (t *Chaincode) is_transfer_recipient(stub Stub, supplyItemID string, caller string) (bool, error) {
+
+	for _, kind := range []string{TRANSFER_OWNERSHIP, TRANSFER_OPERATION} {
+		transfer, err := t.retrieve_transfer(stub, supplyItemID, kind)
+		if ccerror.CodeOf(err) == ccerror.NOT_FOUND { continue }
+		if err != nil { return false, err }
+		if transfer.To == caller { return true, nil }
+	}
+	return false, nil
+}
+
+func check_transfer_kind(kind string) error {
+	if kind != TRANSFER_OWNERSHIP && kind != TRANSFER_OPERATION {
+		return ccerror.New(ccerror.INVALID_ARGUMENT, "Invalid transfer kind " + kind + ". Expecting " + TRANSFER_OWNERSHIP + " or " + TRANSFER_OPERATION)
+	}
+	return nil
+}
+
+//=================================================================================================================================
+//	 propose_transfer - Records a pending handover of ownership or operation of a SupplyItem. Only the current owner may
+//						propose either kind. Nothing changes on the SupplyItem until the recipient accepts.
+//=================================================================================================================================
+func (t *Chaincode) propose_transfer(stub Stub, caller string, args []string) ([]byte, error) {
+
+	//Args
+	//		0			1		2
+	//	supplyItemID	kind	recipient
+
+	if len(args) != 3 { return nil, ccerror.New(ccerror.INVALID_ARGUMENT, "PROPOSE_TRANSFER: Incorrect number of arguments. Expecting supplyItemID, kind and recipient") }
+
+	supplyItemID, kind, recipient := args[0], args[1], args[2]
+
+	if err := check_transfer_kind(kind); err != nil { return nil, err }
+
+	verr := new_validation_error("propose_transfer")
+	validate_id(verr, "recipient", recipient, true)
+	if !verr.Has("recipient") {
+		if _, err := t.check_active_participant(stub, recipient); err != nil { verr.Add("recipient", ccerror.MessageOf(err)) }
+	}
+	if err := verr.Result(); err != nil { return nil, err }
+
+	sItem, err := t.retrieve_SupplyItem(stub, supplyItemID)
+	if err != nil { fmt.Printf("PROPOSE_TRANSFER: Error retrieving supplyItem: %s", err); return nil, err }
+
+	if sItem.OwnerID != caller { return nil, permission_denied("propose_transfer") }
+
+	err = check_transition(sItem, "propose_transfer", current_status(sItem))
+	if err != nil { return nil, err }
+
+	from := sItem.OwnerID
+	if kind == TRANSFER_OPERATION {
+		from = sItem.OperatorID
+	}
+
+	if recipient == from { return nil, ccerror.New(ccerror.CONFLICT, "PROPOSE_TRANSFER: " + recipient + " already holds " + kind + " of " + supplyItemID) }
+
+	if pending, err := t.retrieve_transfer(stub, supplyItemID, kind); err == nil && pending.ProposedBy == sItem.OwnerID {	// A pending transfer proposed by a previous owner is stale and may be replaced
+		return nil, ccerror.New(ccerror.CONFLICT, "PROPOSE_TRANSFER: A " + kind + " transfer is already pending for " + supplyItemID + ". Cancel it first")
+	}
+
+	transfer := Transfer{
+		SupplyItemID: supplyItemID,
+		Kind:         kind,
+		From:         from,
+		To:           recipient,
+		ProposedBy:   caller,
+		TxID:         stub.GetTxID(),
+	}
+
+	err = t.save_transfer(stub, transfer)
+	if err != nil { fmt.Printf("PROPOSE_TRANSFER: Error saving transfer: %s", err); return nil, err }
+
+	return nil, t.emit_transfer_event(stub, transfer, caller, "propose_transfer")
+}
+
+//=================================================================================================================================
+//	 accept_transfer - Called by the recipient of a pending Transfer. Hands ownership or operation of the SupplyItem to
+//					   the recipient and removes the Transfer. A proposal made by someone who is no longer the owner is
+//					   stale and cannot be accepted.
+//=================================================================================================================================
+func (t *Chaincode) accept_transfer(stub Stub, caller string, args []string) ([]byte, error) {
+
+	//Args
+	//		0			1
+	//	supplyItemID	kind
+
+	if len(args) != 2 { return nil, ccerror.New(ccerror.INVALID_ARGUMENT, "ACCEPT_TRANSFER: Incorrect number of arguments. Expecting supplyItemID and kind") }
+
+	supplyItemID, kind := args[0], args[1]
+
+	if err := check_transfer_kind(kind); err != nil { return nil, err }
+
+	transfer, err := t.retrieve_transfer(stub, supplyItemID, kind)
+	if err != nil { return nil, err }
+
+	if transfer.To != caller { return nil, permission_denied("accept_transfer") }
+
+	if _, err := t.check_active_participant(stub, caller); err != nil { return nil, err }
+
+	sItem, err := t.retrieve_SupplyItem(stub, supplyItemID)
+	if err != nil { fmt.Printf("ACCEPT_TRANSFER: Error retrieving supplyItem: %s", err); return nil, err }
+
+	if sItem.OwnerID != transfer.ProposedBy { return nil, ccerror.New(ccerror.CONFLICT, "ACCEPT_TRANSFER: Transfer is stale. " + transfer.ProposedBy + " no longer owns " + supplyItemID) }
+
+	err = check_transition(sItem, "accept_transfer", current_status(sItem))
+	if err != nil { return nil, err }
+
+	if kind == TRANSFER_OWNERSHIP {
+		sItem.OwnerID = transfer.To
+	} else {
+		sItem.OperatorID = transfer.To
+	}
+
+	_, err = t.save_changes(stub, sItem, caller, "accept_transfer")
+	if err != nil { fmt.Printf("ACCEPT_TRANSFER: Error saving changes: %s", err); return nil, err }
+
+	err = t.delete_transfer(stub, transfer)
+	if err != nil { return nil, err }
+
+	return nil, nil
+}
+
+//=================================================================================================================================
+//	 reject_transfer - Called by the recipient of a pending Transfer to decline it. The SupplyItem's fields are left
+//					   unchanged, but a new revision records the rejection in its history, unless the SupplyItem was
+//					   consumed or destroyed while the Transfer was pending and so takes no further revisions.
+//=================================================================================================================================
+func (t *Chaincode) reject_transfer(stub Stub, caller string, args []string) ([]byte, error) {
+
+	//Args
+	//		0			1
+	//	supplyItemID	kind
+
+	if len(args) != 2 { return nil, ccerror.New(ccerror.INVALID_ARGUMENT, "REJECT_TRANSFER: Incorrect number of arguments. Expecting supplyItemID and kind") }
+
+	supplyItemID, kind := args[0], args[1]
+
+	if err := check_transfer_kind(kind); err != nil { return nil, err }
+
+	transfer, err := t.retrieve_transfer(stub, supplyItemID, kind)
+	if err != nil { return nil, err }
+
+	if transfer.To != caller { return nil, permission_denied("reject_transfer") }
+
+	sItem, err := t.retrieve_SupplyItem(stub, supplyItemID)
+	if err != nil { fmt.Printf("REJECT_TRANSFER: Error retrieving supplyItem: %s", err); return nil, err }
+
+	if check_transition(sItem, "reject_transfer", current_status(sItem)) == nil {
+		_, err = t.save_changes(stub, sItem, caller, "reject_transfer")
+		if err != nil { fmt.Printf("REJECT_TRANSFER: Error saving changes: %s", err); return nil, err }
+	}
+
+	err = t.delete_transfer(stub, transfer)
+	if err != nil { return nil, err }
+
+	return nil, t.emit_transfer_event(stub, transfer, caller, "reject_transfer")
+}
+
+//=================================================================================================================================
+//	 cancel_transfer - Called by the proposer, or the current owner, to withdraw a pending Transfer. As with a rejection,
+//					   a new revision records the cancellation in the SupplyItem's history unless it was consumed or
+//					   destroyed meanwhile.
+//=================================================================================================================================
+func (t *Chaincode) cancel_transfer(stub Stub, caller string, args []string) ([]byte, error) {
+
+	//Args
+	//		0			1
+	//	supplyItemID	kind
+
+	if len(args) != 2 { return nil, ccerror.New(ccerror.INVALID_ARGUMENT, "CANCEL_TRANSFER: Incorrect number of arguments. Expecting supplyItemID and kind") }
+
+	supplyItemID, kind := args[0], args[1]
+
+	if err := check_transfer_kind(kind); err != nil { return nil, err }
+
+	transfer, err := t.retrieve_transfer(stub, supplyItemID, kind)
+	if err != nil { return nil, err }
+
+	sItem, err := t.retrieve_SupplyItem(stub, supplyItemID)
+	if err != nil { fmt.Printf("CANCEL_TRANSFER: Error retrieving supplyItem: %s", err); return nil, err }
+
+	if transfer.ProposedBy != caller && sItem.OwnerID != caller { return nil, permission_denied("cancel_transfer") }
+
+	if check_transition(sItem, "cancel_transfer", current_status(sItem)) == nil {
+		_, err = t.save_changes(stub, sItem, caller, "cancel_transfer")
+		if err != nil { fmt.Printf("CANCEL_TRANSFER: Error saving changes: %s", err); return nil, err }
+	}
+
+	err = t.delete_transfer(stub, transfer)
+	if err != nil { return nil, err }
+
+	return nil, t.emit_transfer_event(stub, transfer, caller, "cancel_transfer")
+}
+
+//=================================================================================================================================
+//	 get_pending_transfers - Returns the pending Transfers the caller has proposed or been offered.
+//=================================================================================================================================
+func (t *Chaincode) get_pending_transfers(stub Stub, caller string) ([]byte, error) {
+
+	iter, err := range_query_composite_key(stub, TRANSFER_KEY_TYPE, []string{})
+	if err != nil { return nil, errors.New("Unable to query pending transfers") }
+	defer iter.Close()
+
+	transfers := []Transfer{}
+
+	for iter.HasNext() {
+		_, bytes, err := iter.Next()
+		if err != nil { return nil, errors.New("Unable to query pending transfers") }
+
+		var transfer Transfer
+		err = json.Unmarshal(bytes, &transfer)
+		if err != nil { return nil, ccerror.New(ccerror.CORRUPT_RECORD, "Corrupt transfer record " + string(bytes)) }
+
+		if transfer.To == caller || transfer.ProposedBy == caller {
+			transfers = append(transfers, transfer)
+		}
+	}
+
+	return json.Marshal(transfers)
+}
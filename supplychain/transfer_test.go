@@ -0,0 +1,186 @@
+/*
+Copyright IBM Corp 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+
+package supplychain
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func history_entries(t *testing.T, l *Ledger, supplyItemID string) []HistoryEntry {
+	bytes, err := l.query(TEST_AUDITOR, "get_supplyItem_history", supplyItemID)
+	if err != nil { t.Fatalf("get_supplyItem_history: %s", err) }
+
+	var entries []HistoryEntry
+	if err := json.Unmarshal(bytes, &entries); err != nil { t.Fatalf("decoding %s: %s", bytes, err) }
+	return entries
+}
+
+func TestTransferHistory(t *testing.T) {
+
+	tests := []struct {
+		name   string
+		caller string
+		action string
+		owner  string
+	}{
+		{"accepted",  "owner2",   "accept_transfer", "owner2"},
+		{"rejected",  "owner2",   "reject_transfer", TEST_OWNER},
+		{"cancelled", TEST_OWNER, "cancel_transfer", TEST_OWNER},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			l := new_ledger(t)
+			l.register("owner2", ROLE_OWNER)
+			l.create(a_supplyItem("A1"))
+
+			if _, err := l.invoke(TEST_OWNER, "propose_transfer", "A1", TRANSFER_OWNERSHIP, "owner2"); err != nil { t.Fatal(err) }
+
+			_, err := l.invoke(tc.caller, tc.action, "A1", TRANSFER_OWNERSHIP)
+			check_error(t, err, "")
+
+			bytes, err := l.query(TEST_AUDITOR, "get_supplyItem", "A1")
+			if err != nil { t.Fatal(err) }
+			if sItem := decode_supplyItem(t, bytes); sItem.OwnerID != tc.owner || sItem.Revision != 2 { t.Fatalf("after %s: %+v", tc.action, sItem) }
+
+			entries := history_entries(t, l, "A1")
+			if len(entries) != 2 { t.Fatalf("history %+v", entries) }
+			if last := entries[1]; last.Action != tc.action || last.Actor != tc.caller || last.Revision != 2 {
+				t.Errorf("last history entry %+v", last)
+			}
+		})
+	}
+}
+
+func TestTransferAfterFinalStatus(t *testing.T) {
+
+	tests := []struct {
+		name   string
+		caller string
+		action string
+		status SupplyItemStatus
+	}{
+		{"rejected after destroyed", "owner2",   "reject_transfer", STATUS_DESTROYED},
+		{"cancelled after consumed", TEST_OWNER, "cancel_transfer", STATUS_CONSUMED},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			l := new_ledger(t)
+			l.register("owner2", ROLE_OWNER)
+			l.create(a_supplyItem("A1"))
+
+			if _, err := l.invoke(TEST_OWNER, "propose_transfer", "A1", TRANSFER_OWNERSHIP, "owner2"); err != nil { t.Fatal(err) }
+			if _, err := l.invoke(TEST_OWNER, "update_status", "A1", string(tc.status)); err != nil { t.Fatal(err) }
+
+			_, err := l.invoke(tc.caller, tc.action, "A1", TRANSFER_OWNERSHIP)
+			check_error(t, err, "")
+
+			_, err = l.invoke(tc.caller, tc.action, "A1", TRANSFER_OWNERSHIP)
+			check_error(t, err, `"code":"NOT_FOUND"`)
+
+			entries := history_entries(t, l, "A1")
+			if last := entries[len(entries)-1]; len(entries) != 2 || last.Action != "update_status" { t.Errorf("history %+v, want nothing after update_status", entries) }
+		})
+	}
+}
+
+func TestProposeTransfer(t *testing.T) {
+
+	tests := []struct {
+		name      string
+		caller    string
+		kind      string
+		recipient string
+		status    SupplyItemStatus
+		want      string
+	}{
+		{"ownership",         TEST_OWNER,    TRANSFER_OWNERSHIP, "owner2",      "",              ""},
+		{"operation",         TEST_OWNER,    TRANSFER_OPERATION, "operator2",   "",              ""},
+		{"by the operator",   TEST_OPERATOR, TRANSFER_OPERATION, "operator2",   "",              `"code":"PERMISSION_DENIED"`},
+		{"unknown kind",      TEST_OWNER,    "custody",          "owner2",      "",              "Invalid transfer kind"},
+		{"unknown recipient", TEST_OWNER,    TRANSFER_OWNERSHIP, "nobody",      "",              `"field":"recipient"`},
+		{"to the holder",     TEST_OWNER,    TRANSFER_OPERATION, TEST_OPERATOR, "",              "already holds"},
+		{"already pending",   TEST_OWNER,    TRANSFER_OWNERSHIP, "owner3",      "",              "already pending"},
+		{"recalled",          TEST_OWNER,    TRANSFER_OWNERSHIP, "owner2",      STATUS_RECALLED, "Illegal transition"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			l := new_ledger(t)
+			l.register("owner2", ROLE_OWNER)
+			l.register("owner3", ROLE_OWNER)
+			l.register("operator2", ROLE_OPERATOR)
+			l.create(a_supplyItem("A1"))
+			if tc.status != "" {
+				if _, err := l.invoke(TEST_OWNER, "update_status", "A1", string(tc.status)); err != nil { t.Fatal(err) }
+			}
+			if tc.want == "already pending" {
+				if _, err := l.invoke(TEST_OWNER, "propose_transfer", "A1", TRANSFER_OWNERSHIP, "owner2"); err != nil { t.Fatal(err) }
+			}
+
+			_, err := l.invoke(tc.caller, "propose_transfer", "A1", tc.kind, tc.recipient)
+			check_error(t, err, tc.want)
+			if tc.want != "" { return }
+
+			for _, user := range []string{TEST_OWNER, tc.recipient, "owner3"} {
+				bytes, err := l.query(user, "get_pending_transfers")
+				if err != nil { t.Fatal(err) }
+
+				var transfers []Transfer
+				if err := json.Unmarshal(bytes, &transfers); err != nil { t.Fatal(err) }
+
+				if user == "owner3" {
+					if len(transfers) != 0 { t.Errorf("owner3 sees %s", bytes) }
+				} else if len(transfers) != 1 || transfers[0].Kind != tc.kind || transfers[0].To != tc.recipient || transfers[0].ProposedBy != TEST_OWNER {
+					t.Errorf("%s sees %s", user, bytes)
+				}
+			}
+
+			if sItem := get_supplyItem(t, l, "A1"); sItem.OwnerID != TEST_OWNER || sItem.OperatorID != TEST_OPERATOR || sItem.Revision != 1 { t.Errorf("proposal changed A1: %+v", sItem) }
+		})
+	}
+}
+
+func TestAcceptTransferRefused(t *testing.T) {
+
+	l := new_ledger(t)
+	l.register("owner2", ROLE_OWNER)
+	l.register("operator2", ROLE_OPERATOR)
+	l.create(a_supplyItem("A1"))
+
+	_, err := l.invoke("owner2", "accept_transfer", "A1", TRANSFER_OWNERSHIP)
+	check_error(t, err, `"code":"NOT_FOUND"`)
+
+	if _, err := l.invoke(TEST_OWNER, "propose_transfer", "A1", TRANSFER_OPERATION, "operator2"); err != nil { t.Fatal(err) }
+	if _, err := l.invoke(TEST_OWNER, "propose_transfer", "A1", TRANSFER_OWNERSHIP, "owner2"); err != nil { t.Fatal(err) }
+
+	_, err = l.invoke(TEST_OPERATOR, "accept_transfer", "A1", TRANSFER_OPERATION)
+	check_error(t, err, `"code":"PERMISSION_DENIED"`)
+
+	_, err = l.invoke("owner2", "reject_transfer", "A1", TRANSFER_OPERATION)
+	check_error(t, err, `"code":"PERMISSION_DENIED"`)
+
+	if _, err := l.invoke("owner2", "accept_transfer", "A1", TRANSFER_OWNERSHIP); err != nil { t.Fatal(err) }
+
+	_, err = l.invoke("operator2", "accept_transfer", "A1", TRANSFER_OPERATION)
+	check_error(t, err, "Transfer is stale")
+
+	if sItem := get_supplyItem(t, l, "A1"); sItem.OwnerID != "owner2" || sItem.OperatorID != TEST_OPERATOR { t.Errorf("A1 = %+v", sItem) }
+}
@@ -0,0 +1,283 @@
+/*
+Copyright IBM Corp 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package supplychain
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+	"strings"
+
+	"github.com/vsagineedu/learn-chaincode/ccerror"
+)
+
+//==============================================================================================================================
+//	Unit registry - The units a MaterialQty may be expressed in. Each Unit measures one dimension and converts to that
+//					dimension's base unit by multiplying by its Factor. The default_units are always known; admins add
+//					further units with register_unit, which are stored under (UNIT_KEY_TYPE, code).
+//==============================================================================================================================
+const UNIT_KEY_TYPE = "unit"
+
+const DIMENSION_MASS = "mass"
+const DIMENSION_VOLUME = "volume"
+const DIMENSION_LENGTH = "length"
+const DIMENSION_COUNT = "count"
+
+var base_units = map[string]UnitOfMeasure{
+	DIMENSION_MASS:   UOM_KILOGRAM,
+	DIMENSION_VOLUME: UOM_CUBIC_METRE,
+	DIMENSION_LENGTH: UOM_METRE,
+	DIMENSION_COUNT:  UOM_PIECE,
+}
+
+const MAX_UNIT_CODE_LENGTH = 3
+
+//==============================================================================================================================
+//	Unit - A registered unit of measure. Code is its UN/CEFACT Recommendation 20 code. Aliases are the other spellings
+//		   accepted on input, matched ignoring case; they are replaced by the Code before the SupplyItem is stored.
+//==============================================================================================================================
+type Unit struct {
+	Code      UnitOfMeasure `json:"code"`
+	Name      string        `json:"name"`
+	Dimension string        `json:"dimension"`
+	Factor    Quantity      `json:"factor"`
+	Aliases   []string      `json:"aliases"`
+}
+
+func default_units() []Unit {
+	return []Unit{
+		{Code: UOM_KILOGRAM, Name: "kilogram", Dimension: DIMENSION_MASS, Factor: QUANTITY_SCALE, Aliases: []string{"kg", "kgs", "kilo", "kilos", "kilogram", "kilograms"}},
+		{Code: UOM_GRAM, Name: "gram", Dimension: DIMENSION_MASS, Factor: QUANTITY_SCALE / 1000, Aliases: []string{"g", "gram", "grams"}},
+		{Code: UOM_TONNE, Name: "tonne", Dimension: DIMENSION_MASS, Factor: 1000 * QUANTITY_SCALE, Aliases: []string{"t", "tonne", "tonnes", "metric ton"}},
+		{Code: UOM_LITRE, Name: "litre", Dimension: DIMENSION_VOLUME, Factor: QUANTITY_SCALE / 1000, Aliases: []string{"l", "litre", "litres", "liter", "liters"}},
+		{Code: UOM_CUBIC_METRE, Name: "cubic metre", Dimension: DIMENSION_VOLUME, Factor: QUANTITY_SCALE, Aliases: []string{"m3", "cubic metre", "cubic metres", "cubic meter", "cubic meters"}},
+		{Code: UOM_METRE, Name: "metre", Dimension: DIMENSION_LENGTH, Factor: QUANTITY_SCALE, Aliases: []string{"m", "metre", "metres", "meter", "meters"}},
+		{Code: UOM_PIECE, Name: "piece", Dimension: DIMENSION_COUNT, Factor: QUANTITY_SCALE, Aliases: []string{"piece", "pieces", "pc", "pcs", "ea", "each"}},
+	}
+}
+
+func unit_key(code UnitOfMeasure) (string, error) {
+	return create_composite_key(UNIT_KEY_TYPE, []string{string(code)})
+}
+
+//==============================================================================================================================
+//	 retrieve_units - Returns every known unit ordered by code: the defaults, with the registered units added.
+//==============================================================================================================================
+func (t *Chaincode) retrieve_units(stub Stub) ([]Unit, error) {
+
+	units := default_units()
+
+	iter, err := range_query_composite_key(stub, UNIT_KEY_TYPE, []string{})
+	if err != nil { return nil, errors.New("Unable to query the ledger") }
+	defer iter.Close()
+
+	for iter.HasNext() {
+		_, bytes, err := iter.Next()
+		if err != nil { return nil, errors.New("Unable to query the ledger") }
+
+		var u Unit
+		err = json.Unmarshal(bytes, &u)
+		if err != nil { return nil, ccerror.New(ccerror.CORRUPT_RECORD, "Corrupt unit record " + string(bytes)) }
+
+		if i := find_unit(units, string(u.Code)); i >= 0 && units[i].Code == u.Code {
+			units[i] = u
+		} else {
+			units = append(units, u)
+		}
+	}
+
+	sort.Sort(by_code(units))
+	return units, nil
+}
+
+type by_code []Unit
+
+func (u by_code) Len() int           { return len(u) }
+func (u by_code) Less(i, j int) bool { return u[i].Code < u[j].Code }
+func (u by_code) Swap(i, j int)      { u[i], u[j] = u[j], u[i] }
+
+//==============================================================================================================================
+//	 find_unit - Returns the index of the unit whose code or one of whose aliases matches value ignoring case, or -1.
+//==============================================================================================================================
+func find_unit(units []Unit, value string) int {
+
+	value = strings.TrimSpace(value)
+
+	for i, u := range units {
+		if strings.EqualFold(string(u.Code), value) { return i }
+	}
+	for i, u := range units {
+		for _, alias := range u.Aliases {
+			if strings.EqualFold(alias, value) { return i }
+		}
+	}
+	return -1
+}
+
+func unit_codes(units []Unit) string {
+	codes := make([]string, len(units))
+	for i, u := range units {
+		codes[i] = string(u.Code)
+	}
+	return strings.Join(codes, ", ")
+}
+
+//==============================================================================================================================
+//	 resolve_unit - Replaces the UnitOfMeasure of sItem by the code of the registered unit it names, adding a field error
+//					to verr if it names none.
+//==============================================================================================================================
+func (t *Chaincode) resolve_unit(stub Stub, verr *ccerror.Error, sItem *SupplyItem) error {
+
+	if sItem.UnitOfMeasure == "" { return nil }			// Reported by validate_supplyItem
+
+	units, err := t.retrieve_units(stub)
+	if err != nil { return err }
+
+	i := find_unit(units, string(sItem.UnitOfMeasure))
+	if i < 0 { verr.Add("unitOfMeasure", "Must be one of "+unit_codes(units)+" or one of their aliases"); return nil }
+
+	sItem.UnitOfMeasure = units[i].Code
+	return nil
+}
+
+//==============================================================================================================================
+//	 normalize_quantity - Converts a quantity to the base unit of its dimension, rounding half away from zero to
+//						  QUANTITY_DECIMALS places.
+//==============================================================================================================================
+func normalize_quantity(qty Quantity, u Unit) (Quantity, error) {
+
+	product := new(big.Int).Mul(big.NewInt(int64(qty)), big.NewInt(int64(u.Factor)))
+
+	scale := big.NewInt(QUANTITY_SCALE)
+	half := big.NewInt(QUANTITY_SCALE / 2)
+	if product.Sign() < 0 {
+		product.Sub(product, half)
+	} else {
+		product.Add(product, half)
+	}
+	product.Quo(product, scale)
+
+	if product.BitLen() > 63 { return 0, ccerror.New(ccerror.INVALID_ARGUMENT, "Quantity " + qty.String() + " " + string(u.Code) + " is too large to normalize") }
+
+	return Quantity(product.Int64()), nil
+}
+
+//==============================================================================================================================
+//	 normalize_supplyItem - Sets the NormalizedQty and BaseUnit of sItem from its MaterialQty and UnitOfMeasure.
+//==============================================================================================================================
+func (t *Chaincode) normalize_supplyItem(stub Stub, sItem *SupplyItem) error {
+
+	units, err := t.retrieve_units(stub)
+	if err != nil { return err }
+
+	i := find_unit(units, string(sItem.UnitOfMeasure))
+	if i < 0 || units[i].Code != sItem.UnitOfMeasure { return ccerror.New(ccerror.INVALID_ARGUMENT, "Unknown unit of measure " + string(sItem.UnitOfMeasure)) }
+
+	sItem.NormalizedQty, err = normalize_quantity(sItem.MaterialQty, units[i])
+	if err != nil { return err }
+
+	sItem.BaseUnit = base_units[units[i].Dimension]
+	return nil
+}
+
+//=================================================================================================================================
+//	 register_unit - Adds a unit to the registry, or changes the name and aliases of one already known. The dimension
+//					 and factor of a known unit cannot change, since stored normalized quantities were computed with them.
+//=================================================================================================================================
+func (t *Chaincode) register_unit(stub Stub, caller string, args []string) ([]byte, error) {
+
+	//Args
+	//		0
+	//	unit JSON object of {code, name, dimension, factor, aliases}
+
+	if len(args) != 1 { return nil, ccerror.New(ccerror.INVALID_ARGUMENT, "REGISTER_UNIT: Incorrect number of arguments. Expecting a unit JSON object") }
+
+	var u Unit
+	err := json.Unmarshal([]byte(args[0]), &u)
+	if err != nil { return nil, ccerror.New(ccerror.INVALID_ARGUMENT, "REGISTER_UNIT: Invalid unit object: " + err.Error()) }
+
+	units, err := t.retrieve_units(stub)
+	if err != nil { return nil, err }
+
+	verr := new_validation_error("register_unit")
+
+	code := string(u.Code)
+	if code == "" {
+		verr.Add("code", "Required")
+	} else if len(code) > MAX_UNIT_CODE_LENGTH || strings.ToUpper(code) != code {
+		verr.Add("code", "Must be an upper case UN/CEFACT code of at most "+fmt.Sprint(MAX_UNIT_CODE_LENGTH)+" characters")
+	} else {
+		validate_id(verr, "code", code, true)
+	}
+
+	if strings.TrimSpace(u.Name) == "" { verr.Add("name", "Required") }
+	if len(u.Name) > MAX_TEXT_LENGTH { verr.Add("name", "Must be at most "+fmt.Sprint(MAX_TEXT_LENGTH)+" characters") }
+
+	base, ok := base_units[u.Dimension]
+	if !ok { verr.Add("dimension", "Must be one of "+DIMENSION_MASS+", "+DIMENSION_VOLUME+", "+DIMENSION_LENGTH+", "+DIMENSION_COUNT) }
+
+	if u.Factor <= 0 { verr.Add("factor", "Must be greater than 0") }
+	if ok && u.Code == base && u.Factor != QUANTITY_SCALE { verr.Add("factor", "Must be 1 for the base unit of "+u.Dimension) }
+
+	existing := -1
+	for i := range units {
+		if units[i].Code == u.Code { existing = i }
+	}
+	if existing >= 0 {
+		if u.Dimension != units[existing].Dimension { verr.Add("dimension", "Cannot be changed from "+units[existing].Dimension) }
+		if u.Factor != units[existing].Factor && !verr.Has("factor") { verr.Add("factor", "Cannot be changed from "+units[existing].Factor.String()) }
+	}
+
+	for i, alias := range u.Aliases {
+		field := fmt.Sprintf("aliases[%d]", i)
+		if strings.TrimSpace(alias) == "" || len(alias) > MAX_ID_LENGTH { verr.Add(field, "Must be between 1 and "+fmt.Sprint(MAX_ID_LENGTH)+" characters"); continue }
+		for j, other := range units {
+			if j == existing { continue }
+			if find_unit([]Unit{other}, alias) == 0 { verr.Add(field, "Already names "+string(other.Code)) }
+		}
+	}
+
+	if err := verr.Result(); err != nil { return nil, err }
+
+	if u.Aliases == nil {
+		u.Aliases = []string{}
+	}
+
+	key, err := unit_key(u.Code)
+	if err != nil { return nil, err }
+
+	bytes, err := json.Marshal(u)
+	if err != nil { fmt.Printf("REGISTER_UNIT: Error converting unit record: %s", err); return nil, errors.New("Error converting unit record") }
+
+	err = stub.PutState(key, bytes)
+	if err != nil { fmt.Printf("REGISTER_UNIT: Error storing unit record: %s", err); return nil, errors.New("Error storing unit record") }
+
+	return nil, nil
+}
+
+//=================================================================================================================================
+//	 get_units - Returns every known unit.
+//=================================================================================================================================
+func (t *Chaincode) get_units(stub Stub) ([]byte, error) {
+
+	units, err := t.retrieve_units(stub)
+	if err != nil { return nil, err }
+
+	return json.Marshal(units)
+}
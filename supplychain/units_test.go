@@ -0,0 +1,100 @@
+/*
+Copyright IBM Corp 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package supplychain
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestRegisterUnit(t *testing.T) {
+
+	tests := []struct {
+		name   string
+		caller string
+		unit   string
+		want   string
+	}{
+		{"new unit",              TEST_ADMIN,    `{"code":"LBR","name":"pound","dimension":"mass","factor":0.453592,"aliases":["lb","lbs"]}`, ""},
+		{"renamed default",       TEST_ADMIN,    `{"code":"KGM","name":"kilo","dimension":"mass","factor":1,"aliases":["kg"]}`,               ""},
+		{"not an admin",          TEST_SUPPLIER, `{"code":"LBR","name":"pound","dimension":"mass","factor":0.453592}`,                        `"code":"PERMISSION_DENIED"`},
+		{"lower case code",       TEST_ADMIN,    `{"code":"lbr","name":"pound","dimension":"mass","factor":0.453592}`,                        `"field":"code"`},
+		{"unknown dimension",     TEST_ADMIN,    `{"code":"LBR","name":"pound","dimension":"weight","factor":0.453592}`,                      `"field":"dimension"`},
+		{"zero factor",           TEST_ADMIN,    `{"code":"LBR","name":"pound","dimension":"mass","factor":0}`,                               `"field":"factor"`},
+		{"base unit factor",      TEST_ADMIN,    `{"code":"KGM","name":"kilogram","dimension":"mass","factor":2}`,                            "Must be 1 for the base unit"},
+		{"changed factor",        TEST_ADMIN,    `{"code":"GRM","name":"gram","dimension":"mass","factor":0.01}`,                             "Cannot be changed from 0.001"},
+		{"changed dimension",     TEST_ADMIN,    `{"code":"GRM","name":"gram","dimension":"count","factor":0.001}`,                           "Cannot be changed from mass"},
+		{"alias of another unit", TEST_ADMIN,    `{"code":"LBR","name":"pound","dimension":"mass","factor":0.453592,"aliases":["KG"]}`,       "Already names KGM"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			l := new_ledger(t)
+
+			_, err := l.invoke(tc.caller, "register_unit", tc.unit)
+			check_error(t, err, tc.want)
+
+			bytes, err := l.query(TEST_SUPPLIER, "get_units")
+			if err != nil { t.Fatal(err) }
+
+			var units []Unit
+			if err := json.Unmarshal(bytes, &units); err != nil { t.Fatal(err) }
+
+			want := len(default_units())
+			if tc.name == "new unit" {
+				want++
+			}
+			if len(units) != want { t.Errorf("%d units, want %d: %s", len(units), want, bytes) }
+		})
+	}
+}
+
+func TestCreateResolvesUnit(t *testing.T) {
+
+	tests := []struct {
+		name       string
+		unit       string
+		quantity   float64
+		code       UnitOfMeasure
+		normalized string
+		base       UnitOfMeasure
+		want       string
+	}{
+		{"code",              "KGM",     12.5, UOM_KILOGRAM, "12.5",    UOM_KILOGRAM,    ""},
+		{"alias in any case", "Tonnes",  2,    UOM_TONNE,    "2000",    UOM_KILOGRAM,    ""},
+		{"smaller unit",      "g",       250,  UOM_GRAM,     "0.25",    UOM_KILOGRAM,    ""},
+		{"other dimension",   "litres",  1500, UOM_LITRE,    "1.5",     UOM_CUBIC_METRE, ""},
+		{"registered unit",   "lbs",     10,   "LBR",        "4.53592", UOM_KILOGRAM,    ""},
+		{"unknown unit",      "furlong", 1,    "",           "",        "",              `"field":"unitOfMeasure"`},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			l := new_ledger(t)
+			if _, err := l.invoke(TEST_ADMIN, "register_unit", `{"code":"LBR","name":"pound","dimension":"mass","factor":0.453592,"aliases":["lb","lbs"]}`); err != nil { t.Fatal(err) }
+
+			_, err := l.invoke(TEST_SUPPLIER, "create_supplyItem", a_supplyItem("A1").unit(tc.unit).quantity(tc.quantity).json())
+			check_error(t, err, tc.want)
+			if tc.want != "" { return }
+
+			sItem := get_supplyItem(t, l, "A1")
+			if sItem.UnitOfMeasure != tc.code || sItem.NormalizedQty.String() != tc.normalized || sItem.BaseUnit != tc.base {
+				t.Errorf("unit %s, normalized %s %s, want %s, %s %s", sItem.UnitOfMeasure, sItem.NormalizedQty, sItem.BaseUnit, tc.code, tc.normalized, tc.base)
+			}
+		})
+	}
+}
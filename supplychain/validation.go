@@ -0,0 +1,228 @@
+/*
+Copyright IBM Corp 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package supplychain
+
+import (
+	"encoding/json"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/vsagineedu/learn-chaincode/ccerror"
+)
+
+const MAX_ID_LENGTH = 64
+const MAX_TEXT_LENGTH = 1024
+
+//==============================================================================================================================
+//	 new_validation_error - Returns an INVALID_ARGUMENT error for function. Field errors are added as each field is
+//							checked and result() returns it only if any were, so clients receive every field error in
+//							the response rather than only the first.
+//==============================================================================================================================
+func new_validation_error(function string) *ccerror.Error {
+	return ccerror.Invalid(function)
+}
+
+//==============================================================================================================================
+//	 supplyItem_fields - The JSON field names accepted when creating a SupplyItem from a JSON object.
+//	 updatable_fields  - The subset of those that update_supplyItem may change. Custody fields are only changed through
+//						 the transfer functions.
+//==============================================================================================================================
+var supplyItem_fields = []string{"supplyItemID", "supplierID", "operatorID", "ownerID", "longitude", "latitude",
+	"description", "materialType", "materialQuantity", "unitOfMeasure", "photo", "attachments"}
+
+var updatable_fields = []string{"longitude", "latitude", "description", "photo", "attachments"}
+
+//==============================================================================================================================
+//	 parse_supplyItem_json - Builds a SupplyItem from a single JSON object argument.
+//==============================================================================================================================
+func parse_supplyItem_json(function string, input string) (SupplyItem, error) {
+	return decode_supplyItem_fields(function, SupplyItem{}, input, supplyItem_fields)
+}
+
+//==============================================================================================================================
+//	 decode_supplyItem_fields - Overlays the fields of a JSON object onto sItem and validates the result. Each field is
+//								decoded on its own so a bad value is reported against its field name, and fields not
+//								in allowed are rejected.
+//==============================================================================================================================
+func decode_supplyItem_fields(function string, sItem SupplyItem, input string, allowed []string) (SupplyItem, error) {
+
+	var raw map[string]json.RawMessage
+
+	verr := new_validation_error(function)
+
+	if err := json.Unmarshal([]byte(input), &raw); err != nil {
+		verr.Add("", "Argument is not a JSON object: "+err.Error())
+		return sItem, verr
+	}
+
+	var names []string
+	for name := range raw {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if !contains_string(allowed, name) {
+			verr.Add(name, "Unknown or read-only field")
+		}
+	}
+
+	decode := func(name string, target interface{}) {
+		value, ok := raw[name]
+		if !ok || !contains_string(allowed, name) {
+			return
+		}
+		if err := json.Unmarshal(value, target); err != nil {
+			verr.Add(name, "Invalid value: "+err.Error())
+		}
+	}
+
+	decode("supplyItemID", &sItem.SupplyItemID)
+	decode("supplierID", &sItem.SupplierID)
+	decode("operatorID", &sItem.OperatorID)
+	decode("ownerID", &sItem.OwnerID)
+	decode("longitude", &sItem.Longitude)
+	decode("latitude", &sItem.Latitude)
+	decode("description", &sItem.Description)
+	decode("materialType", &sItem.MaterialType)
+	decode("materialQuantity", &sItem.MaterialQty)
+	decode("unitOfMeasure", &sItem.UnitOfMeasure)
+	decode("photo", &sItem.Photo)
+	decode("attachments", &sItem.Attachments)
+
+	_, photo := raw["photo"]
+	_, attachments := raw["attachments"]
+	validate_attachments(verr, &sItem, photo && !verr.Has("photo"), attachments && !verr.Has("attachments"))
+
+	return sItem, verr.Merge(validate_supplyItem(function, sItem))
+}
+
+//==============================================================================================================================
+//	 parse_supplyItem_args - Builds a SupplyItem from the original 11 positional arguments.
+//
+//	Args
+//		0				1			2			3		4			5			6			7			8				9				10
+//	supplyItemID	supplierID	operatorID	ownerID	longitude	latitude	description	materialType	materialQty	unitOfMeasure	photo
+//
+//	The photo is an Attachment JSON object, or "" for none.
+//==============================================================================================================================
+func parse_supplyItem_args(function string, args []string) (SupplyItem, error) {
+
+	var sItem SupplyItem
+	var err error
+
+	verr := new_validation_error(function)
+
+	sItem.SupplyItemID = args[0]
+	sItem.SupplierID = args[1]
+	sItem.OperatorID = args[2]
+	sItem.OwnerID = args[3]
+	sItem.Description = args[6]
+	sItem.MaterialType = args[7]
+	sItem.UnitOfMeasure = UnitOfMeasure(args[9])
+	if args[10] != "" {
+		sItem.Photo = &Attachment{URI: args[10]}
+		if strings.HasPrefix(strings.TrimSpace(args[10]), "{") && json.Unmarshal([]byte(args[10]), sItem.Photo) != nil {
+			verr.Add("photo", "Not an attachment JSON object: "+args[10])
+		}
+	}
+	validate_attachments(verr, &sItem, sItem.Photo != nil && !verr.Has("photo"), false)
+
+	if sItem.Longitude, err = strconv.ParseFloat(strings.TrimSpace(args[4]), 64); err != nil {
+		verr.Add("longitude", "Not a decimal number: "+args[4])
+	}
+	if sItem.Latitude, err = strconv.ParseFloat(strings.TrimSpace(args[5]), 64); err != nil {
+		verr.Add("latitude", "Not a decimal number: "+args[5])
+	}
+	if sItem.MaterialQty, err = parse_quantity(args[8]); err != nil {
+		verr.Add("materialQuantity", "Invalid value: "+err.Error())
+	}
+
+	return sItem, verr.Merge(validate_supplyItem(function, sItem))
+}
+
+//==============================================================================================================================
+//	 validate_supplyItem - Checks every field of a SupplyItem and returns all problems found as an INVALID_ARGUMENT error.
+//==============================================================================================================================
+func validate_supplyItem(function string, sItem SupplyItem) error {
+
+	verr := new_validation_error(function)
+
+	validate_id(verr, "supplyItemID", sItem.SupplyItemID, true)
+	validate_id(verr, "supplierID", sItem.SupplierID, len(sItem.ParentIDs) < 2)		// A lot merged from several suppliers has none
+	validate_id(verr, "operatorID", sItem.OperatorID, false)
+	validate_id(verr, "ownerID", sItem.OwnerID, true)
+
+	validate_coordinates(verr, sItem.Longitude, sItem.Latitude)
+
+	if len(sItem.Description) > MAX_TEXT_LENGTH {
+		verr.Add("description", "Must be at most "+strconv.Itoa(MAX_TEXT_LENGTH)+" characters")
+	}
+
+	if strings.TrimSpace(sItem.MaterialType) == "" {
+		verr.Add("materialType", "Required")
+	} else if len(sItem.MaterialType) > MAX_ID_LENGTH {
+		verr.Add("materialType", "Must be at most "+strconv.Itoa(MAX_ID_LENGTH)+" characters")
+	} else if validate_composite_key_attribute(sItem.MaterialType) != nil {
+		verr.Add("materialType", "Contains a reserved character")
+	}
+
+	if sItem.MaterialQty < 0 || sItem.MaterialQty == 0 && !sItem.QuantityUnrecorded {
+		verr.Add("materialQuantity", "Must be greater than 0")
+	}
+
+	if strings.TrimSpace(string(sItem.UnitOfMeasure)) == "" {
+		verr.Add("unitOfMeasure", "Required")
+	}
+
+	return verr.Result()
+}
+
+//==============================================================================================================================
+//	 validate_id - IDs are limited to letters, digits, '.', '_' and '-' so they can be used safely inside ledger keys.
+//==============================================================================================================================
+func validate_id(verr *ccerror.Error, field string, value string, required bool) {
+
+	if value == "" {
+		if required {
+			verr.Add(field, "Required")
+		}
+		return
+	}
+
+	if len(value) > MAX_ID_LENGTH {
+		verr.Add(field, "Must be at most "+strconv.Itoa(MAX_ID_LENGTH)+" characters")
+		return
+	}
+
+	for _, c := range value {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '.' || c == '_' || c == '-') {
+			verr.Add(field, "May only contain letters, digits, '.', '_' and '-'")
+			return
+		}
+	}
+}
+
+func contains_string(list []string, value string) bool {
+	for _, s := range list {
+		if s == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,149 @@
+/*
+Copyright IBM Corp 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package supplychain
+
+import (
+	"encoding/json"
+	"errors"
+	"sort"
+
+	"github.com/vsagineedu/learn-chaincode/ccerror"
+)
+
+//==============================================================================================================================
+//	Views - The SupplyItem fields each role may read, kept in the Views of the Policy. The owner, operator and supplier
+//			views apply to the participant named in that field of the SupplyItem; the views of the ReadAll roles apply
+//			to every SupplyItem. A caller matching several views sees the union of their fields. ALL_FIELDS stands
+//			for the whole record.
+//==============================================================================================================================
+const ALL_FIELDS = "*"
+
+var viewable_fields = []string{ALL_FIELDS, "supplyItemID", "supplierID", "operatorID", "ownerID", "longitude", "latitude",
+	"description", "materialType", "materialQuantity", "quantityUnrecorded", "unitOfMeasure", "normalizedQuantity", "baseUnit",
+	"photo", "attachments", "status", "parentIDs", "childIDs", "components", "usedInIDs", "archivedAt", "revision", "schemaVersion"}
+
+func default_views() map[string][]string {
+	return map[string][]string{
+		ROLE_OWNER:     {ALL_FIELDS},
+		ROLE_OPERATOR:  {"supplyItemID", "supplierID", "operatorID", "ownerID", "longitude", "latitude", "description", "materialType", "materialQuantity", "quantityUnrecorded", "unitOfMeasure", "normalizedQuantity", "baseUnit", "status", "archivedAt", "revision"},
+		ROLE_SUPPLIER:  {"supplyItemID", "supplierID", "materialType", "materialQuantity", "quantityUnrecorded", "unitOfMeasure", "normalizedQuantity", "baseUnit", "status", "archivedAt", "revision"},
+		ROLE_AUDITOR:   {ALL_FIELDS},
+		ROLE_REGULATOR: {ALL_FIELDS},
+	}
+}
+
+//==============================================================================================================================
+//	Viewer - The caller of a read, with the policy and roles needed to pick its view of each SupplyItem. Built once per
+//			 query so listings do not reread them for every SupplyItem.
+//==============================================================================================================================
+type Viewer struct {
+	Caller string
+	Policy Policy
+	Roles  []string			// The caller's ReadAll roles
+}
+
+func (t *Chaincode) new_viewer(stub Stub, caller string) (Viewer, error) {
+
+	policy, err := t.retrieve_policy(stub)
+	if err != nil { return Viewer{}, err }
+
+	v := Viewer{Caller: caller, Policy: policy}
+
+	if p, err := t.check_active_participant(stub, caller); err == nil {
+		for _, role := range policy.ReadAll {
+			if p.has_role(role) { v.Roles = append(v.Roles, role) }
+		}
+	}
+
+	return v, nil
+}
+
+//==============================================================================================================================
+//	 fields - Returns the fields of sItem the viewer may read, or nil if it may read none.
+//==============================================================================================================================
+func (v Viewer) fields(sItem SupplyItem) []string {
+
+	var fields []string
+
+	add := func(role string) {
+		fields = append(fields, v.Policy.Views[role]...)
+	}
+
+	if sItem.OwnerID == v.Caller { add(ROLE_OWNER) }
+	if sItem.OperatorID == v.Caller { add(ROLE_OPERATOR) }
+	if sItem.SupplierID == v.Caller { add(ROLE_SUPPLIER) }
+
+	for _, role := range v.Roles {
+		add(role)
+	}
+
+	return fields
+}
+
+//==============================================================================================================================
+//	 view_supplyItem - Renders the fields of sItem the viewer may read. Returns the permission error if there are none.
+//==============================================================================================================================
+func view_supplyItem(v Viewer, sItem SupplyItem, function string) ([]byte, error) {
+
+	fields := v.fields(sItem)
+
+	if len(fields) == 0 { return nil, permission_denied(function) }
+
+	if contains_string(fields, ALL_FIELDS) { return json.Marshal(sItem) }
+
+	all := map[string]json.RawMessage{}
+	if err := to_field_map(sItem, &all); err != nil { return nil, errors.New("Invalid supply item object") }
+
+	view := map[string]json.RawMessage{}
+	for _, field := range fields {
+		if value, ok := all[field]; ok {
+			view[field] = value
+		}
+	}
+
+	return json.Marshal(view)
+}
+
+func validate_views(verr *ccerror.Error, views map[string][]string) {
+
+	var roles []string
+	for role := range views {
+		roles = append(roles, role)
+	}
+	sort.Strings(roles)
+
+	for _, role := range roles {
+		if !contains_string(participant_roles, role) { verr.Add("views."+role, "Unknown role "+role); continue }
+		for _, field := range views[role] {
+			if !contains_string(viewable_fields, field) { verr.Add("views."+role, "Unknown field "+field) }
+		}
+	}
+}
+
+//=================================================================================================================================
+//	 get_supplyItem - Returns the caller's view of a single SupplyItem.
+//=================================================================================================================================
+func (t *Chaincode) get_supplyItem(stub Stub, caller string, supplyItemID string) ([]byte, error) {
+
+	sItem, err := t.retrieve_SupplyItem(stub, supplyItemID)
+	if err != nil { return nil, err }
+
+	viewer, err := t.new_viewer(stub, caller)
+	if err != nil { return nil, err }
+
+	return view_supplyItem(viewer, sItem, "get_supplyItem")
+}
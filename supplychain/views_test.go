@@ -0,0 +1,98 @@
+/*
+Copyright IBM Corp 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+
+package supplychain
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func view_fields(t *testing.T, bytes []byte) string {
+	var view map[string]json.RawMessage
+	if err := json.Unmarshal(bytes, &view); err != nil { t.Fatalf("not a view: %s: %s", err, bytes) }
+
+	fields := []string{}
+	for field := range view {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	return strings.Join(fields, ",")
+}
+
+func TestGetSupplyItemViews(t *testing.T) {
+
+	supplier := "baseUnit,materialQuantity,materialType,normalizedQuantity,revision,status,supplierID,supplyItemID,unitOfMeasure"
+	operator := "baseUnit,description,latitude,longitude,materialQuantity,materialType,normalizedQuantity,operatorID,ownerID,revision,status,supplierID,supplyItemID,unitOfMeasure"
+	all := "baseUnit,description,latitude,longitude,materialQuantity,materialType,normalizedQuantity,operatorID,ownerID,revision,schemaVersion,status,supplierID,supplyItemID,unitOfMeasure"
+
+	tests := []struct {
+		name   string
+		caller string
+		policy string
+		fields string
+		want   string
+	}{
+		{"owner",               TEST_OWNER,    "",                                                 all,                  ""},
+		{"operator",            TEST_OPERATOR, "",                                                 operator,             ""},
+		{"supplier",            TEST_SUPPLIER, "",                                                 supplier,             ""},
+		{"auditor",             TEST_AUDITOR,  "",                                                 all,                  ""},
+		{"anyone else",         "owner2",      "",                                                 "",                   `"code":"PERMISSION_DENIED"`},
+		{"configured view",     TEST_SUPPLIER, `{"views":{"supplier":["supplyItemID","status"]}}`, "status,supplyItemID", ""},
+		{"view taken away",     TEST_AUDITOR,  `{"views":{"auditor":[]}}`,                         "",                   `"code":"PERMISSION_DENIED"`},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			l := new_ledger(t)
+			l.register("owner2", ROLE_OWNER)
+			l.create(a_supplyItem("A1"))
+			if tc.policy != "" {
+				if _, err := l.invoke(TEST_ADMIN, "update_policy", tc.policy); err != nil { t.Fatalf("update_policy: %s", err) }
+			}
+
+			bytes, err := l.query(tc.caller, "get_supplyItem", "A1")
+			check_error(t, err, tc.want)
+			if tc.want != "" { return }
+
+			if fields := view_fields(t, bytes); fields != tc.fields { t.Errorf("fields = %s, want %s", fields, tc.fields) }
+		})
+	}
+}
+
+func TestUpdateViewsRefused(t *testing.T) {
+
+	tests := []struct {
+		name  string
+		views string
+		want  string
+	}{
+		{"unknown field", `{"views":{"supplier":["price"]}}`, `"field":"views.supplier"`},
+		{"unknown role",  `{"views":{"buyer":["status"]}}`,   `"field":"views.buyer"`},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			l := new_ledger(t)
+
+			_, err := l.invoke(TEST_ADMIN, "update_policy", tc.views)
+			check_error(t, err, tc.want)
+		})
+	}
+}